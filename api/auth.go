@@ -0,0 +1,61 @@
+// api/auth.go
+
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"dfs/auth"
+	metadataPb "dfs/proto/metadata"
+)
+
+// requestToken returns the API token an HTTP request was made with: a bearer
+// token, or the password of HTTP basic authentication, which is what WebDAV
+// clients can send. The user name is ignored, since the token says who the
+// caller is.
+func requestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	return ""
+}
+
+// requireToken authenticates requests with the cluster, then serves them with
+// the caller's token so the metadata service enforces the caller's
+// permissions. When the cluster has authentication disabled, every request is
+// let through. The challenge is sent with 401 responses so browsers and
+// WebDAV clients prompt for credentials.
+func (api *API) requireToken(challenge string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if token := requestToken(c.Request); token != "" {
+			ctx = auth.WithToken(ctx, token)
+		}
+
+		resp, err := api.client.WhoAmI(ctx)
+		if err != nil {
+			code := errorStatus(err)
+			if code == http.StatusUnauthorized {
+				c.Header("WWW-Authenticate", challenge)
+			}
+			c.AbortWithStatusJSON(code, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set("whoami", resp)
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// whoami handles GET /whoami, describing the caller
+func (api *API) whoami(c *gin.Context) {
+	resp := c.MustGet("whoami").(*metadataPb.WhoAmIResponse)
+	c.JSON(200, gin.H{"user": resp.User, "auth_enabled": resp.AuthEnabled})
+}
@@ -3,88 +3,259 @@
 package main
 
 import (
+	"flag"
+	"io"
 	"log"
-	"os"
+	"mime/multipart"
+	"net/http"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"dfs/auth"
 	"dfs/clientlib"
+	metadataPb "dfs/proto/metadata"
 )
 
 type API struct {
 	client *clientlib.Client
 }
 
-func NewAPI() *API {
-	client := clientlib.NewClient()
+func NewAPI(metadataAddrs []string) (*API, error) {
+	client, err := clientlib.NewClient(clientlib.WithMetadataAddrs(metadataAddrs...))
+	if err != nil {
+		return nil, err
+	}
 	return &API{
 		client: client,
+	}, nil
+}
+
+// errorStatus maps the gRPC status of a failed cluster operation to an HTTP
+// status code
+func errorStatus(err error) int {
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		return 400
+	case codes.Unauthenticated:
+		return 401
+	case codes.PermissionDenied:
+		return 403
+	case codes.NotFound:
+		return 404
+	case codes.AlreadyExists, codes.FailedPrecondition:
+		return 409
+	default:
+		return 500
 	}
 }
 
-// uploadFile handles file uploads
+// uploadFile handles file uploads, streaming the file into the cluster as it
+// arrives. The file is stored in the directory given by the dir query
+// parameter, which is created if missing, or in the root directory.
 func (api *API) uploadFile(c *gin.Context) {
 	// Multipart form
-	fileHeader, err := c.FormFile("file")
+	reader, err := c.Request.MultipartReader()
 	if err != nil {
 		c.JSON(400, gin.H{"error": "No file is received"})
 		return
 	}
 
-	filePath := filepath.Join("uploads", filepath.Base(fileHeader.Filename))
+	// Find the file part
+	var part *multipart.Part
+	for {
+		part, err = reader.NextPart()
+		if err != nil {
+			c.JSON(400, gin.H{"error": "No file is received"})
+			return
+		}
+		if part.FormName() == "file" && part.FileName() != "" {
+			break
+		}
+	}
+	defer part.Close()
+
+	fileName := path.Join(c.Query("dir"), filepath.Base(part.FileName()))
+
+	file, err := api.client.Create(c.Request.Context(), fileName)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// An upload that is not closed is abandoned and expires on the metadata
+	// service, so a failed transfer never replaces the file
+	if _, err := io.Copy(file, part); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if err := file.Close(); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "File uploaded successfully"})
+}
 
-	// Ensure the uploads directory exists
-	if err := os.MkdirAll("uploads", os.ModePerm); err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create uploads directory"})
+// downloadFile handles file downloads. Range requests are supported, so
+// clients can resume downloads and browsers can seek in media files. An
+// older version of the file is served when the version query parameter is
+// set, and the file as of a snapshot when the snapshot query parameter is.
+func (api *API) downloadFile(c *gin.Context) {
+	fileName := c.Param("path")
+	if fileName == "/" {
+		c.JSON(400, gin.H{"error": "Filename is required"})
 		return
 	}
 
-	// Save the uploaded file to the uploads directory
-	if err := c.SaveUploadedFile(fileHeader, filePath); err != nil {
-		c.JSON(500, gin.H{"error": "Failed to save file"})
+	var version int64
+	if v := c.Query("version"); v != "" {
+		var err error
+		version, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || version < 1 {
+			c.JSON(400, gin.H{"error": "Invalid version " + v})
+			return
+		}
+	}
+
+	snapshot := c.Query("snapshot")
+	if snapshot != "" && version != 0 {
+		c.JSON(400, gin.H{"error": "Version and snapshot cannot be combined"})
 		return
 	}
 
-	err = api.client.UploadFile(filePath)
+	uploadDate, err := api.uploadDate(c, fileName, version, snapshot)
 	if err != nil {
-		// Delete the temporary file only if upload failed
-		os.Remove(filePath)
-		c.JSON(500, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	// Delete the temporary file after successful upload
-	if err := os.Remove(filePath); err != nil {
-		log.Printf("Warning: failed to delete temporary file %s: %v", filePath, err)
+	modTime, err := time.ParseInLocation("2006-01-02 15:04:05", uploadDate, time.Local)
+	if err != nil {
+		modTime = time.Time{}
 	}
 
-	c.JSON(200, gin.H{"status": "File uploaded successfully"})
+	// Stream only the requested bytes from the cluster
+	var file io.ReadSeekCloser
+	if snapshot != "" {
+		file, err = api.client.OpenSnapshot(c.Request.Context(), snapshot, fileName)
+	} else {
+		file, err = api.client.OpenVersion(c.Request.Context(), fileName, version)
+	}
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	http.ServeContent(c.Writer, c.Request, path.Base(fileName), modTime, file)
 }
 
-// downloadFile handles file downloads
-func (api *API) downloadFile(c *gin.Context) {
-	fileName := c.Param("filename")
-	if fileName == "" {
+// uploadDate returns when a version of a file, or its copy in a snapshot, was
+// uploaded, rejecting directories. Version 0 is the current version.
+func (api *API) uploadDate(c *gin.Context, fileName string, version int64, snapshot string) (string, error) {
+	if version == 0 {
+		var entry *metadataPb.DirEntry
+		var err error
+		if snapshot != "" {
+			entry, err = api.client.StatSnapshot(c.Request.Context(), snapshot, fileName)
+		} else {
+			entry, err = api.client.Stat(c.Request.Context(), fileName)
+		}
+		if err != nil {
+			return "", err
+		}
+		if entry.IsDir {
+			return "", status.Errorf(codes.InvalidArgument, "%s is a directory", fileName)
+		}
+		return entry.Created, nil
+	}
+
+	versions, err := api.client.ListVersions(c.Request.Context(), fileName)
+	if err != nil {
+		return "", err
+	}
+	for _, file := range versions {
+		if file.Version == version {
+			return file.UploadDate, nil
+		}
+	}
+	return "", status.Errorf(codes.NotFound, "Version %d of file %s not found", version, fileName)
+}
+
+// listVersions handles listing every version of a file, newest first
+func (api *API) listVersions(c *gin.Context) {
+	versions, err := api.client.ListVersions(c.Request.Context(), c.Param("path"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"versions": versions})
+}
+
+// deleteFile handles deletion of a file or a directory. A directory must be
+// empty unless the recursive query parameter is true.
+func (api *API) deleteFile(c *gin.Context) {
+	fileName := c.Param("path")
+	if fileName == "/" {
 		c.JSON(400, gin.H{"error": "Filename is required"})
 		return
 	}
 
-	// Use the client library to download the file
-	err := api.client.DownloadFile(fileName)
+	recursive := c.Query("recursive") == "true"
+	err := api.client.DeleteFile(c.Request.Context(), fileName, recursive)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Deleted successfully"})
+}
+
+// renameRequest is the body of a rename request
+type renameRequest struct {
+	Dst       string `json:"dst"`
+	Overwrite bool   `json:"overwrite"`
+}
+
+// renameFile handles POST /files/<path>/rename, atomically moving a file or a
+// directory to the path given in the body. An existing file at the
+// destination is only replaced when overwrite is true.
+func (api *API) renameFile(c *gin.Context) {
+	src, ok := strings.CutSuffix(c.Param("path"), "/rename")
+	if !ok {
+		c.JSON(404, gin.H{"error": "Unknown operation"})
+		return
+	}
+	if src == "" {
+		c.JSON(400, gin.H{"error": "Filename is required"})
 		return
 	}
 
-	downloadPath := filepath.Join("dfs_downloads", fileName)
+	var req renameRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Dst == "" {
+		c.JSON(400, gin.H{"error": "Destination is required"})
+		return
+	}
 
-	c.File(downloadPath)
+	err := api.client.Rename(c.Request.Context(), src, req.Dst, req.Overwrite)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Renamed successfully"})
 }
 
 // listFiles handles listing all available files
 func (api *API) listFiles(c *gin.Context) {
-	files, err := api.client.ListFiles()
+	files, err := api.client.ListFiles(c.Request.Context())
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -92,8 +263,134 @@ func (api *API) listFiles(c *gin.Context) {
 	c.JSON(200, gin.H{"files": files})
 }
 
+// listDir handles listing the contents of a directory, as of a snapshot when
+// the snapshot query parameter is set
+func (api *API) listDir(c *gin.Context) {
+	var entries []*metadataPb.DirEntry
+	var err error
+	if snapshot := c.Query("snapshot"); snapshot != "" {
+		entries, err = api.client.ListSnapshotDir(c.Request.Context(), snapshot, c.Param("path"))
+	} else {
+		entries, err = api.client.ListDir(c.Request.Context(), c.Param("path"))
+	}
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"entries": entries})
+}
+
+// mkdir handles directory creation. Missing parents are created when the
+// parents query parameter is true.
+func (api *API) mkdir(c *gin.Context) {
+	dirPath := c.Param("path")
+	parents := c.Query("parents") == "true"
+	if err := api.client.Mkdir(c.Request.Context(), dirPath, parents); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "Directory created successfully"})
+}
+
+// stat handles describing a file or a directory, as of a snapshot when the
+// snapshot query parameter is set
+func (api *API) stat(c *gin.Context) {
+	var entry *metadataPb.DirEntry
+	var err error
+	if snapshot := c.Query("snapshot"); snapshot != "" {
+		entry, err = api.client.StatSnapshot(c.Request.Context(), snapshot, c.Param("path"))
+	} else {
+		entry, err = api.client.Stat(c.Request.Context(), c.Param("path"))
+	}
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"entry": entry})
+}
+
+// snapshotRequest is the body of a snapshot creation request
+type snapshotRequest struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// createSnapshot handles taking a snapshot of the directory given in the
+// body, or of the whole namespace if no path is given
+func (api *API) createSnapshot(c *gin.Context) {
+	var req snapshotRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" {
+		c.JSON(400, gin.H{"error": "Snapshot name is required"})
+		return
+	}
+
+	info, err := api.client.CreateSnapshot(c.Request.Context(), req.Name, req.Path)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"snapshot": info})
+}
+
+// listSnapshots handles listing all snapshots
+func (api *API) listSnapshots(c *gin.Context) {
+	snapshots, err := api.client.ListSnapshots(c.Request.Context())
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"snapshots": snapshots})
+}
+
+// deleteSnapshot handles deletion of a snapshot
+func (api *API) deleteSnapshot(c *gin.Context) {
+	if err := api.client.DeleteSnapshot(c.Request.Context(), c.Param("name")); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "Snapshot deleted successfully"})
+}
+
 func main() {
-	api := NewAPI()
+	metadataAddrs := flag.String("metadata", "localhost:50051", "Comma-separated list of metadata service addresses")
+	s3Addr := flag.String("s3_addr", "", "Address to serve the S3-compatible API on, such as :9000 (disabled if empty)")
+	s3AccessKey := flag.String("s3_access_key", "", "Access key ID that S3 requests must be signed with")
+	s3SecretKey := flag.String("s3_secret_key", "", "Secret access key that S3 requests must be signed with")
+	s3TokenFile := flag.String("s3_token_file", "", "File holding the API token S3 requests act with, when the cluster requires authentication")
+	flag.Parse()
+
+	api, err := NewAPI(strings.Split(*metadataAddrs, ","))
+	if err != nil {
+		log.Fatalf("Failed to create client: %v", err)
+	}
+
+	// Serve the S3-compatible API on its own port, since S3 clients own the
+	// whole path space
+	if *s3Addr != "" {
+		if *s3AccessKey == "" || *s3SecretKey == "" {
+			log.Fatalf("The S3 API requires -s3_access_key and -s3_secret_key")
+		}
+		s3 := NewS3(api.client, *s3AccessKey, *s3SecretKey)
+		s3Router := gin.Default()
+
+		// S3 clients sign requests with the access key rather than sending
+		// an API token, so every S3 request acts as the owner of one token
+		if *s3TokenFile != "" {
+			token, err := auth.ReadTokenFile(*s3TokenFile)
+			if err != nil {
+				log.Fatalf("Failed to read S3 token: %v", err)
+			}
+			s3Router.Use(func(c *gin.Context) {
+				c.Request = c.Request.WithContext(auth.WithToken(c.Request.Context(), token))
+			})
+		}
+		s3Router.Any("/*path", s3.handle)
+		go func() {
+			if err := s3Router.Run(*s3Addr); err != nil {
+				log.Fatalf("Failed to run S3 server: %v", err)
+			}
+		}()
+	}
 
 	router := gin.Default()
 
@@ -103,20 +400,31 @@ func main() {
 		c.File("./static/index.html")
 	})
 
-	// Define API routes
-	router.POST("/upload", api.uploadFile)
-	router.GET("/download/:filename", api.downloadFile)
-	router.GET("/files", api.listFiles)
+	// Define API routes, which act with the caller's API token
+	authed := router.Group("/", api.requireToken(`Bearer realm="dfs"`))
+	authed.GET("/whoami", api.whoami)
+	authed.POST("/upload", api.uploadFile)
+	authed.GET("/download/*path", api.downloadFile)
+	authed.GET("/files", api.listFiles)
+	authed.DELETE("/files/*path", api.deleteFile)
+	authed.POST("/files/*path", api.renameFile)
+	authed.GET("/dirs/*path", api.listDir)
+	authed.POST("/dirs/*path", api.mkdir)
+	authed.GET("/stat/*path", api.stat)
+	authed.GET("/versions/*path", api.listVersions)
+	authed.GET("/snapshots", api.listSnapshots)
+	authed.POST("/snapshots", api.createSnapshot)
+	authed.DELETE("/snapshots/:name", api.deleteSnapshot)
 
-	// Ensure uploads and dfs_downloads directories exist
-	if err := os.MkdirAll("uploads", os.ModePerm); err != nil {
-		log.Fatalf("Failed to create uploads directory: %v", err)
-	}
-	if err := os.MkdirAll("dfs_downloads", os.ModePerm); err != nil {
-		log.Fatalf("Failed to create dfs_downloads directory: %v", err)
+	// Serve the namespace over WebDAV for desktop and editor mounts. WebDAV
+	// clients send the API token as the password of basic authentication.
+	davHandler := serveWebDAV(NewWebDAV(api.client, "/webdav"))
+	davAuth := api.requireToken(`Basic realm="dfs"`)
+	for _, method := range webdavMethods {
+		router.Handle(method, "/webdav/*path", davAuth, davHandler)
 	}
 
-	err := router.Run(":8080")
+	err = router.Run(":8080")
 	if err != nil {
 		log.Fatalf("Failed to run API server: %v", err)
 	}
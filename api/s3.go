@@ -0,0 +1,385 @@
+// api/s3.go
+
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"dfs/clientlib"
+	metadataPb "dfs/proto/metadata"
+)
+
+// s3Namespace is the XML namespace of S3 responses
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// bucketPattern matches the bucket names S3 allows for new buckets
+var bucketPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// unsupportedSubresources are S3 query parameters selecting features this
+// server does not implement. Requests using them are refused rather than
+// mistaken for plain object or bucket requests.
+var unsupportedSubresources = []string{
+	"accelerate", "acl", "analytics", "attributes", "cors", "encryption",
+	"intelligent-tiering", "inventory", "legal-hold", "lifecycle", "logging",
+	"metrics", "notification", "object-lock", "ownershipControls", "policy",
+	"policyStatus", "publicAccessBlock", "replication", "requestPayment",
+	"restore", "retention", "select", "tagging", "torrent", "versioning",
+	"versions", "website",
+}
+
+// S3 serves an S3-compatible API on top of the client library. Buckets are
+// the top-level directories of the file system and object keys are paths
+// inside them, so a key with slashes is stored in subdirectories. Requests
+// must be path-style (http://host/bucket/key) and signed with Signature
+// Version 4 using the configured access key.
+type S3 struct {
+	client    *clientlib.Client
+	accessKey string
+	secretKey string
+}
+
+func NewS3(client *clientlib.Client, accessKey, secretKey string) *S3 {
+	return &S3{
+		client:    client,
+		accessKey: accessKey,
+		secretKey: secretKey,
+	}
+}
+
+// s3Error is an error reported to S3 clients
+type s3Error struct {
+	status  int
+	code    string
+	message string
+}
+
+func errS3(status int, code, message string) *s3Error {
+	return &s3Error{status: status, code: code, message: message}
+}
+
+func (e *s3Error) Error() string {
+	return e.code + ": " + e.message
+}
+
+var (
+	errNoSuchBucket = errS3(http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+	errNoSuchKey    = errS3(http.StatusNotFound, "NoSuchKey", "The specified key does not exist")
+	errNoSuchUpload = errS3(http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist")
+	errMalformedXML = errS3(http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed")
+)
+
+// toS3Error maps a failed cluster operation to an S3 error. A missing file
+// or directory is reported as notFound.
+func toS3Error(err error, notFound *s3Error) *s3Error {
+	var s3Err *s3Error
+	if errors.As(err, &s3Err) {
+		return s3Err
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return errS3(http.StatusBadRequest, "IncompleteBody", "The request body ended before Content-Length bytes were received")
+	}
+	switch status.Code(err) {
+	case codes.NotFound:
+		return notFound
+	case codes.InvalidArgument:
+		return errS3(http.StatusBadRequest, "InvalidArgument", err.Error())
+	case codes.AlreadyExists, codes.FailedPrecondition:
+		return errS3(http.StatusConflict, "OperationAborted", err.Error())
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return errS3(http.StatusForbidden, "AccessDenied", err.Error())
+	case codes.Unavailable:
+		return errS3(http.StatusServiceUnavailable, "ServiceUnavailable", err.Error())
+	default:
+		return errS3(http.StatusInternalServerError, "InternalError", err.Error())
+	}
+}
+
+// errorResponse is the body of an S3 error response
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string
+	Message   string
+	Resource  string
+	RequestId string
+}
+
+// writeError sends an S3 error response. Responses to HEAD requests have no
+// body.
+func writeError(c *gin.Context, err *s3Error) {
+	if err.status >= 500 {
+		log.Printf("S3: %s %s failed: %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+	if c.Request.Method == http.MethodHead {
+		c.Status(err.status)
+		return
+	}
+	c.XML(err.status, errorResponse{
+		Code:      err.code,
+		Message:   err.message,
+		Resource:  c.Request.URL.Path,
+		RequestId: c.Writer.Header().Get("x-amz-request-id"),
+	})
+}
+
+// newID returns a random hex string, for request and upload IDs
+func newID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Fatalf("Failed to generate random ID: %v", err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// s3Time converts a stored upload date to the format S3 listings use
+func s3Time(date string) string {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", date, time.Local)
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// objectETag returns the entity tag of a file version: the MD5 of its
+// contents, recorded when it was written through this gateway or any other
+// streaming upload. Files uploaded without one get a tag derived from the
+// version instead, shaped like the tag of a multipart object so clients do
+// not mistake it for an MD5; it still changes whenever the object does.
+func objectETag(info *metadataPb.FileInfo) string {
+	if info.ContentMd5 != "" {
+		return `"` + info.ContentMd5 + `"`
+	}
+	sum := md5.Sum([]byte(fmt.Sprintf("%s\x00%d\x00%d\x00%s", info.FileName, info.Version, info.FileSize, info.UploadDate)))
+	return fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sum[:]), max(info.NumChunks, 1))
+}
+
+// splitS3Path splits a path-style request path into bucket and key
+func splitS3Path(p string) (string, string) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(p, "/"), "/")
+	return bucket, key
+}
+
+// objectPath returns where an object is stored in the file system
+func objectPath(bucket, key string) string {
+	return bucket + "/" + key
+}
+
+// validateKey rejects keys that would not map onto a path one to one, such as
+// "a//b" or "a/../b". A trailing slash names a directory.
+func validateKey(key string) *s3Error {
+	trimmed := strings.TrimSuffix(key, "/")
+	if trimmed == "" || len(key) > 1024 || strings.ContainsRune(key, 0) || path.Clean("/"+trimmed) != "/"+trimmed {
+		return errS3(http.StatusBadRequest, "InvalidArgument", "Unsupported object key "+key+": keys must not contain empty, '.' or '..' segments")
+	}
+	return nil
+}
+
+// handle authenticates an S3 request and dispatches it on its method, path
+// and query string
+func (s *S3) handle(c *gin.Context) {
+	c.Header("x-amz-request-id", newID())
+
+	if err := s.authenticate(c.Request); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	query := c.Request.URL.Query()
+	for _, subresource := range unsupportedSubresources {
+		if query.Has(subresource) {
+			writeError(c, errS3(http.StatusNotImplemented, "NotImplemented", "The "+subresource+" subresource is not supported"))
+			return
+		}
+	}
+
+	bucket, key := splitS3Path(c.Request.URL.Path)
+	method := c.Request.Method
+
+	switch {
+	case bucket == "" && method == http.MethodGet:
+		s.listBuckets(c)
+	case bucket == "":
+		writeError(c, errS3(http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource"))
+	case key == "":
+		switch {
+		case method == http.MethodGet && query.Has("uploads"):
+			s.listMultipartUploads(c, bucket)
+		case method == http.MethodGet && query.Has("location"):
+			s.getBucketLocation(c, bucket)
+		case method == http.MethodGet:
+			s.listObjects(c, bucket)
+		case method == http.MethodHead:
+			s.headBucket(c, bucket)
+		case method == http.MethodPut:
+			s.createBucket(c, bucket)
+		case method == http.MethodDelete:
+			s.deleteBucket(c, bucket)
+		case method == http.MethodPost && query.Has("delete"):
+			s.deleteObjects(c, bucket)
+		default:
+			writeError(c, errS3(http.StatusNotImplemented, "NotImplemented", "This bucket operation is not supported"))
+		}
+	default:
+		if err := validateKey(key); err != nil {
+			writeError(c, err)
+			return
+		}
+		switch {
+		case method == http.MethodPut && query.Has("uploadId"):
+			s.uploadPart(c, bucket, key)
+		case method == http.MethodPut:
+			s.putObject(c, bucket, key)
+		case method == http.MethodGet && query.Has("uploadId"):
+			s.listParts(c, bucket, key)
+		case method == http.MethodGet, method == http.MethodHead:
+			s.getObject(c, bucket, key)
+		case method == http.MethodDelete && query.Has("uploadId"):
+			s.abortMultipartUpload(c, bucket, key)
+		case method == http.MethodDelete:
+			s.deleteObject(c, bucket, key)
+		case method == http.MethodPost && query.Has("uploads"):
+			s.createMultipartUpload(c, bucket, key)
+		case method == http.MethodPost && query.Has("uploadId"):
+			s.completeMultipartUpload(c, bucket, key)
+		default:
+			writeError(c, errS3(http.StatusNotImplemented, "NotImplemented", "This object operation is not supported"))
+		}
+	}
+}
+
+// checkBucket reports an error unless bucket names an existing bucket
+func (s *S3) checkBucket(c *gin.Context, bucket string) *s3Error {
+	if !bucketPattern.MatchString(bucket) {
+		return errS3(http.StatusBadRequest, "InvalidBucketName", "The specified bucket is not valid")
+	}
+	entry, err := s.client.Stat(c.Request.Context(), bucket)
+	if err != nil {
+		return toS3Error(err, errNoSuchBucket)
+	}
+	if !entry.IsDir {
+		return errNoSuchBucket
+	}
+	return nil
+}
+
+// owner identifies the owner of buckets and objects in listings
+type owner struct {
+	ID          string
+	DisplayName string
+}
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+	Owner   owner
+	Buckets []bucketInfo `xml:"Buckets>Bucket"`
+}
+
+type bucketInfo struct {
+	Name         string
+	CreationDate string
+}
+
+// listBuckets handles ListBuckets. Top-level directories whose names are not
+// valid bucket names are left out.
+func (s *S3) listBuckets(c *gin.Context) {
+	entries, err := s.client.ListDir(c.Request.Context(), "/")
+	if err != nil {
+		writeError(c, toS3Error(err, errNoSuchBucket))
+		return
+	}
+
+	result := listAllMyBucketsResult{
+		Owner:   owner{ID: s.accessKey, DisplayName: s.accessKey},
+		Buckets: []bucketInfo{},
+	}
+	for _, entry := range entries {
+		if entry.IsDir && bucketPattern.MatchString(entry.Path) {
+			result.Buckets = append(result.Buckets, bucketInfo{
+				Name:         entry.Path,
+				CreationDate: s3Time(entry.Created),
+			})
+		}
+	}
+	c.XML(http.StatusOK, result)
+}
+
+// createBucket handles CreateBucket by creating a top-level directory
+func (s *S3) createBucket(c *gin.Context, bucket string) {
+	if !bucketPattern.MatchString(bucket) {
+		writeError(c, errS3(http.StatusBadRequest, "InvalidBucketName", "The specified bucket is not valid"))
+		return
+	}
+
+	err := s.client.Mkdir(c.Request.Context(), bucket, false)
+	if status.Code(err) == codes.AlreadyExists {
+		writeError(c, errS3(http.StatusConflict, "BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it"))
+		return
+	}
+	if err != nil {
+		writeError(c, toS3Error(err, errNoSuchBucket))
+		return
+	}
+
+	log.Printf("S3: created bucket %s", bucket)
+	c.Header("Location", "/"+bucket)
+	c.Status(http.StatusOK)
+}
+
+// headBucket handles HeadBucket
+func (s *S3) headBucket(c *gin.Context, bucket string) {
+	if err := s.checkBucket(c, bucket); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+type locationConstraint struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+}
+
+// getBucketLocation handles GetBucketLocation. The cluster has no regions,
+// which S3 clients read as the default region.
+func (s *S3) getBucketLocation(c *gin.Context, bucket string) {
+	if err := s.checkBucket(c, bucket); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.XML(http.StatusOK, locationConstraint{})
+}
+
+// deleteBucket handles DeleteBucket. Only empty buckets can be deleted.
+func (s *S3) deleteBucket(c *gin.Context, bucket string) {
+	if err := s.checkBucket(c, bucket); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	err := s.client.DeleteFile(c.Request.Context(), bucket, false)
+	if status.Code(err) == codes.FailedPrecondition {
+		writeError(c, errS3(http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty"))
+		return
+	}
+	if err != nil {
+		writeError(c, toS3Error(err, errNoSuchBucket))
+		return
+	}
+
+	log.Printf("S3: deleted bucket %s", bucket)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,546 @@
+// api/s3_auth.go
+
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	amzDateFormat   = "20060102T150405Z"
+	maxClockSkew    = 15 * time.Minute
+	maxPresignedAge = 7 * 24 * time.Hour
+	emptySHA256     = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// Values of x-amz-content-sha256 that do not carry the hash of the body
+	unsignedPayload          = "UNSIGNED-PAYLOAD"
+	streamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingPayloadTrailer  = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	maxStreamingChunk = 64 * 1024 * 1024 // Largest aws-chunked chunk buffered for verification
+)
+
+// crc64NVMETable is for the CRC-64/NVME checksums of x-amz-checksum-crc64nvme
+var crc64NVMETable = crc64.MakeTable(0x9a6c9329ac4bc9b5)
+
+// sigV4 holds the parts of a Signature Version 4 signature, taken from the
+// Authorization header or from the query string of a presigned URL
+type sigV4 struct {
+	accessKey     string
+	scope         string // date/region/service/aws4_request
+	date          string // Date of the scope, YYYYMMDD
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+	amzDate       string
+	presigned     bool
+	expires       time.Duration // Presigned URLs only
+}
+
+// parseAuthorization parses an Authorization header of the form
+// "AWS4-HMAC-SHA256 Credential=AK/scope, SignedHeaders=a;b, Signature=hex"
+func parseAuthorization(header string) (*sigV4, *s3Error) {
+	rest, ok := strings.CutPrefix(header, sigV4Algorithm+" ")
+	if !ok {
+		return nil, errS3(http.StatusBadRequest, "AuthorizationHeaderMalformed", "Only "+sigV4Algorithm+" signatures are supported")
+	}
+
+	sig := &sigV4{}
+	for _, field := range strings.Split(rest, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return nil, errS3(http.StatusBadRequest, "AuthorizationHeaderMalformed", "Malformed field "+field)
+		}
+		switch name {
+		case "Credential":
+			if err := sig.parseCredential(value); err != nil {
+				return nil, err
+			}
+		case "SignedHeaders":
+			sig.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			sig.signature = value
+		}
+	}
+	if sig.accessKey == "" || len(sig.signedHeaders) == 0 || sig.signature == "" {
+		return nil, errS3(http.StatusBadRequest, "AuthorizationHeaderMalformed", "Credential, SignedHeaders and Signature are required")
+	}
+	return sig, nil
+}
+
+// parsePresigned parses the signature of a presigned URL
+func parsePresigned(query url.Values) (*sigV4, *s3Error) {
+	if query.Get("X-Amz-Algorithm") != sigV4Algorithm {
+		return nil, errS3(http.StatusBadRequest, "AuthorizationQueryParametersError", "Only "+sigV4Algorithm+" signatures are supported")
+	}
+
+	sig := &sigV4{
+		signedHeaders: strings.Split(query.Get("X-Amz-SignedHeaders"), ";"),
+		signature:     query.Get("X-Amz-Signature"),
+		amzDate:       query.Get("X-Amz-Date"),
+		presigned:     true,
+	}
+	if err := sig.parseCredential(query.Get("X-Amz-Credential")); err != nil {
+		return nil, err
+	}
+
+	seconds, err := strconv.ParseInt(query.Get("X-Amz-Expires"), 10, 64)
+	if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > maxPresignedAge {
+		return nil, errS3(http.StatusBadRequest, "AuthorizationQueryParametersError", "X-Amz-Expires must be between 0 and 604800 seconds")
+	}
+	sig.expires = time.Duration(seconds) * time.Second
+
+	if sig.signature == "" || sig.amzDate == "" {
+		return nil, errS3(http.StatusBadRequest, "AuthorizationQueryParametersError", "X-Amz-Signature and X-Amz-Date are required")
+	}
+	return sig, nil
+}
+
+// parseCredential splits "AK/20130524/us-east-1/s3/aws4_request"
+func (sig *sigV4) parseCredential(credential string) *s3Error {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" {
+		return errS3(http.StatusBadRequest, "AuthorizationHeaderMalformed", "Malformed credential "+credential)
+	}
+	if parts[3] != "s3" {
+		return errS3(http.StatusBadRequest, "AuthorizationHeaderMalformed", "Credential is not for the s3 service")
+	}
+	sig.accessKey = parts[0]
+	sig.date, sig.region, sig.service = parts[1], parts[2], parts[3]
+	sig.scope = strings.Join(parts[1:], "/")
+	return nil
+}
+
+// signingKey derives the key a signature for scope is made with
+func signingKey(secretKey, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// awsURIEncode percent-encodes s the way SigV4 canonical requests require:
+// every byte except unreserved characters, and slashes unless encodeSlash is
+// false
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery sorts and encodes the query string, leaving out the
+// signature of a presigned URL
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		if name == "X-Amz-Signature" {
+			continue
+		}
+		for _, value := range values {
+			pairs = append(pairs, awsURIEncode(name, true)+"="+awsURIEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// canonicalHeaders lists the signed headers with their values trimmed and
+// inner whitespace collapsed
+func canonicalHeaders(r *http.Request, signedHeaders []string) string {
+	var b strings.Builder
+	for _, name := range signedHeaders {
+		var values []string
+		switch name {
+		case "host":
+			values = []string{r.Host}
+		case "content-length":
+			values = append(values, r.Header.Values("Content-Length")...)
+			if len(values) == 0 && r.ContentLength >= 0 {
+				values = []string{strconv.FormatInt(r.ContentLength, 10)}
+			}
+		default:
+			values = append(values, r.Header.Values(name)...)
+		}
+		for i, value := range values {
+			values[i] = strings.Join(strings.Fields(value), " ")
+		}
+		b.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+	}
+	return b.String()
+}
+
+// stringToSign builds what the signature of the request is computed over
+func (sig *sigV4) stringToSign(r *http.Request, payloadHash string) string {
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		awsURIEncode(r.URL.Path, false),
+		canonicalQuery(r.URL.Query()),
+		canonicalHeaders(r, sig.signedHeaders),
+		strings.Join(sig.signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	return strings.Join([]string{
+		sigV4Algorithm,
+		sig.amzDate,
+		sig.scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+}
+
+// authenticate verifies the SigV4 signature of a request. On success it
+// replaces the request body with one that decodes aws-chunked uploads and
+// fails at the end of the body if the payload does not match what was signed,
+// so nothing read from it must be published before it is fully read.
+func (s *S3) authenticate(r *http.Request) *s3Error {
+	return s.authenticateAt(r, time.Now())
+}
+
+// authenticateAt verifies the signature of a request received at now
+func (s *S3) authenticateAt(r *http.Request, now time.Time) *s3Error {
+	var sig *sigV4
+	var err *s3Error
+	query := r.URL.Query()
+	switch {
+	case r.Header.Get("Authorization") != "":
+		sig, err = parseAuthorization(r.Header.Get("Authorization"))
+		if err == nil {
+			sig.amzDate = r.Header.Get("X-Amz-Date")
+		}
+	case query.Has("X-Amz-Signature"):
+		sig, err = parsePresigned(query)
+	default:
+		return errS3(http.StatusForbidden, "AccessDenied", "Anonymous requests are not allowed")
+	}
+	if err != nil {
+		return err
+	}
+
+	if sig.accessKey != s.accessKey {
+		return errS3(http.StatusForbidden, "InvalidAccessKeyId", "The access key ID does not exist")
+	}
+	if !contains(sig.signedHeaders, "host") {
+		return errS3(http.StatusBadRequest, "AuthorizationHeaderMalformed", "The host header must be signed")
+	}
+
+	// Reject stale requests so captured signatures cannot be replayed later
+	signedAt, perr := time.Parse(amzDateFormat, sig.amzDate)
+	if perr != nil || !strings.HasPrefix(sig.amzDate, sig.date) {
+		return errS3(http.StatusBadRequest, "AuthorizationHeaderMalformed", "Missing or invalid X-Amz-Date")
+	}
+	if sig.presigned {
+		if now.After(signedAt.Add(sig.expires)) {
+			return errS3(http.StatusForbidden, "AccessDenied", "Request has expired")
+		}
+		if signedAt.After(now.Add(maxClockSkew)) {
+			return errS3(http.StatusForbidden, "RequestTimeTooSkewed", "The request time is too far in the future")
+		}
+	} else if signedAt.Before(now.Add(-maxClockSkew)) || signedAt.After(now.Add(maxClockSkew)) {
+		return errS3(http.StatusForbidden, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large")
+	}
+
+	payloadHash := unsignedPayload
+	if !sig.presigned {
+		payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+		if payloadHash == "" {
+			return errS3(http.StatusBadRequest, "InvalidRequest", "Missing required header x-amz-content-sha256")
+		}
+	}
+
+	key := signingKey(s.secretKey, sig.date, sig.region, sig.service)
+	expected := hex.EncodeToString(hmacSHA256(key, sig.stringToSign(r, payloadHash)))
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(sig.signature)) != 1 {
+		return errS3(http.StatusForbidden, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided")
+	}
+
+	// Check the body against what was signed as it is read
+	switch payloadHash {
+	case unsignedPayload:
+	case streamingPayload, streamingPayloadTrailer, streamingUnsignedTrailer:
+		decoded, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil || decoded < 0 {
+			return errS3(http.StatusLengthRequired, "MissingContentLength", "x-amz-decoded-content-length is required for aws-chunked uploads")
+		}
+		reader := &chunkedReader{
+			r:        bufio.NewReader(r.Body),
+			trailer:  payloadHash != streamingPayload,
+			verify:   payloadHash != streamingUnsignedTrailer,
+			key:      key,
+			amzDate:  sig.amzDate,
+			scope:    sig.scope,
+			prevSig:  sig.signature,
+			expected: decoded,
+		}
+		if reader.trailer {
+			// The trailer carries a checksum of the body, named up front
+			reader.checksumName = strings.ToLower(strings.TrimSpace(r.Header.Get("X-Amz-Trailer")))
+			reader.checksum = newChecksum(reader.checksumName)
+			if reader.checksum == nil {
+				return errS3(http.StatusNotImplemented, "NotImplemented", "Unsupported x-amz-trailer "+reader.checksumName)
+			}
+		}
+		r.Body = readCloser{Reader: reader, Closer: r.Body}
+		r.ContentLength = decoded
+	default:
+		if _, err := hex.DecodeString(payloadHash); err != nil || len(payloadHash) != 64 {
+			return errS3(http.StatusBadRequest, "InvalidArgument", "Invalid x-amz-content-sha256 "+payloadHash)
+		}
+		r.Body = readCloser{
+			Reader: &hashReader{r: r.Body, hash: sha256.New(), expected: payloadHash},
+			Closer: r.Body,
+		}
+	}
+
+	return nil
+}
+
+// readCloser combines a replacement body with the original body's Close
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// hashReader fails at the end of the body if its SHA-256 does not match the
+// hash the request was signed with
+type hashReader struct {
+	r        io.Reader
+	hash     hash.Hash
+	expected string
+}
+
+func (h *hashReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(h.hash.Sum(nil)) != h.expected {
+		return n, errS3(http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided x-amz-content-sha256 header does not match what was computed")
+	}
+	return n, err
+}
+
+// chunkedReader decodes an aws-chunked body:
+//
+//	hex-size;chunk-signature=sig\r\n data \r\n ... 0;chunk-signature=sig\r\n [trailers] \r\n
+//
+// Each chunk is buffered and its signature, which chains on the previous one,
+// is checked before any of it is returned. The trailer must hold the checksum
+// named by x-amz-trailer and, if chunks are signed, a signature of its own;
+// both are checked before the end of the body is reported.
+type chunkedReader struct {
+	r            *bufio.Reader
+	trailer      bool // Trailers follow the last chunk
+	verify       bool // Chunks carry signatures
+	key          []byte
+	amzDate      string
+	scope        string
+	prevSig      string
+	expected     int64     // Decoded length the request announced
+	checksumName string    // Trailer header holding the checksum of the body
+	checksum     hash.Hash // Running checksum of the body, for trailers
+	total        int64
+	buf          []byte
+	done         bool
+	err          error
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+		c.err = c.nextChunk()
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+// nextChunk reads and verifies the next chunk into buf
+func (c *chunkedReader) nextChunk() error {
+	malformed := errS3(http.StatusBadRequest, "IncompleteBody", "Malformed aws-chunked body")
+
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return malformed
+	}
+	header, signature, _ := strings.Cut(strings.TrimRight(line, "\r\n"), ";")
+	size, err := strconv.ParseInt(header, 16, 64)
+	if err != nil || size < 0 || size > maxStreamingChunk {
+		return malformed
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return malformed
+	}
+	if size > 0 || !c.trailer {
+		if crlf, err := c.r.ReadString('\n'); err != nil || strings.TrimRight(crlf, "\r\n") != "" {
+			return malformed
+		}
+	}
+
+	if c.verify {
+		signature, ok := strings.CutPrefix(signature, "chunk-signature=")
+		if !ok {
+			return errS3(http.StatusForbidden, "SignatureDoesNotMatch", "Chunk signature is missing")
+		}
+		stringToSign := strings.Join([]string{
+			sigV4Algorithm + "-PAYLOAD",
+			c.amzDate,
+			c.scope,
+			c.prevSig,
+			emptySHA256,
+			sha256Hex(data),
+		}, "\n")
+		expected := hex.EncodeToString(hmacSHA256(c.key, stringToSign))
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) != 1 {
+			return errS3(http.StatusForbidden, "SignatureDoesNotMatch", "Chunk signature does not match")
+		}
+		c.prevSig = signature
+	}
+
+	c.total += size
+	if c.checksum != nil {
+		c.checksum.Write(data)
+	}
+	if size > 0 {
+		c.buf = data
+		return nil
+	}
+
+	if c.total != c.expected {
+		return errS3(http.StatusBadRequest, "IncompleteBody", "The decoded body does not match x-amz-decoded-content-length")
+	}
+	if c.trailer {
+		if err := c.readTrailer(); err != nil {
+			return err
+		}
+	}
+	c.done = true
+	return nil
+}
+
+// readTrailer reads the trailing headers after the last chunk up to the blank
+// line ending the body, and checks their signature and the checksum of the
+// body they carry
+func (c *chunkedReader) readTrailer() error {
+	malformed := errS3(http.StatusBadRequest, "IncompleteBody", "Malformed aws-chunked trailer")
+
+	// Every trailer but the signature is signed, as "name:value\n"
+	var signed strings.Builder
+	var checksum, signature string
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil && !(err == io.EOF && line == "") {
+			return malformed
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return malformed
+		}
+		name, value = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)
+		switch name {
+		case "x-amz-trailer-signature":
+			signature = value
+			continue
+		case c.checksumName:
+			checksum = value
+		}
+		signed.WriteString(name + ":" + value + "\n")
+	}
+
+	if c.verify {
+		stringToSign := strings.Join([]string{
+			sigV4Algorithm + "-TRAILER",
+			c.amzDate,
+			c.scope,
+			c.prevSig,
+			sha256Hex([]byte(signed.String())),
+		}, "\n")
+		expected := hex.EncodeToString(hmacSHA256(c.key, stringToSign))
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) != 1 {
+			return errS3(http.StatusForbidden, "SignatureDoesNotMatch", "Trailer signature does not match")
+		}
+	}
+
+	if checksum != base64.StdEncoding.EncodeToString(c.checksum.Sum(nil)) {
+		return errS3(http.StatusBadRequest, "BadDigest", "The "+c.checksumName+" you specified did not match the calculated checksum")
+	}
+	return nil
+}
+
+// newChecksum returns a hash computing the checksum a trailer header such as
+// x-amz-checksum-crc32c holds, or nil if the algorithm is not supported
+func newChecksum(header string) hash.Hash {
+	switch header {
+	case "x-amz-checksum-crc32":
+		return crc32.NewIEEE()
+	case "x-amz-checksum-crc32c":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case "x-amz-checksum-crc64nvme":
+		return crc64.New(crc64NVMETable)
+	case "x-amz-checksum-sha1":
+		return sha1.New()
+	case "x-amz-checksum-sha256":
+		return sha256.New()
+	}
+	return nil
+}
+
+// contains reports whether list includes value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+// api/s3_list.go
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxListKeys is the most keys and common prefixes returned in one listing
+const maxListKeys = 1000
+
+type listBucketResult struct {
+	XMLName               xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string
+	Prefix                string
+	Delimiter             string `xml:",omitempty"`
+	MaxKeys               int
+	EncodingType          string `xml:",omitempty"`
+	IsTruncated           bool
+	Marker                *string `xml:",omitempty"` // ListObjects only
+	NextMarker            string  `xml:",omitempty"`
+	KeyCount              *int    `xml:",omitempty"` // ListObjectsV2 only
+	ContinuationToken     string  `xml:",omitempty"`
+	NextContinuationToken string  `xml:",omitempty"`
+	StartAfter            string  `xml:",omitempty"`
+	Contents              []objectEntry
+	CommonPrefixes        []commonPrefix
+}
+
+type objectEntry struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+	StorageClass string
+}
+
+type commonPrefix struct {
+	Prefix string
+}
+
+// listing is a key or common prefix found by walkBucket
+type listing struct {
+	key    string
+	object *objectEntry // Nil for common prefixes
+}
+
+// listObjects handles ListObjectsV2, and the original ListObjects when the
+// list-type query parameter is not 2. Keys are listed in lexicographic order,
+// and with a delimiter, the keys sharing a prefix up to the delimiter are
+// rolled up into one common prefix.
+func (s *S3) listObjects(c *gin.Context, bucket string) {
+	if err := s.checkBucket(c, bucket); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	v2 := c.Query("list-type") == "2"
+	prefix := c.Query("prefix")
+	delimiter := c.Query("delimiter")
+	encodingType := c.Query("encoding-type")
+	if encodingType != "" && encodingType != "url" {
+		writeError(c, errS3(http.StatusBadRequest, "InvalidArgument", "Invalid encoding type "+encodingType))
+		return
+	}
+
+	maxKeys := maxListKeys
+	if v := c.Query("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(c, errS3(http.StatusBadRequest, "InvalidArgument", "Invalid max-keys "+v))
+			return
+		}
+		maxKeys = min(n, maxListKeys)
+	}
+
+	// Listing resumes after the marker
+	var marker string
+	token := c.Query("continuation-token")
+	if v2 && token != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			writeError(c, errS3(http.StatusBadRequest, "InvalidArgument", "The continuation token provided is incorrect"))
+			return
+		}
+		marker = string(decoded)
+	} else if v2 {
+		marker = c.Query("start-after")
+	} else {
+		marker = c.Query("marker")
+	}
+
+	items, err := s.walkBucket(c, bucket, prefix, delimiter)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	start := sort.Search(len(items), func(i int) bool {
+		return items[i].key > marker
+	})
+	items = items[start:]
+
+	encode := func(s string) string {
+		if encodingType == "url" {
+			return awsURIEncode(s, false)
+		}
+		return s
+	}
+
+	result := listBucketResult{
+		Name:         bucket,
+		Prefix:       encode(prefix),
+		Delimiter:    encode(delimiter),
+		MaxKeys:      maxKeys,
+		EncodingType: encodingType,
+		IsTruncated:  len(items) > maxKeys,
+	}
+	if result.IsTruncated {
+		items = items[:maxKeys]
+	}
+	for _, item := range items {
+		if item.object != nil {
+			object := *item.object
+			object.Key = encode(object.Key)
+			result.Contents = append(result.Contents, object)
+		} else {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: encode(item.key)})
+		}
+	}
+
+	var last string
+	if len(items) > 0 {
+		last = items[len(items)-1].key
+	}
+	if v2 {
+		keyCount := len(items)
+		result.KeyCount = &keyCount
+		result.ContinuationToken = token
+		result.StartAfter = encode(c.Query("start-after"))
+		if result.IsTruncated {
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+		}
+	} else {
+		marker = encode(marker)
+		result.Marker = &marker
+		if result.IsTruncated {
+			result.NextMarker = encode(last)
+		}
+	}
+
+	c.XML(http.StatusOK, result)
+}
+
+// walkBucket finds the keys in a bucket that start with prefix, sorted, with
+// keys rolled up into common prefixes at the delimiter. Only the directories
+// the prefix can match are listed, and with "/" as the delimiter, the
+// directories below the prefix are reported without being descended into.
+func (s *S3) walkBucket(c *gin.Context, bucket, prefix, delimiter string) ([]listing, *s3Error) {
+	var objects []*objectEntry
+	prefixes := make(map[string]bool)
+
+	var walk func(dir string) *s3Error
+	walk = func(dir string) *s3Error {
+		entries, err := s.client.ListDir(c.Request.Context(), objectPath(bucket, dir))
+		if code := status.Code(err); code == codes.NotFound || code == codes.FailedPrecondition {
+			// The prefix names no directory, so nothing matches it
+			return nil
+		}
+		if err != nil {
+			return toS3Error(err, errNoSuchBucket)
+		}
+
+		for _, entry := range entries {
+			key := strings.TrimPrefix(entry.Path, bucket+"/")
+			if !entry.IsDir {
+				if strings.HasPrefix(key, prefix) {
+					objects = append(objects, &objectEntry{
+						Key:          key,
+						LastModified: s3Time(entry.File.UploadDate),
+						ETag:         objectETag(entry.File),
+						Size:         entry.File.FileSize,
+						StorageClass: "STANDARD",
+					})
+				}
+				continue
+			}
+
+			key += "/"
+			switch {
+			case strings.HasPrefix(key, prefix) && delimiter == "/":
+				prefixes[key] = true
+			case strings.HasPrefix(key, prefix), strings.HasPrefix(prefix, key):
+				if err := walk(key); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	// Start from the deepest directory the prefix names
+	if err := walk(prefix[:strings.LastIndex(prefix, "/")+1]); err != nil {
+		return nil, err
+	}
+
+	var items []listing
+	for _, object := range objects {
+		if delimiter != "" {
+			if i := strings.Index(object.Key[len(prefix):], delimiter); i >= 0 {
+				prefixes[object.Key[:len(prefix)+i+len(delimiter)]] = true
+				continue
+			}
+		}
+		items = append(items, listing{key: object.Key, object: object})
+	}
+	for p := range prefixes {
+		items = append(items, listing{key: p})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].key < items[j].key
+	})
+	return items, nil
+}
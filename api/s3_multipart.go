@@ -0,0 +1,397 @@
+// api/s3_multipart.go
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	metadataPb "dfs/proto/metadata"
+)
+
+// multipartDir holds the multipart uploads in progress, one directory per
+// upload with a file naming the target object and one file per part. Its
+// name is not a valid bucket name, so it never shows up as a bucket.
+const multipartDir = ".s3-multipart"
+
+// maxPartNumber is the highest part number S3 allows
+const maxPartNumber = 10000
+
+// uploadIDPattern matches the upload IDs handed out by newID
+var uploadIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// uploadTarget returns the file naming the object an upload is for
+func uploadTarget(uploadID string) string {
+	return multipartDir + "/" + uploadID + "/target"
+}
+
+// partPath returns where a part of an upload is stored
+func partPath(uploadID string, partNumber int) string {
+	return fmt.Sprintf("%s/%s/part-%05d", multipartDir, uploadID, partNumber)
+}
+
+// partNumber parses the part number of a part file name, returning 0 for
+// other files
+func partNumber(name string) int {
+	digits, ok := strings.CutPrefix(path.Base(name), "part-")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+	Bucket   string
+	Key      string
+	UploadId string
+}
+
+// createMultipartUpload handles CreateMultipartUpload
+func (s *S3) createMultipartUpload(c *gin.Context, bucket, key string) {
+	if err := s.checkBucket(c, bucket); err != nil {
+		writeError(c, err)
+		return
+	}
+	if strings.HasSuffix(key, "/") {
+		writeError(c, errS3(http.StatusBadRequest, "InvalidArgument", "Keys ending in a slash name directories, which cannot hold data"))
+		return
+	}
+
+	uploadID := newID()
+	if _, err := s.writeObject(c, uploadTarget(uploadID), strings.NewReader(bucket+"\n"+key), ""); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	log.Printf("S3: started multipart upload %s of %s/%s", uploadID, bucket, key)
+	c.XML(http.StatusOK, initiateMultipartUploadResult{
+		Bucket:   bucket,
+		Key:      key,
+		UploadId: uploadID,
+	})
+}
+
+// readUpload returns the bucket and key an upload is for
+func (s *S3) readUpload(c *gin.Context, uploadID string) (string, string, *s3Error) {
+	if !uploadIDPattern.MatchString(uploadID) {
+		return "", "", errNoSuchUpload
+	}
+	data, err := s.client.ReadAt(c.Request.Context(), uploadTarget(uploadID), 0, 2048)
+	if err != nil {
+		return "", "", toS3Error(err, errNoSuchUpload)
+	}
+	bucket, key, _ := strings.Cut(string(data), "\n")
+	return bucket, key, nil
+}
+
+// checkUpload reports an error unless the uploadId query parameter names an
+// upload of bucket/key, and returns the upload ID
+func (s *S3) checkUpload(c *gin.Context, bucket, key string) (string, *s3Error) {
+	uploadID := c.Query("uploadId")
+	uploadBucket, uploadKey, err := s.readUpload(c, uploadID)
+	if err != nil {
+		return "", err
+	}
+	if uploadBucket != bucket || uploadKey != key {
+		return "", errNoSuchUpload
+	}
+	return uploadID, nil
+}
+
+// uploadPart handles UploadPart. Uploading a part number again replaces the
+// part.
+func (s *S3) uploadPart(c *gin.Context, bucket, key string) {
+	if c.GetHeader("X-Amz-Copy-Source") != "" {
+		writeError(c, errS3(http.StatusNotImplemented, "NotImplemented", "UploadPartCopy is not supported"))
+		return
+	}
+
+	number, err := strconv.Atoi(c.Query("partNumber"))
+	if err != nil || number < 1 || number > maxPartNumber {
+		writeError(c, errS3(http.StatusBadRequest, "InvalidArgument", "Part number must be an integer between 1 and 10000"))
+		return
+	}
+
+	uploadID, s3Err := s.checkUpload(c, bucket, key)
+	if s3Err != nil {
+		writeError(c, s3Err)
+		return
+	}
+
+	info, s3Err := s.writeObject(c, partPath(uploadID, number), c.Request.Body, c.GetHeader("Content-MD5"))
+	if s3Err != nil {
+		writeError(c, s3Err)
+		return
+	}
+
+	c.Header("ETag", objectETag(info))
+	c.Status(http.StatusOK)
+}
+
+// listUploadParts returns the parts uploaded so far, by part number
+func (s *S3) listUploadParts(c *gin.Context, uploadID string) (map[int]*metadataPb.FileInfo, *s3Error) {
+	entries, err := s.client.ListDir(c.Request.Context(), multipartDir+"/"+uploadID)
+	if err != nil {
+		return nil, toS3Error(err, errNoSuchUpload)
+	}
+
+	parts := make(map[int]*metadataPb.FileInfo)
+	for _, entry := range entries {
+		if n := partNumber(entry.Path); n > 0 && !entry.IsDir {
+			parts[n] = entry.File
+		}
+	}
+	return parts, nil
+}
+
+// completeMultipartUploadRequest is the body of a CompleteMultipartUpload
+// request
+type completeMultipartUploadRequest struct {
+	Parts []struct {
+		PartNumber int
+		ETag       string
+	} `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+	Location string
+	Bucket   string
+	Key      string
+	ETag     string
+}
+
+// completeMultipartUpload handles CompleteMultipartUpload, concatenating the
+// listed parts into the object and discarding the upload
+func (s *S3) completeMultipartUpload(c *gin.Context, bucket, key string) {
+	uploadID, s3Err := s.checkUpload(c, bucket, key)
+	if s3Err != nil {
+		writeError(c, s3Err)
+		return
+	}
+
+	var req completeMultipartUploadRequest
+	if err := xml.NewDecoder(io.LimitReader(c.Request.Body, 2<<20)).Decode(&req); err != nil || len(req.Parts) == 0 {
+		writeError(c, errMalformedXML)
+		return
+	}
+
+	parts, s3Err := s.listUploadParts(c, uploadID)
+	if s3Err != nil {
+		writeError(c, s3Err)
+		return
+	}
+
+	// Step 1: Check that the listed parts were uploaded, in ascending order
+	for i, part := range req.Parts {
+		if i > 0 && part.PartNumber <= req.Parts[i-1].PartNumber {
+			writeError(c, errS3(http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order"))
+			return
+		}
+		info, exists := parts[part.PartNumber]
+		if !exists || strings.Trim(part.ETag, `"`) != strings.Trim(objectETag(info), `"`) {
+			writeError(c, errS3(http.StatusBadRequest, "InvalidPart", fmt.Sprintf("Part %d was not uploaded or its entity tag does not match", part.PartNumber)))
+			return
+		}
+	}
+
+	// Step 2: Copy the parts into the object
+	pr, pw := io.Pipe()
+	go func() {
+		for _, part := range req.Parts {
+			file, err := s.client.OpenVersion(c.Request.Context(), partPath(uploadID, part.PartNumber), parts[part.PartNumber].Version)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			_, err = io.Copy(pw, file)
+			file.Close()
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+	info, s3Err := s.writeObject(c, objectPath(bucket, key), pr, "")
+	pr.Close()
+	if s3Err != nil {
+		writeError(c, s3Err)
+		return
+	}
+
+	// Step 3: Discard the parts
+	if err := s.client.DeleteFile(c.Request.Context(), multipartDir+"/"+uploadID, true); err != nil {
+		log.Printf("S3: failed to clean up multipart upload %s: %v", uploadID, err)
+	}
+
+	log.Printf("S3: completed multipart upload %s of %s/%s (%d parts, %d bytes)", uploadID, bucket, key, len(req.Parts), info.FileSize)
+	c.XML(http.StatusOK, completeMultipartUploadResult{
+		Location: "/" + objectPath(bucket, key),
+		Bucket:   bucket,
+		Key:      key,
+		ETag:     objectETag(info),
+	})
+}
+
+// abortMultipartUpload handles AbortMultipartUpload, discarding the parts
+func (s *S3) abortMultipartUpload(c *gin.Context, bucket, key string) {
+	uploadID, s3Err := s.checkUpload(c, bucket, key)
+	if s3Err != nil {
+		writeError(c, s3Err)
+		return
+	}
+
+	if err := s.client.DeleteFile(c.Request.Context(), multipartDir+"/"+uploadID, true); err != nil {
+		writeError(c, toS3Error(err, errNoSuchUpload))
+		return
+	}
+
+	log.Printf("S3: aborted multipart upload %s of %s/%s", uploadID, bucket, key)
+	c.Status(http.StatusNoContent)
+}
+
+type listPartsResult struct {
+	XMLName              xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListPartsResult"`
+	Bucket               string
+	Key                  string
+	UploadId             string
+	PartNumberMarker     int
+	NextPartNumberMarker int
+	MaxParts             int
+	IsTruncated          bool
+	Parts                []partEntry `xml:"Part"`
+}
+
+type partEntry struct {
+	PartNumber   int
+	LastModified string
+	ETag         string
+	Size         int64
+}
+
+// listParts handles ListParts
+func (s *S3) listParts(c *gin.Context, bucket, key string) {
+	uploadID, s3Err := s.checkUpload(c, bucket, key)
+	if s3Err != nil {
+		writeError(c, s3Err)
+		return
+	}
+
+	maxParts := maxListKeys
+	if v := c.Query("max-parts"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(c, errS3(http.StatusBadRequest, "InvalidArgument", "Invalid max-parts "+v))
+			return
+		}
+		maxParts = min(n, maxListKeys)
+	}
+	marker, _ := strconv.Atoi(c.Query("part-number-marker"))
+
+	parts, s3Err := s.listUploadParts(c, uploadID)
+	if s3Err != nil {
+		writeError(c, s3Err)
+		return
+	}
+
+	var numbers []int
+	for n := range parts {
+		if n > marker {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+
+	result := listPartsResult{
+		Bucket:           bucket,
+		Key:              key,
+		UploadId:         uploadID,
+		PartNumberMarker: marker,
+		MaxParts:         maxParts,
+		IsTruncated:      len(numbers) > maxParts,
+	}
+	if result.IsTruncated {
+		numbers = numbers[:maxParts]
+	}
+	for _, n := range numbers {
+		result.Parts = append(result.Parts, partEntry{
+			PartNumber:   n,
+			LastModified: s3Time(parts[n].UploadDate),
+			ETag:         objectETag(parts[n]),
+			Size:         parts[n].FileSize,
+		})
+		result.NextPartNumberMarker = n
+	}
+	c.XML(http.StatusOK, result)
+}
+
+type listMultipartUploadsResult struct {
+	XMLName     xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListMultipartUploadsResult"`
+	Bucket      string
+	Prefix      string
+	MaxUploads  int
+	IsTruncated bool
+	Uploads     []uploadEntry `xml:"Upload"`
+}
+
+type uploadEntry struct {
+	Key       string
+	UploadId  string
+	Initiated string
+}
+
+// listMultipartUploads handles ListMultipartUploads, listing every upload in
+// progress in a bucket whose key starts with the prefix query parameter
+func (s *S3) listMultipartUploads(c *gin.Context, bucket string) {
+	if err := s.checkBucket(c, bucket); err != nil {
+		writeError(c, err)
+		return
+	}
+	prefix := c.Query("prefix")
+
+	entries, err := s.client.ListDir(c.Request.Context(), multipartDir)
+	if err != nil && status.Code(err) != codes.NotFound {
+		writeError(c, toS3Error(err, errNoSuchUpload))
+		return
+	}
+
+	result := listMultipartUploadsResult{
+		Bucket:     bucket,
+		Prefix:     prefix,
+		MaxUploads: maxListKeys,
+	}
+	for _, entry := range entries {
+		uploadID := path.Base(entry.Path)
+		uploadBucket, key, s3Err := s.readUpload(c, uploadID)
+		if s3Err != nil || uploadBucket != bucket || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		result.Uploads = append(result.Uploads, uploadEntry{
+			Key:       key,
+			UploadId:  uploadID,
+			Initiated: s3Time(entry.Created),
+		})
+	}
+	sort.Slice(result.Uploads, func(i, j int) bool {
+		return result.Uploads[i].Key < result.Uploads[j].Key
+	})
+	c.XML(http.StatusOK, result)
+}
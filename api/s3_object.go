@@ -0,0 +1,306 @@
+// api/s3_object.go
+
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	metadataPb "dfs/proto/metadata"
+)
+
+// emptyETag is the MD5 of no data, the entity tag of directory markers
+const emptyETag = `"d41d8cd98f00b204e9800998ecf8427e"`
+
+// maxDeleteObjects is the most keys one DeleteObjects request may name
+const maxDeleteObjects = 1000
+
+// responseHeaders are the query parameters of a GetObject request that
+// override response headers, mostly used in presigned URLs
+var responseHeaders = map[string]string{
+	"response-cache-control":       "Cache-Control",
+	"response-content-disposition": "Content-Disposition",
+	"response-content-encoding":    "Content-Encoding",
+	"response-content-language":    "Content-Language",
+	"response-content-type":        "Content-Type",
+	"response-expires":             "Expires",
+}
+
+// writeObject stores body at p as a new file, or a new version of an existing
+// one, and returns the stored file. If contentMD5 is set, the file is only
+// published when the body matches it.
+func (s *S3) writeObject(c *gin.Context, p string, body io.Reader, contentMD5 string) (*metadataPb.FileInfo, *s3Error) {
+	var expected []byte
+	if contentMD5 != "" {
+		var err error
+		expected, err = base64.StdEncoding.DecodeString(contentMD5)
+		if err != nil || len(expected) != md5.Size {
+			return nil, errS3(http.StatusBadRequest, "InvalidDigest", "The Content-MD5 you specified was invalid")
+		}
+	}
+
+	file, err := s.client.Create(c.Request.Context(), p)
+	if err != nil {
+		return nil, toS3Error(err, errNoSuchKey)
+	}
+
+	// An upload that is not closed is abandoned and expires on the metadata
+	// service
+	hash := md5.New()
+	if _, err := io.Copy(file, io.TeeReader(body, hash)); err != nil {
+		return nil, toS3Error(err, errNoSuchKey)
+	}
+	if expected != nil && !bytes.Equal(hash.Sum(nil), expected) {
+		return nil, errS3(http.StatusBadRequest, "BadDigest", "The Content-MD5 you specified did not match what was received")
+	}
+	if err := file.Close(); err != nil {
+		return nil, toS3Error(err, errNoSuchKey)
+	}
+
+	entry, err := s.client.Stat(c.Request.Context(), p)
+	if err != nil {
+		return nil, toS3Error(err, errNoSuchKey)
+	}
+	if entry.IsDir {
+		return nil, errS3(http.StatusConflict, "OperationAborted", "A directory was created at "+p+" concurrently")
+	}
+	return entry.File, nil
+}
+
+// putObject handles PutObject. A key ending in a slash with no data creates a
+// directory, which is how S3 tools make folders.
+func (s *S3) putObject(c *gin.Context, bucket, key string) {
+	if c.GetHeader("X-Amz-Copy-Source") != "" {
+		writeError(c, errS3(http.StatusNotImplemented, "NotImplemented", "CopyObject is not supported"))
+		return
+	}
+	if err := s.checkBucket(c, bucket); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	if strings.HasSuffix(key, "/") {
+		if c.Request.ContentLength > 0 {
+			writeError(c, errS3(http.StatusBadRequest, "InvalidArgument", "Keys ending in a slash name directories, which cannot hold data"))
+			return
+		}
+		if err := s.client.Mkdir(c.Request.Context(), objectPath(bucket, key), true); err != nil {
+			writeError(c, toS3Error(err, errNoSuchBucket))
+			return
+		}
+		c.Header("ETag", emptyETag)
+		c.Status(http.StatusOK)
+		return
+	}
+
+	info, err := s.writeObject(c, objectPath(bucket, key), c.Request.Body, c.GetHeader("Content-MD5"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	log.Printf("S3: put %s/%s (%d bytes)", bucket, key, info.FileSize)
+	c.Header("ETag", objectETag(info))
+	c.Header("x-amz-version-id", strconv.FormatInt(info.Version, 10))
+	c.Status(http.StatusOK)
+}
+
+// getObject handles GetObject and HeadObject, including range requests and
+// conditional requests. The versionId query parameter selects an older
+// version of the file.
+func (s *S3) getObject(c *gin.Context, bucket, key string) {
+	if err := s.checkBucket(c, bucket); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	var version int64
+	if v := c.Query("versionId"); v != "" && v != "null" {
+		var err error
+		version, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || version < 1 {
+			writeError(c, errS3(http.StatusBadRequest, "InvalidArgument", "Invalid version id "+v))
+			return
+		}
+	}
+
+	p := objectPath(bucket, key)
+	info, err := s.objectInfo(c, p, key, version)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	for param, header := range responseHeaders {
+		if value := c.Query(param); value != "" {
+			c.Header(header, value)
+		}
+	}
+	if c.Writer.Header().Get("Content-Type") == "" {
+		contentType := mime.TypeByExtension(path.Ext(key))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		c.Header("Content-Type", contentType)
+	}
+
+	// Directory markers are empty objects
+	if info == nil {
+		c.Header("ETag", emptyETag)
+		http.ServeContent(c.Writer, c.Request, "", time.Time{}, bytes.NewReader(nil))
+		return
+	}
+
+	modTime, timeErr := time.ParseInLocation("2006-01-02 15:04:05", info.UploadDate, time.Local)
+	if timeErr != nil {
+		modTime = time.Time{}
+	}
+
+	file, openErr := s.client.OpenVersion(c.Request.Context(), p, info.Version)
+	if openErr != nil {
+		writeError(c, toS3Error(openErr, errNoSuchKey))
+		return
+	}
+	defer file.Close()
+
+	c.Header("ETag", objectETag(info))
+	c.Header("x-amz-version-id", strconv.FormatInt(info.Version, 10))
+	http.ServeContent(c.Writer, c.Request, "", modTime, file)
+}
+
+// objectInfo describes a version of the object at p; version 0 is the
+// current one. A directory is returned as nil when the key ends in a slash and
+// is missing otherwise.
+func (s *S3) objectInfo(c *gin.Context, p, key string, version int64) (*metadataPb.FileInfo, *s3Error) {
+	if version == 0 {
+		entry, err := s.client.Stat(c.Request.Context(), p)
+		if err != nil {
+			return nil, toS3Error(err, errNoSuchKey)
+		}
+		if entry.IsDir != strings.HasSuffix(key, "/") {
+			return nil, errNoSuchKey
+		}
+		return entry.File, nil
+	}
+
+	versions, err := s.client.ListVersions(c.Request.Context(), p)
+	if err != nil {
+		return nil, toS3Error(err, errNoSuchKey)
+	}
+	for _, info := range versions {
+		if info.Version == version {
+			return info, nil
+		}
+	}
+	return nil, errS3(http.StatusNotFound, "NoSuchVersion", "The specified version does not exist")
+}
+
+// deleteObject handles DeleteObject. Deleting a missing key succeeds, as it
+// does in S3.
+func (s *S3) deleteObject(c *gin.Context, bucket, key string) {
+	if err := s.checkBucket(c, bucket); err != nil {
+		writeError(c, err)
+		return
+	}
+	if err := s.removeObject(c, bucket, key); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// removeObject deletes an object and then the directories above it that it
+// leaves empty, since S3 has no directories that outlive their objects. A
+// key ending in a slash deletes an empty directory.
+func (s *S3) removeObject(c *gin.Context, bucket, key string) *s3Error {
+	err := s.client.DeleteFile(c.Request.Context(), objectPath(bucket, key), false)
+	switch status.Code(err) {
+	case codes.OK:
+		log.Printf("S3: deleted %s/%s", bucket, key)
+	case codes.NotFound:
+		return nil
+	case codes.FailedPrecondition:
+		// A directory with objects in it stays until they are deleted
+		return nil
+	default:
+		return toS3Error(err, errNoSuchKey)
+	}
+
+	for dir := path.Dir(strings.TrimSuffix(key, "/")); dir != "."; dir = path.Dir(dir) {
+		if err := s.client.DeleteFile(c.Request.Context(), objectPath(bucket, dir), false); err != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// deleteRequest is the body of a DeleteObjects request
+type deleteRequest struct {
+	Quiet   bool
+	Objects []struct {
+		Key string
+	} `xml:"Object"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name        `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	Deleted []deletedObject `xml:"Deleted"`
+	Errors  []deleteError   `xml:"Error"`
+}
+
+type deletedObject struct {
+	Key string
+}
+
+type deleteError struct {
+	Key     string
+	Code    string
+	Message string
+}
+
+// deleteObjects handles DeleteObjects, deleting up to 1000 keys in one
+// request. Each key succeeds or fails on its own.
+func (s *S3) deleteObjects(c *gin.Context, bucket string) {
+	if err := s.checkBucket(c, bucket); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	var req deleteRequest
+	if err := xml.NewDecoder(io.LimitReader(c.Request.Body, 2<<20)).Decode(&req); err != nil || len(req.Objects) == 0 {
+		writeError(c, errMalformedXML)
+		return
+	}
+	if len(req.Objects) > maxDeleteObjects {
+		writeError(c, errS3(http.StatusBadRequest, "MalformedXML", "A DeleteObjects request may name at most 1000 keys"))
+		return
+	}
+
+	result := deleteResult{}
+	for _, object := range req.Objects {
+		err := validateKey(object.Key)
+		if err == nil {
+			err = s.removeObject(c, bucket, object.Key)
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, deleteError{Key: object.Key, Code: err.code, Message: err.message})
+		} else if !req.Quiet {
+			result.Deleted = append(result.Deleted, deletedObject{Key: object.Key})
+		}
+	}
+	c.XML(http.StatusOK, result)
+}
@@ -0,0 +1,37 @@
+// api/s3_test.go
+
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	metadataPb "dfs/proto/metadata"
+)
+
+func TestObjectETag(t *testing.T) {
+	tests := []struct {
+		name string
+		info *metadataPb.FileInfo
+		want *regexp.Regexp
+	}{
+		{
+			name: "recorded MD5",
+			info: &metadataPb.FileInfo{FileName: "b/k", Version: 1, NumChunks: 1, ContentMd5: "9e107d9d372bb6826bd81d3542a419d6"},
+			want: regexp.MustCompile(`^"9e107d9d372bb6826bd81d3542a419d6"$`),
+		},
+		{
+			name: "no recorded MD5",
+			info: &metadataPb.FileInfo{FileName: "b/k", Version: 1, NumChunks: 3},
+			want: regexp.MustCompile(`^"[0-9a-f]{32}-3"$`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := objectETag(tt.info); !tt.want.MatchString(got) {
+				t.Errorf("objectETag = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+// api/webdav.go
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/webdav"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"dfs/clientlib"
+	metadataPb "dfs/proto/metadata"
+)
+
+// webdavMethods are the HTTP methods the WebDAV handler serves
+var webdavMethods = []string{
+	http.MethodOptions, http.MethodGet, http.MethodHead, http.MethodPost,
+	http.MethodPut, http.MethodDelete, "PROPFIND", "PROPPATCH", "MKCOL",
+	"COPY", "MOVE", "LOCK", "UNLOCK",
+}
+
+// NewWebDAV returns a WebDAV handler serving the file system under prefix.
+// Locks are held in memory, so they only coordinate clients of the same API
+// server.
+func NewWebDAV(client *clientlib.Client, prefix string) *webdav.Handler {
+	return &webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: &davFS{client: client},
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(r *http.Request, err error) {
+			if err != nil {
+				log.Printf("WebDAV: %s %s failed: %v", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+}
+
+// transferKey is the context key of a request's *transfer
+type transferKey struct{}
+
+// transfer records the first error reading the data a request copies into a
+// file, from the request body for PUT or from the source file for COPY
+type transfer struct {
+	mu  sync.Mutex
+	err error
+}
+
+// fail records a read error other than io.EOF
+func (t *transfer) fail(err error) {
+	if err == nil || err == io.EOF {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+func (t *transfer) failed() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// trackedBody is a request body that records read errors
+type trackedBody struct {
+	io.ReadCloser
+	transfer *transfer
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.transfer.fail(err)
+	return n, err
+}
+
+// serveWebDAV serves a WebDAV request. The webdav package closes the file it
+// writes to even when copying into it failed, so reads are tracked to keep a
+// partial file from being published.
+func serveWebDAV(handler *webdav.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t := &transfer{}
+		c.Request.Body = &trackedBody{ReadCloser: c.Request.Body, transfer: t}
+		ctx := context.WithValue(c.Request.Context(), transferKey{}, t)
+		handler.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+	}
+}
+
+// davError converts the error of a failed cluster operation into the os
+// errors the webdav package maps to HTTP statuses
+func davError(op, name string, err error) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	case codes.AlreadyExists:
+		return &os.PathError{Op: op, Path: name, Err: os.ErrExist}
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.PermissionDenied:
+		return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+	default:
+		return err
+	}
+}
+
+// davFS implements webdav.FileSystem on top of the client library
+type davFS struct {
+	client *clientlib.Client
+}
+
+// Mkdir creates a directory whose parent must exist
+func (d *davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	if err := d.client.Mkdir(ctx, name, false); err != nil {
+		return davError("mkdir", name, err)
+	}
+	return nil
+}
+
+// OpenFile opens a file or directory for reading, or a file for writing.
+// Files can only be written whole: a write creates a new version of the file,
+// published when it is closed.
+func (d *davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		return d.create(ctx, name, flag)
+	}
+
+	entry, err := d.client.Stat(ctx, name)
+	if err != nil {
+		return nil, davError("open", name, err)
+	}
+	info := newDavFileInfo(entry)
+	if entry.IsDir {
+		return &davDir{client: d.client, ctx: ctx, name: name, info: info}, nil
+	}
+
+	file, err := d.client.OpenVersion(ctx, name, entry.File.Version)
+	if err != nil {
+		return nil, davError("open", name, err)
+	}
+	t, _ := ctx.Value(transferKey{}).(*transfer)
+	return &davReader{ReadSeekCloser: file, info: info, transfer: t}, nil
+}
+
+// create starts writing a file, which must be opened with O_TRUNC in a
+// directory that exists
+func (d *davFS) create(ctx context.Context, name string, flag int) (webdav.File, error) {
+	if flag&os.O_TRUNC == 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: errors.ErrUnsupported}
+	}
+
+	parent, err := d.client.Stat(ctx, path.Dir(name))
+	if err != nil {
+		return nil, davError("open", name, err)
+	}
+	if !parent.IsDir {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	if flag&os.O_EXCL != 0 {
+		if _, err := d.client.Stat(ctx, name); err == nil {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+		}
+	}
+
+	file, err := d.client.Create(ctx, name)
+	if err != nil {
+		return nil, davError("open", name, err)
+	}
+	t, _ := ctx.Value(transferKey{}).(*transfer)
+	return &davWriter{
+		WriteCloser: file,
+		name:        name,
+		transfer:    t,
+		modTime:     time.Now(),
+	}, nil
+}
+
+// RemoveAll deletes a file or a directory with everything in it. A missing
+// name is not an error.
+func (d *davFS) RemoveAll(ctx context.Context, name string) error {
+	if path.Clean(name) == "/" {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrPermission}
+	}
+	err := d.client.DeleteFile(ctx, name, true)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return davError("remove", name, err)
+	}
+	return nil
+}
+
+// Rename atomically moves a file or directory. The webdav package deletes the
+// destination first when the client asked to overwrite it.
+func (d *davFS) Rename(ctx context.Context, oldName, newName string) error {
+	if err := d.client.Rename(ctx, oldName, newName, false); err != nil {
+		return davError("rename", oldName, err)
+	}
+	return nil
+}
+
+// Stat describes a file or directory
+func (d *davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	entry, err := d.client.Stat(ctx, name)
+	if err != nil {
+		return nil, davError("stat", name, err)
+	}
+	return newDavFileInfo(entry), nil
+}
+
+// davFileInfo describes a file or directory. It implements the webdav
+// package's ContentTyper and ETager, so listing a directory does not read
+// every file in it.
+type davFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+	version int64
+}
+
+func newDavFileInfo(entry *metadataPb.DirEntry) *davFileInfo {
+	modTime, err := time.ParseInLocation("2006-01-02 15:04:05", entry.Created, time.Local)
+	if err != nil {
+		modTime = time.Time{}
+	}
+	info := &davFileInfo{
+		name:    path.Base("/" + entry.Path),
+		modTime: modTime,
+		isDir:   entry.IsDir,
+	}
+	if entry.File != nil {
+		info.size = entry.File.FileSize
+		info.version = entry.File.Version
+	}
+	return info
+}
+
+func (i *davFileInfo) Name() string       { return i.name }
+func (i *davFileInfo) Size() int64        { return i.size }
+func (i *davFileInfo) ModTime() time.Time { return i.modTime }
+func (i *davFileInfo) IsDir() bool        { return i.isDir }
+func (i *davFileInfo) Sys() any           { return nil }
+
+func (i *davFileInfo) Mode() fs.FileMode {
+	if i.isDir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+// ContentType guesses the content type from the file extension
+func (i *davFileInfo) ContentType(ctx context.Context) (string, error) {
+	if contentType := mime.TypeByExtension(path.Ext(i.name)); contentType != "" {
+		return contentType, nil
+	}
+	return "application/octet-stream", nil
+}
+
+// ETag identifies the version of a file. Version numbers restart when a file
+// is deleted and recreated, so the upload time and size are included too.
+func (i *davFileInfo) ETag(ctx context.Context) (string, error) {
+	return fmt.Sprintf(`"%x-%x-%x"`, i.modTime.Unix(), i.version, i.size), nil
+}
+
+// davReader is a file opened for reading
+type davReader struct {
+	io.ReadSeekCloser
+	info     *davFileInfo
+	transfer *transfer // Nil outside of serveWebDAV
+}
+
+func (r *davReader) Read(p []byte) (int, error) {
+	n, err := r.ReadSeekCloser.Read(p)
+	if r.transfer != nil {
+		r.transfer.fail(err)
+	}
+	return n, err
+}
+
+func (r *davReader) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: r.info.name, Err: errors.New("not a directory")}
+}
+
+func (r *davReader) Stat() (fs.FileInfo, error) {
+	return r.info, nil
+}
+
+func (r *davReader) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: r.info.name, Err: os.ErrPermission}
+}
+
+// davWriter is a file opened for writing
+type davWriter struct {
+	io.WriteCloser
+	name     string
+	transfer *transfer // Nil outside of serveWebDAV
+	written  int64
+	err      error // First write error
+	modTime  time.Time
+}
+
+func (w *davWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	w.written += int64(n)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// Close publishes the file, unless writing it or reading the data copied into
+// it failed. An unpublished upload expires on the metadata service.
+func (w *davWriter) Close() error {
+	if w.err != nil {
+		return fmt.Errorf("failed to write %s: %v", w.name, w.err)
+	}
+	if w.transfer != nil {
+		if err := w.transfer.failed(); err != nil {
+			return fmt.Errorf("failed to write %s: reading its contents failed: %v", w.name, err)
+		}
+	}
+	if err := w.WriteCloser.Close(); err != nil {
+		return davError("close", w.name, err)
+	}
+	log.Printf("WebDAV: wrote %s (%d bytes)", w.name, w.written)
+	return nil
+}
+
+func (w *davWriter) Read(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: w.name, Err: os.ErrPermission}
+}
+
+func (w *davWriter) Seek(offset int64, whence int) (int64, error) {
+	return 0, &os.PathError{Op: "seek", Path: w.name, Err: errors.ErrUnsupported}
+}
+
+func (w *davWriter) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: w.name, Err: errors.New("not a directory")}
+}
+
+func (w *davWriter) Stat() (fs.FileInfo, error) {
+	return &davFileInfo{name: path.Base(w.name), size: w.written, modTime: w.modTime}, nil
+}
+
+// davDir is an open directory. Its entries are listed on the first Readdir.
+type davDir struct {
+	client  *clientlib.Client
+	ctx     context.Context
+	name    string
+	info    *davFileInfo
+	entries []fs.FileInfo
+	listed  bool
+}
+
+// Readdir returns the next count entries, or all remaining entries if count
+// is not positive
+func (d *davDir) Readdir(count int) ([]fs.FileInfo, error) {
+	if !d.listed {
+		entries, err := d.client.ListDir(d.ctx, d.name)
+		if err != nil {
+			return nil, davError("readdir", d.name, err)
+		}
+		for _, entry := range entries {
+			d.entries = append(d.entries, newDavFileInfo(entry))
+		}
+		d.listed = true
+	}
+
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+func (d *davDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *davDir) Close() error {
+	return nil
+}
+
+func (d *davDir) Read(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *davDir) Seek(offset int64, whence int) (int64, error) {
+	return 0, &os.PathError{Op: "seek", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *davDir) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: d.name, Err: errors.New("is a directory")}
+}
@@ -0,0 +1,122 @@
+// api/webdav_test.go
+
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// fakeUpload is a file being written that records whether it was published
+type fakeUpload struct {
+	strings.Builder
+	published bool
+}
+
+func (u *fakeUpload) Close() error {
+	u.published = true
+	return nil
+}
+
+// failingWriter is an upload whose writes fail
+type failingWriter struct {
+	fakeUpload
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("storage node unreachable")
+}
+
+func TestDavWriterClose(t *testing.T) {
+	errBroken := errors.New("connection reset")
+
+	tests := []struct {
+		name      string
+		body      io.Reader // Request body copied into the file
+		failWrite bool
+		tracked   bool // Reads of the body are tracked, as in serveWebDAV
+		published bool
+	}{
+		{name: "complete body", body: strings.NewReader("hello"), tracked: true, published: true},
+		{name: "body read fails", body: io.MultiReader(strings.NewReader("hel"), iotest.ErrReader(errBroken)), tracked: true},
+		{name: "write fails", body: strings.NewReader("hello"), failWrite: true, tracked: true},
+		{name: "outside of serveWebDAV", body: strings.NewReader("hello"), published: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var upload *fakeUpload
+			w := &davWriter{name: "/file.txt"}
+			if tt.failWrite {
+				failing := &failingWriter{}
+				upload, w.WriteCloser = &failing.fakeUpload, failing
+			} else {
+				upload = &fakeUpload{}
+				w.WriteCloser = upload
+			}
+
+			body := io.NopCloser(tt.body)
+			if tt.tracked {
+				w.transfer = &transfer{}
+				body = &trackedBody{ReadCloser: body, transfer: w.transfer}
+			}
+
+			// Copy the body like the webdav package does, ignoring the
+			// error, then close the file regardless
+			io.Copy(w, body)
+			err := w.Close()
+
+			if (err == nil) != tt.published {
+				t.Errorf("Close error = %v, want published %v", err, tt.published)
+			}
+			if upload.published != tt.published {
+				t.Errorf("published = %v, want %v", upload.published, tt.published)
+			}
+		})
+	}
+}
+
+func TestDavDirReaddir(t *testing.T) {
+	newDir := func() *davDir {
+		d := &davDir{name: "/dir", listed: true}
+		for _, name := range []string{"a", "b", "c", "d", "e"} {
+			d.entries = append(d.entries, &davFileInfo{name: name})
+		}
+		return d
+	}
+	names := func(infos []fs.FileInfo) string {
+		var names []string
+		for _, info := range infos {
+			names = append(names, info.Name())
+		}
+		return strings.Join(names, ",")
+	}
+
+	// Paging returns every entry once, then io.EOF
+	d := newDir()
+	for _, want := range []string{"a,b", "c,d", "e"} {
+		infos, err := d.Readdir(2)
+		if err != nil || names(infos) != want {
+			t.Fatalf("Readdir(2) = %q, %v, want %q", names(infos), err, want)
+		}
+	}
+	if infos, err := d.Readdir(2); err != io.EOF || len(infos) != 0 {
+		t.Errorf("Readdir(2) at the end = %q, %v, want io.EOF", names(infos), err)
+	}
+
+	// A count that is not positive returns the rest without io.EOF
+	d = newDir()
+	if infos, err := d.Readdir(1); err != nil || names(infos) != "a" {
+		t.Fatalf("Readdir(1) = %q, %v, want %q", names(infos), err, "a")
+	}
+	if infos, err := d.Readdir(0); err != nil || names(infos) != "b,c,d,e" {
+		t.Errorf("Readdir(0) = %q, %v, want %q", names(infos), err, "b,c,d,e")
+	}
+	if infos, err := d.Readdir(-1); err != nil || len(infos) != 0 {
+		t.Errorf("Readdir(-1) at the end = %q, %v, want nothing", names(infos), err)
+	}
+}
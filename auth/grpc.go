@@ -0,0 +1,147 @@
+// auth/grpc.go
+
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// header is the gRPC metadata key tokens are sent in
+const header = "authorization"
+
+// tokenCredentials sends a bearer token with every RPC
+type tokenCredentials struct {
+	token string
+}
+
+// Credentials returns per-RPC credentials that send token with every call, or
+// the token set on the call's context with WithToken. Calls without either
+// are sent unauthenticated.
+func Credentials(token string) credentials.PerRPCCredentials {
+	return tokenCredentials{token: token}
+}
+
+// GetRequestMetadata adds the token to the outgoing metadata of a call
+func (c tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token := c.token
+	if ctxToken, ok := TokenFromContext(ctx); ok {
+		token = ctxToken
+	}
+	if token == "" {
+		return nil, nil
+	}
+	return map[string]string{header: "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity allows tokens over plaintext connections, which
+// the cluster uses between its nodes. Run it on a trusted network.
+func (c tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// IncomingToken returns the bearer token a gRPC call was made with
+func IncomingToken(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	values := md.Get(header)
+	if len(values) == 0 {
+		return "", false
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	return strings.TrimSpace(token), ok && token != ""
+}
+
+// AuthFunc resolves a token to the identity it belongs to. It fails with
+// Unauthenticated for unknown tokens.
+type AuthFunc func(ctx context.Context, token string) (*Identity, error)
+
+// Rules says who may call the methods of a server. Entries are full method
+// names, such as "/metadata.MetadataService/Heartbeat", or service names
+// followed by a slash to cover every method of the service. Every other
+// method is open to all authenticated callers.
+type Rules struct {
+	Public   []string // Callable without a token
+	Internal []string // Only callable with the cluster token
+}
+
+// matches reports whether method is covered by one of the entries
+func matches(entries []string, method string) bool {
+	for _, entry := range entries {
+		if entry == method || (strings.HasSuffix(entry, "/") && strings.HasPrefix(method, entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize authenticates the caller of method and returns a context
+// carrying its identity
+func authorize(ctx context.Context, method string, authenticate AuthFunc, rules Rules) (context.Context, error) {
+	if matches(rules.Public, method) {
+		return ctx, nil
+	}
+
+	token, ok := IncomingToken(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "A bearer token is required")
+	}
+	id, err := authenticate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !id.Cluster && matches(rules.Internal, method) {
+		return nil, status.Errorf(codes.PermissionDenied, "%s is reserved for cluster nodes", method)
+	}
+	return NewContext(ctx, id), nil
+}
+
+// UnaryServerInterceptor authenticates unary calls
+func UnaryServerInterceptor(authenticate AuthFunc, rules Rules) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authorize(ctx, info.FullMethod, authenticate, rules)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor authenticates streaming calls
+func StreamServerInterceptor(authenticate AuthFunc, rules Rules) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authorize(ss.Context(), info.FullMethod, authenticate, rules)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream is a server stream with the caller's identity in its context
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context carrying the caller's identity
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// ServerOptions returns the options installing both interceptors on a gRPC
+// server
+func ServerOptions(authenticate AuthFunc, rules Rules) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(authenticate, rules)),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(authenticate, rules)),
+	}
+}
@@ -3,27 +3,89 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"dfs/auth"
 	"dfs/clientlib"
+	metadataPb "dfs/proto/metadata"
 )
 
 func main() {
-	operation := flag.String("op", "", "Operation to perform: upload/download/list")
-	fileName := flag.String("file", "", "File name (required for upload/download)")
+	operation := flag.String("op", "", "Operation to perform: upload/download/delete/list/ls/mkdir/stat/mv/chmod/chown/versions/snapshot/snapshots/delete-snapshot/repair-status/mount/whoami/add-user/delete-user/users/create-token/tokens/revoke-token")
+	fileName := flag.String("file", "", "Local file for upload, path in the file system for the other operations")
+	dest := flag.String("dest", "", "Destination path (upload: defaults to the file's name in the root directory; mv: required)")
+	overwrite := flag.Bool("overwrite", false, "Replace an existing file at -dest (mv only)")
+	recursive := flag.Bool("recursive", false, "Delete a directory with everything in it (delete), or create missing parents (mkdir)")
+	metadataAddrs := flag.String("metadata", "localhost:50051", "Comma-separated list of metadata service addresses")
+	resume := flag.String("resume", "", "Upload ID of an interrupted upload to resume (upload only)")
+	snapshot := flag.String("snapshot", "", "Snapshot name (snapshot, delete-snapshot), or snapshot to read from instead of the live files (ls, stat, download)")
+	version := flag.Int64("version", 0, "Version of the file to download, as listed by -op=versions (download only; 0 is the current version)")
+	mountpoint := flag.String("mountpoint", "", "Directory to mount the file system on (mount only)")
+	cacheDir := flag.String("cache_dir", defaultCacheDir(), "Directory for the local chunk cache (mount only)")
+	cacheMB := flag.Int64("cache_mb", 1024, "Size limit of the local chunk cache in MB; 0 disables it (mount only)")
+	tokenFile := flag.String("token_file", "", "File holding the API token to authenticate with (defaults to the DFS_TOKEN environment variable)")
+	user := flag.String("user", "", "User name (add-user, delete-user), or user whose tokens to manage (create-token, tokens; defaults to yourself); new owner (chown)")
+	admin := flag.Bool("admin", false, "Make the new user an administrator (add-user only)")
+	mode := flag.String("mode", "", "Permission bits in octal, such as 640 (chmod only)")
+	tokenID := flag.String("token_id", "", "ID of the token to revoke, as listed by -op=tokens (revoke-token only)")
+	description := flag.String("description", "", "What the new token is for (create-token only)")
 	flag.Parse()
 
-	c := clientlib.NewClient()
+	opts := []clientlib.Option{
+		clientlib.WithMetadataAddrs(strings.Split(*metadataAddrs, ",")...),
+		clientlib.WithProgress(os.Stdout),
+	}
+
+	// Authenticate with the token from -token_file or the environment
+	token := os.Getenv("DFS_TOKEN")
+	if *tokenFile != "" {
+		var err error
+		if token, err = auth.ReadTokenFile(*tokenFile); err != nil {
+			log.Fatalf("Failed to read token: %v", err)
+		}
+	}
+	if token != "" {
+		opts = append(opts, clientlib.WithToken(token))
+	}
+	if *operation == "mount" && *cacheMB > 0 {
+		cache, err := clientlib.NewChunkCache(*cacheDir, *cacheMB*1024*1024)
+		if err != nil {
+			log.Fatalf("Failed to open chunk cache: %v", err)
+		}
+		opts = append(opts, clientlib.WithChunkCache(cache))
+	}
+
+	c, err := clientlib.NewClient(opts...)
+	if err != nil {
+		log.Fatalf("Failed to create client: %v", err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
 
 	switch *operation {
 	case "upload":
 		if *fileName == "" {
 			log.Fatalf("Upload operation requires -file parameter")
 		}
-		err := c.UploadFile(*fileName)
+		if *resume != "" {
+			err = c.ResumeUpload(ctx, *fileName, *resume)
+		} else {
+			err = c.UploadFile(ctx, *fileName, *dest)
+		}
 		if err != nil {
+			var uploadErr *clientlib.UploadError
+			if errors.As(err, &uploadErr) {
+				log.Fatalf("Upload failed: %v\nResume it with: -op=upload -file=%s -resume=%s", err, *fileName, uploadErr.UploadID)
+			}
 			log.Fatalf("Upload failed: %v", err)
 		}
 		fmt.Println("File uploaded successfully.")
@@ -31,13 +93,138 @@ func main() {
 		if *fileName == "" {
 			log.Fatalf("Download operation requires -file parameter")
 		}
-		err := c.DownloadFile(*fileName)
+		if *snapshot != "" {
+			err = c.DownloadSnapshot(ctx, *snapshot, *fileName)
+		} else {
+			err = c.DownloadVersion(ctx, *fileName, *version)
+		}
 		if err != nil {
 			log.Fatalf("Download failed: %v", err)
 		}
 		fmt.Println("File downloaded successfully.")
+	case "delete":
+		if *fileName == "" {
+			log.Fatalf("Delete operation requires -file parameter")
+		}
+		err := c.DeleteFile(ctx, *fileName, *recursive)
+		if err != nil {
+			log.Fatalf("Delete failed: %v", err)
+		}
+		fmt.Println("Deleted successfully.")
+	case "mkdir":
+		if *fileName == "" {
+			log.Fatalf("Mkdir operation requires -file parameter")
+		}
+		if err := c.Mkdir(ctx, *fileName, *recursive); err != nil {
+			log.Fatalf("Mkdir failed: %v", err)
+		}
+		fmt.Println("Directory created successfully.")
+	case "ls":
+		var entries []*metadataPb.DirEntry
+		if *snapshot != "" {
+			entries, err = c.ListSnapshotDir(ctx, *snapshot, *fileName)
+		} else {
+			entries, err = c.ListDir(ctx, *fileName)
+		}
+		if err != nil {
+			log.Fatalf("List directory failed: %v", err)
+		}
+		for _, entry := range entries {
+			printEntry(entry)
+		}
+	case "stat":
+		if *fileName == "" {
+			log.Fatalf("Stat operation requires -file parameter")
+		}
+		var entry *metadataPb.DirEntry
+		if *snapshot != "" {
+			entry, err = c.StatSnapshot(ctx, *snapshot, *fileName)
+		} else {
+			entry, err = c.Stat(ctx, *fileName)
+		}
+		if err != nil {
+			log.Fatalf("Stat failed: %v", err)
+		}
+		printEntry(entry)
+	case "mv":
+		if *fileName == "" || *dest == "" {
+			log.Fatalf("Mv operation requires -file and -dest parameters")
+		}
+		if err := c.Rename(ctx, *fileName, *dest, *overwrite); err != nil {
+			log.Fatalf("Mv failed: %v", err)
+		}
+		fmt.Println("Moved successfully.")
+	case "chmod":
+		if *fileName == "" || *mode == "" {
+			log.Fatalf("Chmod operation requires -file and -mode parameters")
+		}
+		bits, err := strconv.ParseUint(*mode, 8, 32)
+		if err != nil {
+			log.Fatalf("Invalid mode %q: must be octal, such as 640", *mode)
+		}
+		entry, err := c.Chmod(ctx, *fileName, uint32(bits))
+		if err != nil {
+			log.Fatalf("Chmod failed: %v", err)
+		}
+		printEntry(entry)
+	case "chown":
+		if *fileName == "" || *user == "" {
+			log.Fatalf("Chown operation requires -file and -user parameters")
+		}
+		entry, err := c.Chown(ctx, *fileName, *user)
+		if err != nil {
+			log.Fatalf("Chown failed: %v", err)
+		}
+		printEntry(entry)
+	case "versions":
+		if *fileName == "" {
+			log.Fatalf("Versions operation requires -file parameter")
+		}
+		versions, err := c.ListVersions(ctx, *fileName)
+		if err != nil {
+			log.Fatalf("List versions failed: %v", err)
+		}
+		fmt.Printf("Versions of %s:\n", *fileName)
+		for _, file := range versions {
+			fmt.Printf("- v%d (Size: %.2f MB, Chunks: %d, Uploaded: %s)\n",
+				file.Version,
+				float64(file.FileSize)/(1024*1024),
+				file.NumChunks,
+				file.UploadDate)
+		}
+	case "snapshot":
+		if *snapshot == "" {
+			log.Fatalf("Snapshot operation requires -snapshot parameter")
+		}
+		info, err := c.CreateSnapshot(ctx, *snapshot, *fileName)
+		if err != nil {
+			log.Fatalf("Snapshot failed: %v", err)
+		}
+		fmt.Printf("Snapshot %s of /%s created with %d files.\n", info.Name, info.Path, info.NumFiles)
+	case "snapshots":
+		snapshots, err := c.ListSnapshots(ctx)
+		if err != nil {
+			log.Fatalf("List snapshots failed: %v", err)
+		}
+		fmt.Println("Snapshots:")
+		for _, info := range snapshots {
+			fmt.Printf("- %s (Path: /%s, Files: %d, Size: %.2f MB, Created: %s)\n",
+				info.Name,
+				info.Path,
+				info.NumFiles,
+				float64(info.TotalSize)/(1024*1024),
+				info.Created)
+		}
+	case "delete-snapshot":
+		if *snapshot == "" {
+			log.Fatalf("Delete-snapshot operation requires -snapshot parameter")
+		}
+		if err := c.DeleteSnapshot(ctx, *snapshot); err != nil {
+			log.Fatalf("Delete snapshot failed: %v", err)
+		}
+		fmt.Println("Snapshot deleted successfully.")
 	case "list":
-		files, err := c.ListFiles()
+		files, err := c.ListFiles(ctx)
 		if err != nil {
 			log.Fatalf("List files failed: %v", err)
 		}
@@ -50,7 +237,121 @@ func main() {
 				file.NumReplicas,
 				file.UploadDate)
 		}
+	case "repair-status":
+		repair, err := c.RepairStatus(ctx)
+		if err != nil {
+			log.Fatalf("Repair status failed: %v", err)
+		}
+		fmt.Printf("Last scan: %s\n", repair.LastScan)
+		fmt.Printf("Under-replicated chunks: %d, lost chunks: %d\n", repair.UnderReplicatedChunks, repair.LostChunks)
+		fmt.Printf("Repaired: %d, failed: %d\n", repair.RepairedChunks, repair.FailedRepairs)
+		for _, task := range repair.InProgress {
+			fmt.Printf("- copying %s from %s to %s (started %s)\n", task.ChunkId, task.Source, task.Target, task.StartedAt)
+		}
+	case "whoami":
+		resp, err := c.WhoAmI(ctx)
+		if err != nil {
+			log.Fatalf("Whoami failed: %v", err)
+		}
+		if !resp.AuthEnabled {
+			fmt.Println("Authentication is disabled; every caller may do anything.")
+			break
+		}
+		fmt.Printf("%s (Admin: %t)\n", resp.User.Name, resp.User.Admin)
+	case "add-user":
+		if *user == "" {
+			log.Fatalf("Add-user operation requires -user parameter")
+		}
+		info, err := c.CreateUser(ctx, *user, *admin)
+		if err != nil {
+			log.Fatalf("Add user failed: %v", err)
+		}
+		fmt.Printf("User %s created (Admin: %t).\n", info.Name, info.Admin)
+	case "delete-user":
+		if *user == "" {
+			log.Fatalf("Delete-user operation requires -user parameter")
+		}
+		if err := c.DeleteUser(ctx, *user); err != nil {
+			log.Fatalf("Delete user failed: %v", err)
+		}
+		fmt.Println("User deleted successfully.")
+	case "users":
+		users, err := c.ListUsers(ctx)
+		if err != nil {
+			log.Fatalf("List users failed: %v", err)
+		}
+		fmt.Println("Users:")
+		for _, info := range users {
+			fmt.Printf("- %s (Admin: %t, Created: %s)\n", info.Name, info.Admin, info.Created)
+		}
+	case "create-token":
+		token, info, err := c.CreateToken(ctx, *user, *description)
+		if err != nil {
+			log.Fatalf("Create token failed: %v", err)
+		}
+		fmt.Printf("Token %s created for %s. Store it now; it cannot be shown again:\n%s\n", info.Id, info.User, token)
+	case "tokens":
+		tokens, err := c.ListTokens(ctx, *user)
+		if err != nil {
+			log.Fatalf("List tokens failed: %v", err)
+		}
+		fmt.Println("Tokens:")
+		for _, info := range tokens {
+			fmt.Printf("- %s (User: %s, Description: %s, Created: %s)\n", info.Id, info.User, info.Description, info.Created)
+		}
+	case "revoke-token":
+		if *tokenID == "" {
+			log.Fatalf("Revoke-token operation requires -token_id parameter")
+		}
+		if err := c.RevokeToken(ctx, *tokenID); err != nil {
+			log.Fatalf("Revoke token failed: %v", err)
+		}
+		fmt.Println("Token revoked successfully.")
+	case "mount":
+		if *mountpoint == "" {
+			log.Fatalf("Mount operation requires -mountpoint parameter")
+		}
+		if err := mountFS(c, *mountpoint); err != nil {
+			log.Fatalf("Mount failed: %v", err)
+		}
+		fmt.Println("Unmounted.")
 	default:
-		fmt.Println("Invalid operation. Use -op=upload, -op=download, or -op=list.")
+		fmt.Println("Invalid operation. Use -op=upload, -op=download, -op=delete, -op=list, -op=ls, -op=mkdir, -op=stat, -op=mv, -op=chmod, -op=chown, -op=versions, -op=snapshot, -op=snapshots, -op=delete-snapshot, -op=repair-status, -op=mount, -op=whoami, -op=add-user, -op=delete-user, -op=users, -op=create-token, -op=tokens, or -op=revoke-token.")
+	}
+}
+
+// defaultCacheDir returns where the chunk cache is kept unless -cache_dir is
+// given
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "dfs", "chunks")
+}
+
+// printEntry prints one line describing a file or a directory, starting with
+// its permissions and owner
+func printEntry(entry *metadataPb.DirEntry) {
+	name := "/" + entry.Path
+	mode := os.FileMode(entry.Mode)
+	owner := entry.Owner
+	if owner == "" {
+		owner = auth.RootUser
+	}
+	if entry.IsDir {
+		if entry.Path != "" {
+			name += "/"
+		}
+		fmt.Printf("- %s %-8s %s (Directory, Created: %s)\n", mode|os.ModeDir, owner, name, entry.Created)
+		return
 	}
+	fmt.Printf("- %s %-8s %s (Size: %.2f MB, Chunks: %d, Replicas: %d, Uploaded: %s)\n",
+		mode,
+		owner,
+		name,
+		float64(entry.File.GetFileSize())/(1024*1024),
+		entry.File.GetNumChunks(),
+		entry.File.GetNumReplicas(),
+		entry.Created)
 }
@@ -0,0 +1,500 @@
+// client/mount.go
+
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"dfs/clientlib"
+	metadataPb "dfs/proto/metadata"
+)
+
+// attrTimeout is how long the kernel caches names and attributes. Changes
+// made through other clients show up within it.
+const attrTimeout = time.Second
+
+// renameNoReplace is the RENAME_NOREPLACE flag of renameat2(2)
+const renameNoReplace = 0x1
+
+// mountFS serves the namespace as a FUSE file system at mountpoint until the
+// process is interrupted or the file system is unmounted. Reads go through
+// the client's chunk cache. Written files are spooled to local temporary
+// files and uploaded when they are closed.
+func mountFS(c *clientlib.Client, mountpoint string) error {
+	timeout := attrTimeout
+	root := &dfsNode{client: c}
+	server, err := fs.Mount(mountpoint, root, &fs.Options{
+		EntryTimeout: &timeout,
+		AttrTimeout:  &timeout,
+		MountOptions: fuse.MountOptions{
+			FsName:      "dfs",
+			Name:        "dfs",
+			DirectMount: true,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Mounted at %s; unmount with fusermount -u %s or Ctrl-C", mountpoint, mountpoint)
+
+	// Unmount on interrupt so the mountpoint is not left dangling
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		if err := server.Unmount(); err != nil {
+			log.Printf("Failed to unmount %s: %v", mountpoint, err)
+		}
+	}()
+
+	server.Wait()
+	return nil
+}
+
+// toErrno maps the error of a failed cluster operation to an errno
+func toErrno(err error) syscall.Errno {
+	switch status.Code(err) {
+	case codes.OK:
+		return 0
+	case codes.NotFound:
+		return syscall.ENOENT
+	case codes.AlreadyExists:
+		return syscall.EEXIST
+	case codes.InvalidArgument, codes.FailedPrecondition:
+		return syscall.EINVAL
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return syscall.EACCES
+	default:
+		log.Printf("Mount: %v", err)
+		return syscall.EIO
+	}
+}
+
+// dfsNode is a file or directory in the mounted file system
+type dfsNode struct {
+	fs.Inode
+	client *clientlib.Client
+}
+
+var (
+	_ fs.NodeGetattrer = (*dfsNode)(nil)
+	_ fs.NodeSetattrer = (*dfsNode)(nil)
+	_ fs.NodeLookuper  = (*dfsNode)(nil)
+	_ fs.NodeReaddirer = (*dfsNode)(nil)
+	_ fs.NodeOpener    = (*dfsNode)(nil)
+	_ fs.NodeCreater   = (*dfsNode)(nil)
+	_ fs.NodeMkdirer   = (*dfsNode)(nil)
+	_ fs.NodeUnlinker  = (*dfsNode)(nil)
+	_ fs.NodeRmdirer   = (*dfsNode)(nil)
+	_ fs.NodeRenamer   = (*dfsNode)(nil)
+)
+
+// path returns the node's path in the file system
+func (n *dfsNode) path() string {
+	return "/" + n.Path(nil)
+}
+
+// child returns the path of a child of the node
+func (n *dfsNode) child(name string) string {
+	return path.Join(n.path(), name)
+}
+
+// newChild creates the inode for a child of the node
+func (n *dfsNode) newChild(ctx context.Context, isDir bool) *fs.Inode {
+	mode := uint32(fuse.S_IFREG)
+	if isDir {
+		mode = fuse.S_IFDIR
+	}
+	return n.NewInode(ctx, &dfsNode{client: n.client}, fs.StableAttr{Mode: mode})
+}
+
+// fillAttr describes a file or directory entry, with the permission bits the
+// metadata service enforces
+func fillAttr(entry *metadataPb.DirEntry, out *fuse.Attr) {
+	if entry.IsDir {
+		out.Mode = fuse.S_IFDIR | entry.Mode
+		out.Nlink = 2
+	} else {
+		out.Mode = fuse.S_IFREG | entry.Mode
+		out.Nlink = 1
+		out.Size = uint64(entry.File.GetFileSize())
+		out.Blocks = (out.Size + 511) / 512
+	}
+	if created, err := time.ParseInLocation("2006-01-02 15:04:05", entry.Created, time.Local); err == nil {
+		out.SetTimes(nil, &created, &created)
+	}
+}
+
+// Getattr describes the node. A file open for writing reports what has been
+// written so far.
+func (n *dfsNode) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	if w, ok := fh.(*writeHandle); ok {
+		return w.Getattr(ctx, out)
+	}
+
+	entry, err := n.client.Stat(ctx, n.path())
+	if err != nil {
+		return toErrno(err)
+	}
+	fillAttr(entry, &out.Attr)
+	return 0
+}
+
+// Setattr truncates files and changes their permission bits. Owners are
+// user names rather than local user IDs, so changing them here fails with
+// EPERM; use -op=chown instead. Times are accepted but not stored, since the
+// file system does not keep them.
+func (n *dfsNode) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	_, uidSet := in.GetUID()
+	_, gidSet := in.GetGID()
+	if uidSet || gidSet {
+		return syscall.EPERM
+	}
+	if mode, ok := in.GetMode(); ok {
+		if _, err := n.client.Chmod(ctx, n.path(), mode&0777); err != nil {
+			// Like chmod(2), refuse with EPERM to anyone but the owner
+			if status.Code(err) == codes.PermissionDenied {
+				return syscall.EPERM
+			}
+			return toErrno(err)
+		}
+	}
+	if size, ok := in.GetSize(); ok {
+		switch w, isWrite := fh.(*writeHandle); {
+		case isWrite:
+			if errno := w.truncate(int64(size)); errno != 0 {
+				return errno
+			}
+		case size == 0:
+			// Truncating a file that is not open replaces it with an empty one
+			file, err := n.client.Create(ctx, n.path())
+			if err == nil {
+				err = file.Close()
+			}
+			if err != nil {
+				return toErrno(err)
+			}
+		default:
+			return syscall.EOPNOTSUPP
+		}
+	}
+	return n.Getattr(ctx, fh, out)
+}
+
+// Lookup finds a child of a directory
+func (n *dfsNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	entry, err := n.client.Stat(ctx, n.child(name))
+	if err != nil {
+		return nil, toErrno(err)
+	}
+	fillAttr(entry, &out.Attr)
+	return n.newChild(ctx, entry.IsDir), 0
+}
+
+// Readdir lists a directory
+func (n *dfsNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries, err := n.client.ListDir(ctx, n.path())
+	if err != nil {
+		return nil, toErrno(err)
+	}
+
+	list := make([]fuse.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		mode := uint32(fuse.S_IFREG)
+		if entry.IsDir {
+			mode = fuse.S_IFDIR
+		}
+		list = append(list, fuse.DirEntry{Name: path.Base(entry.Path), Mode: mode})
+	}
+	return fs.NewListDirStream(list), 0
+}
+
+// Open opens a file for reading, or for writing through a local spool file.
+// Opening for writing without O_TRUNC copies the current contents into the
+// spool first, so appends and in-place edits work.
+func (n *dfsNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	if flags&(syscall.O_WRONLY|syscall.O_RDWR) != 0 {
+		w, errno := newWriteHandle(n.client, n.path())
+		if errno != 0 {
+			return nil, 0, errno
+		}
+		if flags&syscall.O_TRUNC != 0 {
+			w.dirty = true
+		} else if errno := w.load(ctx); errno != 0 {
+			w.Release(ctx)
+			return nil, 0, errno
+		}
+		return w, 0, 0
+	}
+
+	// The reader fetches chunks after Open returns, so it cannot use the
+	// request's context
+	reader, err := n.client.Open(context.Background(), n.path())
+	if err != nil {
+		return nil, 0, toErrno(err)
+	}
+	return &readHandle{reader: reader}, 0, 0
+}
+
+// Create creates a file and opens it for writing. The file appears in the
+// cluster once it is closed.
+func (n *dfsNode) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
+	p := n.child(name)
+	if flags&syscall.O_EXCL != 0 {
+		if _, err := n.client.Stat(ctx, p); err == nil {
+			return nil, nil, 0, syscall.EEXIST
+		}
+	}
+
+	w, errno := newWriteHandle(n.client, p)
+	if errno != 0 {
+		return nil, nil, 0, errno
+	}
+	w.dirty = true
+
+	out.Attr.Mode = fuse.S_IFREG | 0644
+	out.Attr.Nlink = 1
+	now := time.Now()
+	out.Attr.SetTimes(nil, &now, &now)
+	return n.newChild(ctx, false), w, 0, 0
+}
+
+// Mkdir creates a directory
+func (n *dfsNode) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	p := n.child(name)
+	if err := n.client.Mkdir(ctx, p, false); err != nil {
+		return nil, toErrno(err)
+	}
+	entry, err := n.client.Stat(ctx, p)
+	if err != nil {
+		return nil, toErrno(err)
+	}
+	fillAttr(entry, &out.Attr)
+	return n.newChild(ctx, true), 0
+}
+
+// Unlink deletes a file
+func (n *dfsNode) Unlink(ctx context.Context, name string) syscall.Errno {
+	p := n.child(name)
+	entry, err := n.client.Stat(ctx, p)
+	if err != nil {
+		return toErrno(err)
+	}
+	if entry.IsDir {
+		return syscall.EISDIR
+	}
+	return toErrno(n.client.DeleteFile(ctx, p, false))
+}
+
+// Rmdir deletes an empty directory
+func (n *dfsNode) Rmdir(ctx context.Context, name string) syscall.Errno {
+	p := n.child(name)
+	entry, err := n.client.Stat(ctx, p)
+	if err != nil {
+		return toErrno(err)
+	}
+	if !entry.IsDir {
+		return syscall.ENOTDIR
+	}
+
+	err = n.client.DeleteFile(ctx, p, false)
+	if status.Code(err) == codes.FailedPrecondition {
+		return syscall.ENOTEMPTY
+	}
+	return toErrno(err)
+}
+
+// Rename atomically moves a file or directory, replacing a file or empty
+// directory at the destination unless RENAME_NOREPLACE is given
+func (n *dfsNode) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	if flags&^renameNoReplace != 0 {
+		return syscall.EINVAL
+	}
+	dst := path.Join("/"+newParent.EmbeddedInode().Path(nil), newName)
+
+	err := n.client.Rename(ctx, n.child(name), dst, flags&renameNoReplace == 0)
+	if status.Code(err) == codes.FailedPrecondition {
+		// The destination is a non-empty directory, or of the other type
+		return syscall.ENOTEMPTY
+	}
+	return toErrno(err)
+}
+
+// readHandle is a file open for reading
+type readHandle struct {
+	mu     sync.Mutex
+	reader io.ReadSeekCloser
+}
+
+func (r *readHandle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.reader.Seek(off, io.SeekStart); err != nil {
+		return nil, syscall.EINVAL
+	}
+	n, err := io.ReadFull(r.reader, dest)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		log.Printf("Mount: read failed: %v", err)
+		return nil, syscall.EIO
+	}
+	return fuse.ReadResultData(dest[:n]), 0
+}
+
+func (r *readHandle) Release(ctx context.Context) syscall.Errno {
+	r.reader.Close()
+	return 0
+}
+
+// writeHandle is a file open for writing. Writes go to a local spool file,
+// which is uploaded as a new version of the file when it is closed.
+type writeHandle struct {
+	mu     sync.Mutex
+	client *clientlib.Client
+	path   string
+	spool  *os.File
+	dirty  bool // The spool has changes that have not been uploaded
+}
+
+// newWriteHandle creates a write handle with an empty spool
+func newWriteHandle(client *clientlib.Client, p string) (*writeHandle, syscall.Errno) {
+	spool, err := os.CreateTemp("", "dfs-mount-*")
+	if err != nil {
+		log.Printf("Mount: failed to create spool file: %v", err)
+		return nil, syscall.EIO
+	}
+	// Unlinked straight away, so nothing is left behind if the mount dies
+	os.Remove(spool.Name())
+
+	return &writeHandle{client: client, path: p, spool: spool}, 0
+}
+
+// load copies the file's current contents into the spool
+func (w *writeHandle) load(ctx context.Context) syscall.Errno {
+	reader, err := w.client.Open(ctx, w.path)
+	if err != nil {
+		return toErrno(err)
+	}
+	defer reader.Close()
+
+	if _, err := io.Copy(w.spool, reader); err != nil {
+		log.Printf("Mount: failed to read %s: %v", w.path, err)
+		return syscall.EIO
+	}
+	return 0
+}
+
+func (w *writeHandle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n, err := w.spool.WriteAt(data, off)
+	w.dirty = true
+	if err != nil {
+		log.Printf("Mount: failed to spool %s: %v", w.path, err)
+		return uint32(n), syscall.EIO
+	}
+	return uint32(n), 0
+}
+
+func (w *writeHandle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n, err := w.spool.ReadAt(dest, off)
+	if err != nil && err != io.EOF {
+		return nil, syscall.EIO
+	}
+	return fuse.ReadResultData(dest[:n]), 0
+}
+
+// truncate resizes the spooled file
+func (w *writeHandle) truncate(size int64) syscall.Errno {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.spool.Truncate(size); err != nil {
+		return syscall.EIO
+	}
+	w.dirty = true
+	return 0
+}
+
+func (w *writeHandle) Getattr(ctx context.Context, out *fuse.AttrOut) syscall.Errno {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	info, err := w.spool.Stat()
+	if err != nil {
+		return syscall.EIO
+	}
+	out.Attr.Mode = fuse.S_IFREG | 0644
+	out.Attr.Nlink = 1
+	out.Attr.Size = uint64(info.Size())
+	out.Attr.Blocks = (out.Attr.Size + 511) / 512
+	modTime := info.ModTime()
+	out.Attr.SetTimes(nil, &modTime, &modTime)
+	return 0
+}
+
+// Flush uploads the spooled file if it changed. It runs on every close(2) of
+// the file, so upload errors are reported to the program closing it.
+func (w *writeHandle) Flush(ctx context.Context) syscall.Errno {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.dirty {
+		return 0
+	}
+
+	info, err := w.spool.Stat()
+	if err != nil {
+		return syscall.EIO
+	}
+
+	file, err := w.client.Create(ctx, w.path)
+	if err != nil {
+		return toErrno(err)
+	}
+	if _, err := io.Copy(file, io.NewSectionReader(w.spool, 0, info.Size())); err != nil {
+		// The unfinished upload expires on the metadata service
+		log.Printf("Mount: failed to upload %s: %v", w.path, err)
+		return syscall.EIO
+	}
+	if err := file.Close(); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return syscall.EINTR
+		}
+		return toErrno(err)
+	}
+
+	w.dirty = false
+	log.Printf("Mount: wrote %s (%d bytes)", w.path, info.Size())
+	return 0
+}
+
+// Fsync uploads the spooled file like Flush
+func (w *writeHandle) Fsync(ctx context.Context, flags uint32) syscall.Errno {
+	return w.Flush(ctx)
+}
+
+func (w *writeHandle) Release(ctx context.Context) syscall.Errno {
+	w.spool.Close()
+	return 0
+}
@@ -0,0 +1,16 @@
+// client/mount_other.go
+
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+
+	"dfs/clientlib"
+)
+
+// mountFS is only implemented on Linux
+func mountFS(c *clientlib.Client, mountpoint string) error {
+	return errors.New("mounting is only supported on Linux")
+}
@@ -0,0 +1,115 @@
+// client/mount_test.go
+
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestToErrno(t *testing.T) {
+	tests := []struct {
+		err   error
+		errno syscall.Errno
+	}{
+		{err: nil, errno: 0},
+		{err: status.Error(codes.NotFound, "missing"), errno: syscall.ENOENT},
+		{err: status.Error(codes.AlreadyExists, "exists"), errno: syscall.EEXIST},
+		{err: status.Error(codes.InvalidArgument, "bad"), errno: syscall.EINVAL},
+		{err: status.Error(codes.FailedPrecondition, "not empty"), errno: syscall.EINVAL},
+		{err: status.Error(codes.PermissionDenied, "denied"), errno: syscall.EACCES},
+		{err: status.Error(codes.Unauthenticated, "no token"), errno: syscall.EACCES},
+		{err: status.Error(codes.Unavailable, "down"), errno: syscall.EIO},
+		{err: errors.New("broken"), errno: syscall.EIO},
+	}
+
+	for _, tt := range tests {
+		if errno := toErrno(tt.err); errno != tt.errno {
+			t.Errorf("toErrno(%v) = %v, want %v", tt.err, errno, tt.errno)
+		}
+	}
+}
+
+func TestSetattrChown(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid uint32
+	}{
+		{name: "owner", valid: fuse.FATTR_UID},
+		{name: "group", valid: fuse.FATTR_GID},
+		{name: "owner and mode", valid: fuse.FATTR_UID | fuse.FATTR_MODE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Refused before the cluster is asked, so no client is needed
+			n := &dfsNode{}
+			in := &fuse.SetAttrIn{SetAttrInCommon: fuse.SetAttrInCommon{Valid: tt.valid, Mode: 0600}}
+			if errno := n.Setattr(context.Background(), nil, in, &fuse.AttrOut{}); errno != syscall.EPERM {
+				t.Errorf("Setattr = %v, want %v", errno, syscall.EPERM)
+			}
+		})
+	}
+}
+
+func TestWriteHandleSpool(t *testing.T) {
+	w, errno := newWriteHandle(nil, "/file.txt")
+	if errno != 0 {
+		t.Fatalf("newWriteHandle: %v", errno)
+	}
+	defer w.Release(context.Background())
+	ctx := context.Background()
+
+	// Writes land at their offsets, leaving holes zeroed
+	if n, errno := w.Write(ctx, []byte("hello"), 0); errno != 0 || n != 5 {
+		t.Fatalf("Write = %d, %v", n, errno)
+	}
+	if n, errno := w.Write(ctx, []byte("world"), 7); errno != 0 || n != 5 {
+		t.Fatalf("Write = %d, %v", n, errno)
+	}
+	if !w.dirty {
+		t.Errorf("spool not marked dirty after a write")
+	}
+
+	read := func(size int, off int64) string {
+		t.Helper()
+		result, errno := w.Read(ctx, make([]byte, size), off)
+		if errno != 0 {
+			t.Fatalf("Read: %v", errno)
+		}
+		data, _ := result.Bytes(nil)
+		return string(data)
+	}
+	if got := read(20, 0); got != "hello\x00\x00world" {
+		t.Errorf("Read = %q", got)
+	}
+	if got := read(4, 8); got != "orld" {
+		t.Errorf("Read at 8 = %q", got)
+	}
+
+	// Truncation shrinks the file, and Getattr reports the spooled size
+	if errno := w.truncate(3); errno != 0 {
+		t.Fatalf("truncate: %v", errno)
+	}
+	if got := read(20, 0); got != "hel" {
+		t.Errorf("Read after truncate = %q", got)
+	}
+	var out fuse.AttrOut
+	if errno := w.Getattr(ctx, &out); errno != 0 || out.Size != 3 || out.Mode != fuse.S_IFREG|0644 {
+		t.Errorf("Getattr = size %d, mode %o, %v", out.Size, out.Mode, errno)
+	}
+
+	// A clean spool is not uploaded again, so no client is needed
+	w.dirty = false
+	if errno := w.Flush(ctx); errno != 0 {
+		t.Errorf("Flush of a clean spool = %v", errno)
+	}
+}
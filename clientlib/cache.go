@@ -0,0 +1,208 @@
+// clientlib/cache.go
+
+package clientlib
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	metadataPb "dfs/proto/metadata"
+)
+
+// ChunkCache keeps recently read chunks on local disk. A stored chunk never
+// changes, since every upload gets fresh chunk handles, so cached chunks never
+// go stale. They are still checked against their checksums when read back.
+type ChunkCache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	lru     *list.List               // Cached chunks, most recently used first
+	entries map[string]*list.Element // Elements of lru by chunk ID
+	size    int64                    // Total size of the cached chunks
+}
+
+// cacheEntry is a chunk held by a ChunkCache
+type cacheEntry struct {
+	chunkID string
+	size    int64
+}
+
+// NewChunkCache opens a chunk cache in dir holding at most maxBytes of chunk
+// data. Chunks left in dir by an earlier cache are kept, oldest evicted first.
+func NewChunkCache(dir string, maxBytes int64) (*ChunkCache, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("invalid cache size: %d", maxBytes)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %v", err)
+	}
+
+	cache := &ChunkCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+
+	// Pick up the chunks already in dir, most recently used first
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %v", err)
+	}
+	var infos []os.FileInfo
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if filepath.Ext(info.Name()) == ".tmp" {
+			// Left behind by a crash while a chunk was being cached
+			os.Remove(filepath.Join(dir, info.Name()))
+			continue
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().After(infos[j].ModTime())
+	})
+	for _, info := range infos {
+		cache.entries[info.Name()] = cache.lru.PushBack(&cacheEntry{chunkID: info.Name(), size: info.Size()})
+		cache.size += info.Size()
+	}
+	cache.mu.Lock()
+	cache.evict()
+	cache.mu.Unlock()
+
+	return cache, nil
+}
+
+// WithChunkCache serves chunks read by readers from Open and its variants
+// from cache, and adds the chunks they fetch to it. By default nothing is
+// cached.
+func WithChunkCache(cache *ChunkCache) Option {
+	return func(o *options) {
+		o.chunkCache = cache
+	}
+}
+
+// fetchChunk returns a whole chunk of size bytes, from the chunk cache if the
+// client has one and the chunk is in it
+func (c *Client) fetchChunk(ctx context.Context, chunkInfo *metadataPb.ChunkInfo, size int64) ([]byte, error) {
+	cache := c.opts.chunkCache
+	if cache != nil {
+		if data := cache.get(chunkInfo, size); data != nil {
+			return data, nil
+		}
+	}
+
+	buf := make(bufferAt, size)
+	n, err := c.retrieveChunk(ctx, chunkInfo, buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	if n != size {
+		return nil, fmt.Errorf("short read from chunk %s: got %d of %d bytes", chunkInfo.ChunkId, n, size)
+	}
+
+	if cache != nil {
+		cache.put(chunkInfo.ChunkId, buf)
+	}
+	return buf, nil
+}
+
+// path returns the file holding a chunk. Chunk IDs are checked by the
+// metadata service, but are cleaned here too since they become file names.
+func (c *ChunkCache) path(chunkID string) string {
+	return filepath.Join(c.dir, filepath.Base(chunkID))
+}
+
+// get returns a cached chunk, or nil if it is not cached or its cached copy
+// is damaged
+func (c *ChunkCache) get(chunkInfo *metadataPb.ChunkInfo, size int64) []byte {
+	c.mu.Lock()
+	elem, exists := c.entries[chunkInfo.ChunkId]
+	if exists {
+		c.lru.MoveToFront(elem)
+	}
+	c.mu.Unlock()
+	if !exists {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.path(chunkInfo.ChunkId))
+	if err == nil && int64(len(data)) == size && (chunkInfo.Checksum == nil || crc32.Checksum(data, castagnoli) == *chunkInfo.Checksum) {
+		now := time.Now()
+		os.Chtimes(c.path(chunkInfo.ChunkId), now, now)
+		return data
+	}
+
+	c.mu.Lock()
+	c.remove(chunkInfo.ChunkId)
+	c.mu.Unlock()
+	return nil
+}
+
+// put adds a chunk to the cache, evicting the least recently used chunks to
+// make room. Failing to cache a chunk is not an error.
+func (c *ChunkCache) put(chunkID string, data []byte) {
+	size := int64(len(data))
+	if size > c.maxBytes {
+		return
+	}
+
+	// Write to a temporary file first so a crash never leaves a partial chunk
+	tmp, err := os.CreateTemp(c.dir, "*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(chunkID))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, exists := c.entries[chunkID]; exists {
+		c.size -= elem.Value.(*cacheEntry).size
+		c.lru.Remove(elem)
+	}
+	c.entries[chunkID] = c.lru.PushFront(&cacheEntry{chunkID: chunkID, size: size})
+	c.size += size
+	c.evict()
+}
+
+// evict removes the least recently used chunks until the cache fits in its
+// size limit. The caller must hold c.mu.
+func (c *ChunkCache) evict() {
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back().Value.(*cacheEntry).chunkID)
+	}
+}
+
+// remove drops a chunk from the cache. The caller must hold c.mu.
+func (c *ChunkCache) remove(chunkID string) {
+	elem, exists := c.entries[chunkID]
+	if !exists {
+		return
+	}
+	c.lru.Remove(elem)
+	delete(c.entries, chunkID)
+	c.size -= elem.Value.(*cacheEntry).size
+	os.Remove(c.path(chunkID))
+}
@@ -4,167 +4,412 @@ package clientlib
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
+	"dfs/auth"
 	metadataPb "dfs/proto/metadata"
 	storagePb "dfs/proto/storage"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	leaderRetries    = 10                     // Attempts to reach the metadata leader before giving up
+	leaderRetryDelay = 500 * time.Millisecond // Wait between attempts, e.g. while an election runs
 )
 
 // Client represents the client interacting with Metadata and Storage services
 type Client struct {
-	metadataClient metadataPb.MetadataServiceClient
-	storageClients map[string]storagePb.StorageServiceClient
-	chunkSize      int64
-	mu             sync.Mutex
+	opts            options
+	metadataClients map[string]metadataPb.MetadataServiceClient
+	leaderAddr      string
+	storageClients  map[string]storagePb.StorageServiceClient
+	conns           []*grpc.ClientConn
+	sem             chan struct{} // Limits concurrent chunk transfers
+	mu              sync.Mutex
+}
+
+// NewClient initializes a new Client configured by opts. With no options the
+// client connects to a single metadata node on localhost:50051.
+func NewClient(opts ...Option) (*Client, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if len(o.metadataAddrs) == 0 {
+		return nil, errors.New("at least one metadata address is required")
+	}
+	if o.concurrency < 1 {
+		return nil, fmt.Errorf("invalid concurrency: %d", o.concurrency)
+	}
+	if o.rpcTimeout <= 0 || o.transferTimeout <= 0 {
+		return nil, errors.New("timeouts must be positive")
+	}
+
+	// Send the token, or the one on each call's context, with every call
+	o.dialOptions = append(o.dialOptions, grpc.WithPerRPCCredentials(auth.Credentials(o.token)))
+
+	c := &Client{
+		opts:            o,
+		metadataClients: make(map[string]metadataPb.MetadataServiceClient),
+		leaderAddr:      o.metadataAddrs[0],
+		storageClients:  make(map[string]storagePb.StorageServiceClient),
+		sem:             make(chan struct{}, o.concurrency),
+	}
+
+	// Connect to every Metadata Service node
+	for _, addr := range o.metadataAddrs {
+		if _, err := c.getMetadataClient(addr); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	// Look up the leader now; failures are retried on the first request
+	ctx, cancel := context.WithTimeout(context.Background(), o.rpcTimeout)
+	defer cancel()
+	if err := c.findLeader(ctx); err != nil {
+		c.opts.logger.Printf("Metadata leader not found yet: %v", err)
+	}
+
+	return c, nil
+}
+
+// Close closes the client's connections to the metadata and storage nodes
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var firstErr error
+	for _, conn := range c.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	c.conns = nil
+	c.metadataClients = make(map[string]metadataPb.MetadataServiceClient)
+	c.storageClients = make(map[string]storagePb.StorageServiceClient)
+	return firstErr
 }
 
-// NewClient initializes a new Client
-func NewClient() *Client {
-	// Connect to Metadata Service
-	metadataConn, err := grpc.Dial("localhost:50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
+// getMetadataClient retrieves or creates a MetadataServiceClient for the given address
+func (c *Client) getMetadataClient(address string) (metadataPb.MetadataServiceClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if client, exists := c.metadataClients[address]; exists {
+		return client, nil
+	}
+
+	dialOptions := append([]grpc.DialOption{grpc.WithChainUnaryInterceptor(detectSent)}, c.opts.dialOptions...)
+	conn, err := grpc.Dial(address, dialOptions...)
 	if err != nil {
-		log.Fatalf("Failed to connect to Metadata Service: %v", err)
+		return nil, fmt.Errorf("failed to connect to metadata node at %s: %v", address, err)
 	}
-	metadataClient := metadataPb.NewMetadataServiceClient(metadataConn)
 
-	return &Client{
-		metadataClient: metadataClient,
-		storageClients: make(map[string]storagePb.StorageServiceClient),
-		chunkSize:      64 * 1024 * 1024, // 64MB
+	client := metadataPb.NewMetadataServiceClient(conn)
+	c.metadataClients[address] = client
+	c.conns = append(c.conns, conn)
+	return client, nil
+}
+
+// findLeader asks the metadata nodes which of them is the Raft leader and
+// directs subsequent requests to it
+func (c *Client) findLeader(ctx context.Context) error {
+	for _, addr := range c.opts.metadataAddrs {
+		client, err := c.getMetadataClient(addr)
+		if err != nil {
+			continue
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, c.opts.rpcTimeout)
+		resp, err := client.GetLeader(callCtx, &metadataPb.GetLeaderRequest{})
+		cancel()
+		if err != nil {
+			continue
+		}
+
+		leader := resp.LeaderAddress
+		if resp.IsLeader {
+			leader = addr
+		}
+		if leader == "" {
+			continue
+		}
+
+		c.mu.Lock()
+		c.leaderAddr = leader
+		c.mu.Unlock()
+		return nil
 	}
+
+	return fmt.Errorf("no metadata node reported a leader")
 }
 
-// UploadFile uploads a file to the distributed file system
-func (c *Client) UploadFile(filePath string) error {
-	// Get file info
-	fileInfo, err := os.Stat(filePath)
+// callMetadata runs call against the metadata leader, with a context bounded
+// by the RPC timeout. A call is retried after rediscovering the leader, up to
+// leaderRetries times or until ctx is done, but only when it cannot have
+// taken effect: the node rejected it for not being the leader, or it failed
+// with Unavailable before reaching any node. Other failures are returned
+// as they are, so writes are never applied twice.
+func (c *Client) callMetadata(ctx context.Context, call func(context.Context, metadataPb.MetadataServiceClient) error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		c.mu.Lock()
+		leaderAddr := c.leaderAddr
+		c.mu.Unlock()
+
+		var client metadataPb.MetadataServiceClient
+		client, err = c.getMetadataClient(leaderAddr)
+		if err == nil {
+			sent := false
+			callCtx, cancel := context.WithTimeout(context.WithValue(ctx, sentKey{}, &sent), c.opts.rpcTimeout)
+			err = call(callCtx, client)
+			cancel()
+			if !isNotLeader(err) && (status.Code(err) != codes.Unavailable || sent) {
+				return err
+			}
+		}
+		if attempt == leaderRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(leaderRetryDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if findErr := c.findLeader(ctx); findErr != nil {
+			c.opts.logger.Printf("Retrying metadata request (attempt %d): %v", attempt, findErr)
+		}
+	}
+}
+
+// errorDomain and notLeaderReason identify the ErrorInfo the metadata service
+// attaches to calls it rejected because it is not the leader
+const (
+	errorDomain     = "dfs"
+	notLeaderReason = "NOT_LEADER"
+)
+
+// notLeader returns an Unavailable error tagged like the metadata service's
+// rejections of calls sent to a node that is not the leader
+func notLeader(format string, args ...interface{}) error {
+	st := status.Newf(codes.Unavailable, format, args...)
+	tagged, err := st.WithDetails(&errdetails.ErrorInfo{Reason: notLeaderReason, Domain: errorDomain})
 	if err != nil {
-		return fmt.Errorf("failed to stat file: %v", err)
+		return st.Err()
 	}
+	return tagged.Err()
+}
 
-	fileName := filepath.Base(filePath)
-	fileSize := fileInfo.Size()
+// isNotLeader reports whether err is a rejection by a metadata node that is
+// not the leader, which never takes effect
+func isNotLeader(err error) bool {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.Unavailable {
+		return false
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == errorDomain && info.Reason == notLeaderReason {
+			return true
+		}
+	}
+	return false
+}
 
-	// Request chunk allocation from Metadata Service
-	allocResp, err := c.metadataClient.AllocateChunks(context.Background(), &metadataPb.CreateFileRequest{
-		FileName: fileName,
-		FileSize: fileSize,
+// sentKey marks the context of a metadata call with a flag that detectSent
+// sets once any RPC of the call was handed to a connection
+type sentKey struct{}
+
+// detectSent is a client interceptor recording, for contexts marked with
+// sentKey, whether an RPC reached a connection. An RPC that failed without
+// one was never seen by a server and can safely be sent again.
+func detectSent(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	sent, ok := ctx.Value(sentKey{}).(*bool)
+	if !ok {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	var p peer.Peer
+	err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Peer(&p))...)
+	if p.Addr != nil {
+		*sent = true
+	}
+	return err
+}
+
+// chunkSize asks the metadata leader what size new files are split into
+func (c *Client) chunkSize(ctx context.Context) (int64, error) {
+	var chunkSize int64
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		resp, err := metadataClient.GetLeader(ctx, &metadataPb.GetLeaderRequest{})
+		if err != nil {
+			return err
+		}
+		if !resp.IsLeader {
+			// Retried once the leader is rediscovered
+			return notLeader("%s is not the leader", resp.LeaderAddress)
+		}
+		chunkSize = resp.ChunkSize
+		return nil
 	})
 	if err != nil {
-		return fmt.Errorf("failed to allocate chunks: %v", err)
+		return 0, fmt.Errorf("failed to get chunk size: %w", err)
 	}
+	if chunkSize <= 0 {
+		return 0, fmt.Errorf("metadata service reported invalid chunk size %d", chunkSize)
+	}
+	return chunkSize, nil
+}
 
-	// Open the file
-	file, err := os.Open(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %v", err)
+// acquire waits for a free chunk transfer slot
+func (c *Client) acquire(ctx context.Context) error {
+	select {
+	case c.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	defer file.Close()
+}
 
-	// Upload each chunk
-	var wg sync.WaitGroup
+// release frees a chunk transfer slot
+func (c *Client) release() {
+	<-c.sem
+}
+
+// trackProgress reports the progress of a transfer of total bytes to the
+// configured progress writer. Send byte counts on the returned channel and
+// call the returned function once the transfer is over.
+func (c *Client) trackProgress(action, done string, total int64) (chan<- int64, func()) {
 	progress := make(chan int64)
-	totalSize := fileSize
+	finished := make(chan struct{})
 
-	// Start a goroutine to display upload progress
 	go func() {
-		var uploaded int64
+		defer close(finished)
+		var transferred int64
 		for n := range progress {
-			uploaded += n
-			percent := float64(uploaded) / float64(totalSize) * 100
-			fmt.Printf("\rUploading... %.2f%% complete", percent)
+			transferred += n
+			if c.opts.progress != nil && total > 0 {
+				percent := float64(transferred) / float64(total) * 100
+				fmt.Fprintf(c.opts.progress, "\r%s... %.2f%% complete", action, percent)
+			}
+		}
+		if c.opts.progress != nil {
+			fmt.Fprintf(c.opts.progress, "\n%s complete.\n", done)
 		}
-		fmt.Println("\nUpload complete.")
 	}()
 
-	errChan := make(chan error, len(allocResp.Chunks))
+	return progress, func() {
+		close(progress)
+		<-finished
+	}
+}
 
-	for i, chunkInfo := range allocResp.Chunks {
-		wg.Add(1)
-		go func(i int, chunkInfo *metadataPb.ChunkInfo) {
-			defer wg.Done()
-			// Read chunk data
-			chunkOffset := int64(i) * c.chunkSize
-			chunkSize := c.chunkSize
-			if chunkOffset+chunkSize > fileSize {
-				chunkSize = fileSize - chunkOffset
-			}
-			chunkData := make([]byte, chunkSize)
-			n, err := file.ReadAt(chunkData, chunkOffset)
-			if err != nil && err != io.EOF {
-				errChan <- fmt.Errorf("failed to read chunk %s: %v", chunkInfo.ChunkId, err)
-				return
-			}
-			chunkData = chunkData[:n]
+// UploadFile uploads the local file at filePath to remotePath in the
+// distributed file system, creating missing parent directories. An empty
+// remotePath stores the file under its base name in the root directory. If
+// the upload fails after chunks were allocated the error is an *UploadError,
+// and the upload can be finished with ResumeUpload.
+func (c *Client) UploadFile(ctx context.Context, filePath, remotePath string) error {
+	// Get file info
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %v", err)
+	}
 
-			// Connect to Storage Node
-			storageClient, err := c.getStorageClient(chunkInfo.StorageNode)
-			if err != nil {
-				errChan <- fmt.Errorf("failed to connect to storage node: %v", err)
-				return
-			}
+	fileName := remotePath
+	if fileName == "" {
+		fileName = filepath.Base(filePath)
+	}
+	fileSize := fileInfo.Size()
 
-			// Store the chunk
-			_, err = storageClient.StoreChunk(context.Background(), &storagePb.StoreChunkRequest{
-				ChunkId: chunkInfo.ChunkId,
-				Data:    chunkData,
-			})
-			if err != nil {
-				errChan <- fmt.Errorf("failed to store chunk %s: %v", chunkInfo.ChunkId, err)
-				return
-			}
+	// Open the file
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
 
-			// Update progress
-			progress <- int64(n)
+	// Split the file the way the metadata service expects
+	chunkSize, err := c.chunkSize(ctx)
+	if err != nil {
+		return err
+	}
 
-			log.Printf("Chunk %s uploaded successfully.", chunkInfo.ChunkId)
-		}(i, chunkInfo)
+	// Checksum every chunk so corruption can be detected end to end
+	checksums, err := chunkChecksums(file, fileSize, chunkSize)
+	if err != nil {
+		return err
 	}
-	wg.Wait()
-	close(progress)
-	close(errChan)
 
-	// Check for errors
-	if len(errChan) > 0 {
-		for err := range errChan {
-			log.Println(err)
-		}
-		return fmt.Errorf("upload failed due to errors during chunk upload")
+	// Request chunk allocation from Metadata Service
+	var allocResp *metadataPb.AllocateChunksResponse
+	err = c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		allocResp, err = metadataClient.AllocateChunks(ctx, &metadataPb.CreateFileRequest{
+			FileName:       fileName,
+			FileSize:       fileSize,
+			ChunkChecksums: checksums,
+			ChunkSize:      chunkSize,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to allocate chunks: %w", err)
+	}
+
+	// Upload each chunk, then publish the file
+	if err := c.uploadChunks(ctx, file, fileSize, chunkSize, allocResp.Chunks, nil); err != nil {
+		return &UploadError{UploadID: allocResp.UploadId, Err: err}
+	}
+	if err := c.commitUpload(ctx, allocResp.UploadId); err != nil {
+		return &UploadError{UploadID: allocResp.UploadId, Err: err}
 	}
 
 	return nil
 }
 
-// DownloadFile downloads a file from the distributed file system
-func (c *Client) DownloadFile(fileName string) error {
+// DownloadFile downloads the current version of a file from the distributed
+// file system into the download directory
+func (c *Client) DownloadFile(ctx context.Context, fileName string) error {
+	return c.DownloadVersion(ctx, fileName, 0)
+}
+
+// DownloadVersion downloads a version of a file from the distributed file
+// system into the download directory. Version 0 is the current version.
+func (c *Client) DownloadVersion(ctx context.Context, fileName string, version int64) error {
+	return c.download(ctx, &metadataPb.GetFileRequest{FileName: fileName, Version: version})
+}
+
+// download downloads the file described by req into the download directory
+func (c *Client) download(ctx context.Context, req *metadataPb.GetFileRequest) error {
+	fileName := req.FileName
+
 	// Request file info from Metadata Service
-	fileInfoResp, err := c.metadataClient.GetFileInfo(context.Background(), &metadataPb.GetFileRequest{
-		FileName: fileName,
-	})
+	fileInfoResp, err := c.getFileInfo(ctx, req)
 	if err != nil {
-		return fmt.Errorf("failed to get file info: %v", err)
+		return err
 	}
-
-	// Define the download path
-	downloadDir := "dfs_downloads"
+	chunkSize := fileInfoResp.Info.GetChunkSize()
 
 	// Create the download directory if it doesn't exist
-	err = os.MkdirAll(downloadDir, os.ModePerm)
+	err = os.MkdirAll(c.opts.downloadDir, os.ModePerm)
 	if err != nil {
 		return fmt.Errorf("failed to create download directory: %v", err)
 	}
 
 	// Create the full path for the downloaded file
-	downloadPath := filepath.Join(downloadDir, fileName)
+	downloadPath := filepath.Join(c.opts.downloadDir, filepath.Base(fileName))
 
 	// Create or truncate the local file
 	file, err := os.Create(downloadPath)
@@ -175,84 +420,106 @@ func (c *Client) DownloadFile(fileName string) error {
 
 	// Download each chunk
 	var wg sync.WaitGroup
-	progress := make(chan int64)
-	totalSize := int64(len(fileInfoResp.Chunks)) * c.chunkSize
-
-	// Start a goroutine to display download progress
-	go func() {
-		var downloaded int64
-		for n := range progress {
-			downloaded += n
-			percent := float64(downloaded) / float64(totalSize) * 100
-			fmt.Printf("\rDownloading... %.2f%% complete", percent)
-		}
-		fmt.Println("\nDownload complete.")
-	}()
-
+	progress, finish := c.trackProgress("Downloading", "Download", fileInfoResp.Info.GetFileSize())
 	errChan := make(chan error, len(fileInfoResp.Chunks))
-	chunkDataMap := make([][]byte, len(fileInfoResp.Chunks))
 
 	for i, chunkInfo := range fileInfoResp.Chunks {
+		if err := c.acquire(ctx); err != nil {
+			errChan <- err
+			break
+		}
 		wg.Add(1)
 		go func(i int, chunkInfo *metadataPb.ChunkInfo) {
 			defer wg.Done()
-			// Connect to Storage Node
-			storageClient, err := c.getStorageClient(chunkInfo.StorageNode)
-			if err != nil {
-				errChan <- fmt.Errorf("failed to connect to storage node: %v", err)
-				return
-			}
-
-			// Retrieve the chunk
-			resp, err := storageClient.RetrieveChunk(context.Background(), &storagePb.RetrieveChunkRequest{
-				ChunkId: chunkInfo.ChunkId,
-			})
+			defer c.release()
+			// Stream the chunk from the first replica that responds into place
+			n, err := c.retrieveChunk(ctx, chunkInfo, file, int64(i)*chunkSize)
 			if err != nil {
-				errChan <- fmt.Errorf("failed to retrieve chunk %s: %v", chunkInfo.ChunkId, err)
+				errChan <- err
 				return
 			}
 
-			chunkDataMap[i] = resp.Data
-
 			// Update progress
-			progress <- int64(len(resp.Data))
+			progress <- n
 
-			log.Printf("Chunk %s downloaded successfully.", chunkInfo.ChunkId)
+			c.opts.logger.Printf("Chunk %s downloaded successfully.", chunkInfo.ChunkId)
 		}(i, chunkInfo)
 	}
 	wg.Wait()
-	close(progress)
+	finish()
 	close(errChan)
 
 	// Check for errors
 	if len(errChan) > 0 {
 		for err := range errChan {
-			log.Println(err)
+			c.opts.logger.Printf("%v", err)
 		}
 		return fmt.Errorf("download failed due to errors during chunk download")
 	}
 
-	// Write chunks to file
-	for i, data := range chunkDataMap {
-		if data == nil {
-			return fmt.Errorf("missing data for chunk %d", i)
-		}
-		_, err := file.WriteAt(data, int64(i)*c.chunkSize)
-		if err != nil {
-			return fmt.Errorf("failed to write chunk %d to file: %v", i, err)
-		}
+	return nil
+}
+
+// ListFiles retrieves the list of all files from the Metadata Service
+func (c *Client) ListFiles(ctx context.Context) ([]*metadataPb.FileInfo, error) {
+	var listResp *metadataPb.ListFilesResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		listResp, err = metadataClient.ListFiles(ctx, &metadataPb.ListFilesRequest{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
 	}
+	return listResp.Files, nil
+}
 
+// ListVersions lists every version of a file, newest first
+func (c *Client) ListVersions(ctx context.Context, fileName string) ([]*metadataPb.FileInfo, error) {
+	var listResp *metadataPb.ListVersionsResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		listResp, err = metadataClient.ListVersions(ctx, &metadataPb.ListVersionsRequest{
+			FileName: fileName,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions of %s: %w", fileName, err)
+	}
+	return listResp.Versions, nil
+}
+
+// DeleteFile removes a file or a directory from the distributed file system.
+// A directory must be empty unless recursive is set. Chunks are reclaimed
+// from the storage nodes in the background.
+func (c *Client) DeleteFile(ctx context.Context, path string, recursive bool) error {
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		_, err := metadataClient.DeleteFile(ctx, &metadataPb.DeleteFileRequest{
+			FileName:  path,
+			Recursive: recursive,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", path, err)
+	}
 	return nil
 }
 
-// ListFiles retrieves the list of all files from the Metadata Service
-func (c *Client) ListFiles() ([]*metadataPb.FileInfo, error) { // Changed to []*FileInfo
-	listResp, err := c.metadataClient.ListFiles(context.Background(), &metadataPb.ListFilesRequest{})
+// RepairStatus retrieves the progress of chunk re-replication from the
+// Metadata Service leader
+func (c *Client) RepairStatus(ctx context.Context) (*metadataPb.GetRepairStatusResponse, error) {
+	var resp *metadataPb.GetRepairStatusResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		resp, err = metadataClient.GetRepairStatus(ctx, &metadataPb.GetRepairStatusRequest{})
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list files: %v", err)
+		return nil, fmt.Errorf("failed to get repair status: %w", err)
 	}
-	return listResp.Files, nil // Now returning []*FileInfo
+	return resp, nil
 }
 
 // getStorageClient retrieves or creates a StorageServiceClient for the given address with retry logic
@@ -267,11 +534,11 @@ func (c *Client) getStorageClient(address string) (storagePb.StorageServiceClien
 	var conn *grpc.ClientConn
 	var err error
 	for i := 0; i < 3; i++ { // Retry up to 3 times
-		conn, err = grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		conn, err = grpc.Dial(address, c.opts.dialOptions...)
 		if err == nil {
 			break
 		}
-		log.Printf("Retrying to connect to storage node at %s (attempt %d)", address, i+1)
+		c.opts.logger.Printf("Retrying to connect to storage node at %s (attempt %d)", address, i+1)
 		time.Sleep(2 * time.Second)
 	}
 	if err != nil {
@@ -280,5 +547,6 @@ func (c *Client) getStorageClient(address string) (storagePb.StorageServiceClien
 
 	client := storagePb.NewStorageServiceClient(conn)
 	c.storageClients[address] = client
+	c.conns = append(c.conns, conn)
 	return client, nil
 }
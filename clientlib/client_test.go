@@ -0,0 +1,146 @@
+// clientlib/client_test.go
+
+package clientlib
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	metadataPb "dfs/proto/metadata"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeMetadataServer is a metadata node that reports leader as the leader
+// and answers Mkdir with mkdirErr
+type fakeMetadataServer struct {
+	metadataPb.UnimplementedMetadataServiceServer
+	addr     string
+	leader   string
+	mkdirErr error
+
+	mu     sync.Mutex
+	mkdirs int
+}
+
+func (f *fakeMetadataServer) GetLeader(ctx context.Context, req *metadataPb.GetLeaderRequest) (*metadataPb.GetLeaderResponse, error) {
+	return &metadataPb.GetLeaderResponse{
+		LeaderAddress: f.leader,
+		IsLeader:      f.leader == f.addr,
+		ChunkSize:     1024,
+	}, nil
+}
+
+func (f *fakeMetadataServer) Mkdir(ctx context.Context, req *metadataPb.MkdirRequest) (*metadataPb.MkdirResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mkdirs++
+	if f.mkdirErr != nil {
+		return nil, f.mkdirErr
+	}
+	return &metadataPb.MkdirResponse{}, nil
+}
+
+// calls returns how many Mkdir calls reached the node
+func (f *fakeMetadataServer) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.mkdirs
+}
+
+// serveMetadata starts srv on a local port and sets its address
+func serveMetadata(t *testing.T, srv *fakeMetadataServer) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv.addr = lis.Addr().String()
+	grpcServer := grpc.NewServer()
+	metadataPb.RegisterMetadataServiceServer(grpcServer, srv)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+}
+
+// closedAddr returns a local address nothing listens on
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+	return addr
+}
+
+func TestCallMetadataRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		firstErr  error // Returned by the node the client starts with
+		firstDown bool  // The node the client starts with is unreachable
+		code      codes.Code
+		first     int // Mkdir calls reaching each node
+		second    int
+	}{
+		{
+			name:     "rejected by a follower",
+			firstErr: notLeader("not the leader"),
+			first:    1,
+			second:   1,
+		},
+		{
+			name:     "leadership lost while committing",
+			firstErr: status.Error(codes.Unavailable, "lost leadership before the command was committed"),
+			code:     codes.Unavailable,
+			first:    1,
+		},
+		{
+			name:      "leader unreachable",
+			firstDown: true,
+			second:    1,
+		},
+		{
+			name:     "other errors",
+			firstErr: status.Error(codes.AlreadyExists, "exists"),
+			code:     codes.AlreadyExists,
+			first:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := &fakeMetadataServer{mkdirErr: tt.firstErr}
+			second := &fakeMetadataServer{}
+			serveMetadata(t, first)
+			serveMetadata(t, second)
+			first.leader, second.leader = second.addr, second.addr
+
+			c, err := NewClient(WithMetadataAddrs(first.addr, second.addr))
+			if err != nil {
+				t.Fatalf("new client: %v", err)
+			}
+			defer c.Close()
+
+			// Send the first attempt to the wrong node
+			c.mu.Lock()
+			c.leaderAddr = first.addr
+			if tt.firstDown {
+				c.leaderAddr = closedAddr(t)
+			}
+			c.mu.Unlock()
+
+			err = c.Mkdir(context.Background(), "d", false)
+			if status.Code(err) != tt.code {
+				t.Fatalf("Mkdir error = %v, want code %v", err, tt.code)
+			}
+			if first.calls() != tt.first || second.calls() != tt.second {
+				t.Errorf("calls = %d and %d, want %d and %d", first.calls(), second.calls(), tt.first, tt.second)
+			}
+		})
+	}
+}
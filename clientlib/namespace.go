@@ -0,0 +1,110 @@
+// clientlib/namespace.go
+
+package clientlib
+
+import (
+	"context"
+	"fmt"
+
+	metadataPb "dfs/proto/metadata"
+)
+
+// Stat describes the file or directory at path
+func (c *Client) Stat(ctx context.Context, path string) (*metadataPb.DirEntry, error) {
+	var statResp *metadataPb.StatResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		statResp, err = metadataClient.Stat(ctx, &metadataPb.StatRequest{
+			Path: path,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	return statResp.Entry, nil
+}
+
+// Mkdir creates a directory. With parents, missing parent directories are
+// created too and an existing directory is not an error.
+func (c *Client) Mkdir(ctx context.Context, path string, parents bool) error {
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		_, err := metadataClient.Mkdir(ctx, &metadataPb.MkdirRequest{
+			Path:    path,
+			Parents: parents,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", path, err)
+	}
+	return nil
+}
+
+// ListDir lists the files and directories directly inside a directory, sorted
+// by path. The root directory is "" or "/".
+func (c *Client) ListDir(ctx context.Context, path string) ([]*metadataPb.DirEntry, error) {
+	var listResp *metadataPb.ListDirResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		listResp, err = metadataClient.ListDir(ctx, &metadataPb.ListDirRequest{
+			Path: path,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list directory %s: %w", path, err)
+	}
+	return listResp.Entries, nil
+}
+
+// Rename atomically moves a file or a directory tree to dst. Unless
+// overwrite is set dst must not exist; with it, a file at dst is replaced by
+// a file, or an empty directory by a directory. Only metadata changes; no
+// chunk data is copied.
+func (c *Client) Rename(ctx context.Context, src, dst string, overwrite bool) error {
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		_, err := metadataClient.Rename(ctx, &metadataPb.RenameRequest{
+			Src:       src,
+			Dst:       dst,
+			Overwrite: overwrite,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rename %s to %s: %w", src, dst, err)
+	}
+	return nil
+}
+
+// Chmod changes the permission bits of the file or directory at path. Only
+// its owner and administrators may change them.
+func (c *Client) Chmod(ctx context.Context, path string, mode uint32) (*metadataPb.DirEntry, error) {
+	return c.setPermissions(ctx, &metadataPb.SetPermissionsRequest{
+		Path: path,
+		Mode: &mode,
+	})
+}
+
+// Chown gives the file or directory at path to another user. Only
+// administrators may change owners.
+func (c *Client) Chown(ctx context.Context, path, owner string) (*metadataPb.DirEntry, error) {
+	return c.setPermissions(ctx, &metadataPb.SetPermissionsRequest{
+		Path:  path,
+		Owner: owner,
+	})
+}
+
+// setPermissions changes the mode or the owner of an entry
+func (c *Client) setPermissions(ctx context.Context, req *metadataPb.SetPermissionsRequest) (*metadataPb.DirEntry, error) {
+	var setResp *metadataPb.SetPermissionsResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		setResp, err = metadataClient.SetPermissions(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to change permissions of %s: %w", req.Path, err)
+	}
+	return setResp.Entry, nil
+}
@@ -0,0 +1,122 @@
+// clientlib/options.go
+
+package clientlib
+
+import (
+	"io"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Logger receives the client's diagnostic messages. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Option configures a Client
+type Option func(*options)
+
+// options holds the settings of a Client
+type options struct {
+	metadataAddrs   []string
+	rpcTimeout      time.Duration
+	transferTimeout time.Duration
+	concurrency     int
+	dialOptions     []grpc.DialOption
+	logger          Logger
+	progress        io.Writer
+	downloadDir     string
+	chunkCache      *ChunkCache
+	token           string
+}
+
+// defaultOptions returns the settings used when no options are given
+func defaultOptions() options {
+	return options{
+		metadataAddrs:   []string{"localhost:50051"},
+		rpcTimeout:      10 * time.Second,
+		transferTimeout: 5 * time.Minute,
+		concurrency:     8,
+		dialOptions:     []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		logger:          log.Default(),
+		downloadDir:     "dfs_downloads",
+	}
+}
+
+// WithMetadataAddrs sets the nodes of the metadata cluster. The current leader
+// is discovered from them. Defaults to localhost:50051.
+func WithMetadataAddrs(addrs ...string) Option {
+	return func(o *options) {
+		o.metadataAddrs = addrs
+	}
+}
+
+// WithRPCTimeout bounds each call to the metadata service. Defaults to 10
+// seconds.
+func WithRPCTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.rpcTimeout = timeout
+	}
+}
+
+// WithTransferTimeout bounds the transfer of a single chunk to or from a
+// storage node. Defaults to 5 minutes.
+func WithTransferTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.transferTimeout = timeout
+	}
+}
+
+// WithConcurrency limits how many chunks are transferred at once by a single
+// operation. Defaults to 8.
+func WithConcurrency(n int) Option {
+	return func(o *options) {
+		o.concurrency = n
+	}
+}
+
+// WithDialOptions adds gRPC dial options used for every connection, such as
+// transport credentials. They are applied after the default insecure
+// credentials, so they can replace them.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dialOptions = append(o.dialOptions, opts...)
+	}
+}
+
+// WithLogger sets where the client logs retries and per-chunk progress.
+// Defaults to the standard logger.
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithProgress prints the progress of uploads and downloads to w. By default
+// no progress is printed.
+func WithProgress(w io.Writer) Option {
+	return func(o *options) {
+		o.progress = w
+	}
+}
+
+// WithDownloadDir sets the directory DownloadFile writes to. Defaults to
+// dfs_downloads.
+func WithDownloadDir(dir string) Option {
+	return func(o *options) {
+		o.downloadDir = dir
+	}
+}
+
+// WithToken authenticates every call with an API token. A token set on a
+// call's context with auth.WithToken takes its place, so servers can act on
+// behalf of their own callers. By default calls carry no token, which only
+// works while the cluster has authentication disabled.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
@@ -0,0 +1,115 @@
+// clientlib/read.go
+
+package clientlib
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	metadataPb "dfs/proto/metadata"
+)
+
+// ReadAt reads length bytes of a file starting at offset, fetching only the
+// chunks that overlap the range. The result is shorter than length if the
+// range extends past the end of the file.
+func (c *Client) ReadAt(ctx context.Context, fileName string, offset, length int64) ([]byte, error) {
+	if offset < 0 || length < 0 {
+		return nil, fmt.Errorf("invalid range: offset %d, length %d", offset, length)
+	}
+
+	fileInfoResp, err := c.getFileInfo(ctx, &metadataPb.GetFileRequest{FileName: fileName})
+	if err != nil {
+		return nil, err
+	}
+	return c.readRange(ctx, fileInfoResp, offset, length)
+}
+
+// getFileInfo retrieves the chunks and summary of a file, or of a version of
+// it or its copy in a snapshot, from the Metadata Service
+func (c *Client) getFileInfo(ctx context.Context, req *metadataPb.GetFileRequest) (*metadataPb.GetFileResponse, error) {
+	fileName := req.FileName
+	var fileInfoResp *metadataPb.GetFileResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		fileInfoResp, err = metadataClient.GetFileInfo(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file info: %w", err)
+	}
+	if fileInfoResp.Info.GetChunkSize() <= 0 {
+		return nil, fmt.Errorf("metadata service reported invalid chunk size %d for %s", fileInfoResp.Info.GetChunkSize(), fileName)
+	}
+	return fileInfoResp, nil
+}
+
+// readRange reads a byte range of a file described by fileInfoResp, fetching
+// the overlapping chunks in parallel
+func (c *Client) readRange(ctx context.Context, fileInfoResp *metadataPb.GetFileResponse, offset, length int64) ([]byte, error) {
+	// Clamp the range to the end of the file
+	fileSize := fileInfoResp.Info.GetFileSize()
+	if offset >= fileSize {
+		return nil, nil
+	}
+	if length > fileSize-offset {
+		length = fileSize - offset
+	}
+	if length == 0 {
+		return nil, nil
+	}
+
+	buf := make(bufferAt, length)
+	chunkSize := fileInfoResp.Info.GetChunkSize()
+	first := offset / chunkSize
+	last := (offset + length - 1) / chunkSize
+
+	if last >= int64(len(fileInfoResp.Chunks)) {
+		return nil, fmt.Errorf("file %s is missing chunk %d", fileInfoResp.Info.GetFileName(), last)
+	}
+
+	var wg sync.WaitGroup
+	errChan := make(chan error, last-first+1)
+
+	for i := first; i <= last; i++ {
+		// The part of the range that falls within this chunk
+		chunkStart := i * chunkSize
+		start := max(offset, chunkStart)
+		end := min(offset+length, chunkStart+chunkSize)
+
+		if err := c.acquire(ctx); err != nil {
+			errChan <- err
+			break
+		}
+		wg.Add(1)
+		go func(chunkInfo *metadataPb.ChunkInfo, chunkOffset, n, bufOffset int64) {
+			defer wg.Done()
+			defer c.release()
+			read, err := c.retrieveRange(ctx, chunkInfo, chunkOffset, n, buf, bufOffset)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			if read != n {
+				errChan <- fmt.Errorf("short read from chunk %s: got %d of %d bytes", chunkInfo.ChunkId, read, n)
+			}
+		}(fileInfoResp.Chunks[i], start-chunkStart, end-start, start-offset)
+	}
+	wg.Wait()
+	close(errChan)
+
+	if err := <-errChan; err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// bufferAt is a byte slice that chunks can be written into out of order
+type bufferAt []byte
+
+func (b bufferAt) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > int64(len(b)) {
+		return 0, fmt.Errorf("write of %d bytes at offset %d exceeds buffer of %d bytes", len(p), off, len(b))
+	}
+	return copy(b[off:], p), nil
+}
@@ -0,0 +1,69 @@
+// clientlib/read_test.go
+
+package clientlib
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// putFile stores content in f as a committed file split into chunks
+func (f *fakeCluster) putFile(fileName, content string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	uploadID := f.newUpload(fileName)
+	file := f.uploads[uploadID]
+	delete(f.uploads, uploadID)
+	file.fileSize = int64(len(content))
+	for i := 0; int64(i)*f.chunkSize < file.fileSize; i++ {
+		data := content[int64(i)*f.chunkSize : min(int64(i+1)*f.chunkSize, file.fileSize)]
+		chunk := f.newChunk(uploadID, i, crc32Of(data))
+		f.data[chunk.ChunkId] = []byte(data)
+		file.chunks = append(file.chunks, chunk)
+	}
+	f.files[fileName] = file
+}
+
+func TestReadAt(t *testing.T) {
+	const content = "0123456789abcdefghij" // Five chunks of four bytes
+
+	tests := []struct {
+		name      string
+		offset    int64
+		length    int64
+		want      string
+		retrieves int // Chunks fetched from storage
+		err       bool
+	}{
+		{name: "whole file", offset: 0, length: 20, want: content, retrieves: 5},
+		{name: "within one chunk", offset: 5, length: 2, want: "56", retrieves: 1},
+		{name: "across chunks", offset: 3, length: 6, want: "345678", retrieves: 3},
+		{name: "past the end", offset: 18, length: 10, want: "ij", retrieves: 1},
+		{name: "huge length", offset: 18, length: math.MaxInt64, want: "ij", retrieves: 1},
+		{name: "at the end", offset: 20, length: 5, want: ""},
+		{name: "beyond the end", offset: 100, length: 5, want: ""},
+		{name: "empty range", offset: 4, length: 0, want: ""},
+		{name: "negative offset", offset: -1, length: 5, err: true},
+		{name: "negative length", offset: 0, length: -1, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, c := newFakeCluster(t, 4)
+			f.putFile("file.txt", content)
+
+			data, err := c.ReadAt(context.Background(), "file.txt", tt.offset, tt.length)
+			if (err != nil) != tt.err {
+				t.Fatalf("ReadAt error = %v, want error %v", err, tt.err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("ReadAt = %q, want %q", data, tt.want)
+			}
+			if _, retrieves := f.counts(); retrieves != tt.retrieves {
+				t.Errorf("fetched %d chunks, want %d", retrieves, tt.retrieves)
+			}
+		})
+	}
+}
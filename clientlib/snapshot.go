@@ -0,0 +1,105 @@
+// clientlib/snapshot.go
+
+package clientlib
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	metadataPb "dfs/proto/metadata"
+)
+
+// CreateSnapshot takes a read-only snapshot named name of the directory at
+// path, or of the whole namespace if path is "" or "/". Snapshots share
+// chunks with the live files, so they are cheap to take.
+func (c *Client) CreateSnapshot(ctx context.Context, name, path string) (*metadataPb.SnapshotInfo, error) {
+	var createResp *metadataPb.CreateSnapshotResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		createResp, err = metadataClient.CreateSnapshot(ctx, &metadataPb.CreateSnapshotRequest{
+			Name: name,
+			Path: path,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot %s: %w", name, err)
+	}
+	return createResp.Snapshot, nil
+}
+
+// ListSnapshots lists every snapshot, sorted by name
+func (c *Client) ListSnapshots(ctx context.Context) ([]*metadataPb.SnapshotInfo, error) {
+	var listResp *metadataPb.ListSnapshotsResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		listResp, err = metadataClient.ListSnapshots(ctx, &metadataPb.ListSnapshotsRequest{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	return listResp.Snapshots, nil
+}
+
+// DeleteSnapshot removes a snapshot. Chunks only the snapshot used are
+// reclaimed in the background.
+func (c *Client) DeleteSnapshot(ctx context.Context, name string) error {
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		_, err := metadataClient.DeleteSnapshot(ctx, &metadataPb.DeleteSnapshotRequest{
+			Name: name,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot %s: %w", name, err)
+	}
+	return nil
+}
+
+// StatSnapshot describes the file or directory at path as of a snapshot
+func (c *Client) StatSnapshot(ctx context.Context, snapshot, path string) (*metadataPb.DirEntry, error) {
+	var statResp *metadataPb.StatResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		statResp, err = metadataClient.Stat(ctx, &metadataPb.StatRequest{
+			Path:     path,
+			Snapshot: snapshot,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s in snapshot %s: %w", path, snapshot, err)
+	}
+	return statResp.Entry, nil
+}
+
+// ListSnapshotDir lists the files and directories directly inside a directory
+// as of a snapshot, sorted by path
+func (c *Client) ListSnapshotDir(ctx context.Context, snapshot, path string) ([]*metadataPb.DirEntry, error) {
+	var listResp *metadataPb.ListDirResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		listResp, err = metadataClient.ListDir(ctx, &metadataPb.ListDirRequest{
+			Path:     path,
+			Snapshot: snapshot,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list directory %s in snapshot %s: %w", path, snapshot, err)
+	}
+	return listResp.Entries, nil
+}
+
+// OpenSnapshot opens a file as of a snapshot for reading like Open
+func (c *Client) OpenSnapshot(ctx context.Context, snapshot, fileName string) (io.ReadSeekCloser, error) {
+	return c.open(ctx, &metadataPb.GetFileRequest{FileName: fileName, Snapshot: snapshot})
+}
+
+// DownloadSnapshot downloads a file as of a snapshot into the download
+// directory
+func (c *Client) DownloadSnapshot(ctx context.Context, snapshot, fileName string) error {
+	return c.download(ctx, &metadataPb.GetFileRequest{FileName: fileName, Snapshot: snapshot})
+}
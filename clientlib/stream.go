@@ -0,0 +1,252 @@
+// clientlib/stream.go
+
+package clientlib
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+
+	metadataPb "dfs/proto/metadata"
+)
+
+// fileReader reads a file from the distributed file system one chunk at a
+// time. Every chunk is checked against its checksum as it is fetched.
+type fileReader struct {
+	c         *Client
+	ctx       context.Context
+	fileInfo  *metadataPb.GetFileResponse
+	size      int64
+	chunkSize int64
+	pos       int64
+	chunk     []byte // The chunk containing pos, once fetched
+	chunkIdx  int64  // Index of chunk, or -1 if none is loaded
+	closed    bool
+}
+
+// Open opens the current version of a file in the distributed file system for
+// reading. The returned reader holds at most one chunk in memory at a time,
+// and fetches chunks with ctx until it is closed, through the chunk cache if
+// the client has one.
+func (c *Client) Open(ctx context.Context, fileName string) (io.ReadSeekCloser, error) {
+	return c.OpenVersion(ctx, fileName, 0)
+}
+
+// OpenVersion opens a version of a file for reading like Open. Version 0 is
+// the current version.
+func (c *Client) OpenVersion(ctx context.Context, fileName string, version int64) (io.ReadSeekCloser, error) {
+	return c.open(ctx, &metadataPb.GetFileRequest{FileName: fileName, Version: version})
+}
+
+// open opens the file described by req for reading
+func (c *Client) open(ctx context.Context, req *metadataPb.GetFileRequest) (io.ReadSeekCloser, error) {
+	fileInfoResp, err := c.getFileInfo(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileReader{
+		c:         c,
+		ctx:       ctx,
+		fileInfo:  fileInfoResp,
+		size:      fileInfoResp.Info.GetFileSize(),
+		chunkSize: fileInfoResp.Info.GetChunkSize(),
+		chunkIdx:  -1,
+	}, nil
+}
+
+func (r *fileReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+
+	// Fetch the chunk holding the current position
+	idx := r.pos / r.chunkSize
+	if idx != r.chunkIdx {
+		if idx >= int64(len(r.fileInfo.Chunks)) {
+			return 0, fmt.Errorf("file %s is missing chunk %d", r.fileInfo.Info.GetFileName(), idx)
+		}
+
+		chunk, err := r.c.fetchChunk(r.ctx, r.fileInfo.Chunks[idx], min(r.chunkSize, r.size-idx*r.chunkSize))
+		if err != nil {
+			return 0, err
+		}
+		r.chunk = chunk
+		r.chunkIdx = idx
+	}
+
+	n := copy(p, r.chunk[r.pos-r.chunkIdx*r.chunkSize:])
+	r.pos += int64(n)
+	return n, nil
+}
+
+func (r *fileReader) Seek(offset int64, whence int) (int64, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+func (r *fileReader) Close() error {
+	r.closed = true
+	r.chunk = nil
+	return nil
+}
+
+// fileWriter writes a file to the distributed file system one chunk at a
+// time. Each chunk is buffered in memory until it is full, then stored on its
+// replicas before more data is accepted.
+type fileWriter struct {
+	c         *Client
+	ctx       context.Context
+	uploadID  string
+	chunkSize int64
+	buf       []byte
+	index     int32     // Index of the chunk being filled
+	size      int64     // Bytes stored so far
+	md5       hash.Hash // Of the bytes stored so far, recorded with the file
+	err       error     // First error, after which the upload is abandoned
+	closed    bool
+}
+
+// Create starts writing a new file to the distributed file system. The file
+// only appears once the writer is closed without error; if writing fails the
+// upload is abandoned and expires on the metadata service. Chunks are stored
+// with ctx until the writer is closed.
+func (c *Client) Create(ctx context.Context, fileName string) (io.WriteCloser, error) {
+	var createResp *metadataPb.CreateUploadResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		createResp, err = metadataClient.CreateUpload(ctx, &metadataPb.CreateUploadRequest{
+			FileName: fileName,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	if createResp.ChunkSize <= 0 {
+		return nil, fmt.Errorf("metadata service reported invalid chunk size %d", createResp.ChunkSize)
+	}
+
+	return &fileWriter{
+		c:         c,
+		ctx:       ctx,
+		uploadID:  createResp.UploadId,
+		chunkSize: createResp.ChunkSize,
+		md5:       md5.New(),
+	}, nil
+}
+
+func (w *fileWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// Fill the current chunk
+		n := min(len(p), int(w.chunkSize)-len(w.buf))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+
+		// Store it once it is full
+		if int64(len(w.buf)) == w.chunkSize {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flush allocates and stores the buffered chunk
+func (w *fileWriter) flush() error {
+	checksum := crc32.Checksum(w.buf, castagnoli)
+
+	var allocResp *metadataPb.AllocateChunkResponse
+	err := w.c.callMetadata(w.ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		allocResp, err = metadataClient.AllocateChunk(ctx, &metadataPb.AllocateChunkRequest{
+			UploadId: w.uploadID,
+			Index:    w.index,
+			Checksum: checksum,
+		})
+		return err
+	})
+	if err != nil {
+		w.err = fmt.Errorf("failed to allocate chunk %d: %w", w.index, err)
+		return w.err
+	}
+
+	if err := w.c.storeChunk(w.ctx, allocResp.Chunk, bytes.NewReader(w.buf), 0, int64(len(w.buf))); err != nil {
+		w.err = err
+		return w.err
+	}
+
+	w.size += int64(len(w.buf))
+	w.md5.Write(w.buf)
+	w.index++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Close stores the last partial chunk and publishes the file
+func (w *fileWriter) Close() error {
+	if w.closed {
+		return os.ErrClosed
+	}
+	w.closed = true
+
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.buf) > 0 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	w.buf = nil
+
+	err := w.c.callMetadata(w.ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		_, err := metadataClient.CommitFile(ctx, &metadataPb.CommitFileRequest{
+			UploadId:   w.uploadID,
+			FileSize:   w.size,
+			ContentMd5: hex.EncodeToString(w.md5.Sum(nil)),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to commit file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// clientlib/stream_test.go
+
+package clientlib
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		chunks int
+	}{
+		{name: "empty file", writes: nil, chunks: 0},
+		{name: "partial chunk", writes: []string{"ab"}, chunks: 1},
+		{name: "exactly one chunk", writes: []string{"abcd"}, chunks: 1},
+		{name: "writes spanning chunks", writes: []string{"ab", "cdefg", "", "hijklmnopq"}, chunks: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, c := newFakeCluster(t, 4)
+
+			w, err := c.Create(context.Background(), "file.txt")
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			for _, data := range tt.writes {
+				if n, err := w.Write([]byte(data)); err != nil || n != len(data) {
+					t.Fatalf("Write = %d, %v, want %d", n, err, len(data))
+				}
+				if _, exists := f.fileData("file.txt"); exists {
+					t.Fatalf("file visible before the writer was closed")
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			want := strings.Join(tt.writes, "")
+			if data, _ := f.fileData("file.txt"); data != want {
+				t.Errorf("file = %q, want %q", data, want)
+			}
+			if stores, _ := f.counts(); stores != tt.chunks {
+				t.Errorf("stored %d chunks, want %d", stores, tt.chunks)
+			}
+			if err := w.Close(); !errors.Is(err, os.ErrClosed) {
+				t.Errorf("second Close = %v, want %v", err, os.ErrClosed)
+			}
+		})
+	}
+}
+
+func TestCreateFailedChunk(t *testing.T) {
+	f, c := newFakeCluster(t, 4)
+
+	// The second chunk cannot be stored, so the file must never appear
+	f.failOnce["upload0-1"] = true
+	w, err := c.Create(context.Background(), "file.txt")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := w.Write([]byte("abcdefgh")); err == nil {
+		t.Fatalf("Write succeeded despite a failed chunk")
+	}
+	if _, err := w.Write([]byte("ij")); err == nil {
+		t.Errorf("Write after a failure succeeded")
+	}
+	if err := w.Close(); err == nil {
+		t.Errorf("Close published a failed upload")
+	}
+	if _, exists := f.fileData("file.txt"); exists {
+		t.Errorf("file published by a failed upload")
+	}
+}
+
+func TestCreateRecordsMD5(t *testing.T) {
+	f, c := newFakeCluster(t, 4)
+
+	var commitMD5 string
+	f.onCommit = func(md5 string) { commitMD5 = md5 }
+
+	w, err := c.Create(context.Background(), "file.txt")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	content := "0123456789"
+	if _, err := io.WriteString(w, content); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	sum := md5.Sum([]byte(content))
+	if want := hex.EncodeToString(sum[:]); commitMD5 != want {
+		t.Errorf("committed MD5 = %q, want %q", commitMD5, want)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	const content = "0123456789abcdefghij" // Five chunks of four bytes
+	f, c := newFakeCluster(t, 4)
+	f.putFile("file.txt", content)
+
+	r, err := c.Open(context.Background(), "file.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer r.Close()
+
+	// Read in pieces that straddle chunk boundaries
+	var got []byte
+	buf := make([]byte, 3)
+	for {
+		n, err := r.Read(buf)
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+	}
+	if string(got) != content {
+		t.Errorf("read %q, want %q", got, content)
+	}
+	if _, retrieves := f.counts(); retrieves != 5 {
+		t.Errorf("fetched %d chunks, want each of the 5 once", retrieves)
+	}
+
+	// Seeking moves within and across chunks
+	seeks := []struct {
+		offset int64
+		whence int
+		pos    int64
+		want   string
+	}{
+		{offset: 6, whence: io.SeekStart, pos: 6, want: "6789"},
+		{offset: -8, whence: io.SeekCurrent, pos: 2, want: "23"},
+		{offset: -3, whence: io.SeekEnd, pos: 17, want: "hij"},
+		{offset: 5, whence: io.SeekEnd, pos: 25, want: ""},
+	}
+	for _, s := range seeks {
+		pos, err := r.Seek(s.offset, s.whence)
+		if err != nil || pos != s.pos {
+			t.Fatalf("Seek(%d, %d) = %d, %v, want %d", s.offset, s.whence, pos, err, s.pos)
+		}
+		data := make([]byte, len(s.want))
+		if _, err := io.ReadFull(r, data); err != nil {
+			t.Fatalf("read after Seek(%d, %d): %v", s.offset, s.whence, err)
+		}
+		if string(data) != s.want {
+			t.Errorf("read after Seek(%d, %d) = %q, want %q", s.offset, s.whence, data, s.want)
+		}
+	}
+	if _, err := r.Seek(-100, io.SeekCurrent); err == nil {
+		t.Errorf("seek to a negative position succeeded")
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := r.Read(buf); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Read after Close = %v, want %v", err, os.ErrClosed)
+	}
+}
+
+func TestOpenCorruptChunk(t *testing.T) {
+	f, c := newFakeCluster(t, 4)
+	f.putFile("file.txt", "01234567")
+	f.data["upload0-1"] = []byte("XXXX")
+
+	r, err := c.Open(context.Background(), "file.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer r.Close()
+
+	if _, err := io.ReadAll(r); err == nil {
+		t.Errorf("corrupt chunk read without error")
+	}
+}
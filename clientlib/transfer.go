@@ -0,0 +1,206 @@
+// clientlib/transfer.go
+
+package clientlib
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sync"
+
+	metadataPb "dfs/proto/metadata"
+	storagePb "dfs/proto/storage"
+)
+
+// frameSize is the amount of chunk data sent in a single stream frame
+const frameSize = 1024 * 1024 // 1MB
+
+// castagnoli is the CRC32C table used for chunk checksums
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// chunkChecksums computes the CRC32C of every chunk of a file of size bytes
+// split into chunks of chunkSize bytes
+func chunkChecksums(src io.ReaderAt, size, chunkSize int64) ([]uint32, error) {
+	var checksums []uint32
+	for offset := int64(0); offset < size; offset += chunkSize {
+		n := min(chunkSize, size-offset)
+
+		hash := crc32.New(castagnoli)
+		if _, err := io.Copy(hash, io.NewSectionReader(src, offset, n)); err != nil {
+			return nil, fmt.Errorf("failed to checksum chunk at offset %d: %v", offset, err)
+		}
+		checksums = append(checksums, hash.Sum32())
+	}
+	return checksums, nil
+}
+
+// storeChunk streams the size bytes at offset in src to every replica of a
+// chunk in parallel. The chunk is only stored once all replicas have accepted
+// it.
+func (c *Client) storeChunk(ctx context.Context, chunkInfo *metadataPb.ChunkInfo, src io.ReaderAt, offset, size int64) error {
+	if len(chunkInfo.Replicas) == 0 {
+		return fmt.Errorf("no replicas allocated for chunk %s", chunkInfo.ChunkId)
+	}
+
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(chunkInfo.Replicas))
+
+	for _, replica := range chunkInfo.Replicas {
+		wg.Add(1)
+		go func(replica string) {
+			defer wg.Done()
+			// Each replica reads its own view of the chunk
+			data := io.NewSectionReader(src, offset, size)
+			if err := c.sendChunk(ctx, replica, chunkInfo.ChunkId, chunkInfo.Checksum, data); err != nil {
+				errChan <- fmt.Errorf("failed to store chunk %s on %s: %v", chunkInfo.ChunkId, replica, err)
+			}
+		}(replica)
+	}
+	wg.Wait()
+	close(errChan)
+
+	// Report the first failure; the others are logged
+	var firstErr error
+	for err := range errChan {
+		if firstErr == nil {
+			firstErr = err
+			continue
+		}
+		c.opts.logger.Printf("%v", err)
+	}
+	return firstErr
+}
+
+// sendChunk streams a chunk read from data to a single storage node, which
+// rejects it if it does not match checksum
+func (c *Client) sendChunk(ctx context.Context, address, chunkID string, checksum *uint32, data io.Reader) error {
+	// Connect to Storage Node
+	storageClient, err := c.getStorageClient(address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to storage node: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.transferTimeout)
+	defer cancel()
+
+	stream, err := storageClient.StoreChunkStream(ctx)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, frameSize)
+	first := true
+	for {
+		n, readErr := data.Read(buf)
+		if readErr != nil && readErr != io.EOF {
+			stream.CloseSend()
+			return fmt.Errorf("failed to read chunk data: %v", readErr)
+		}
+
+		// The first frame names the chunk, even if the chunk is empty
+		if n > 0 || first {
+			frame := &storagePb.StoreChunkFrame{Data: buf[:n]}
+			if first {
+				frame.ChunkId = chunkID
+				frame.Checksum = checksum
+				first = false
+			}
+			if err := stream.Send(frame); err != nil {
+				// The server ended the stream; its status explains why
+				if err == io.EOF {
+					break
+				}
+				return err
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	_, err = stream.CloseAndRecv()
+	return err
+}
+
+// retrieveChunk streams a chunk into dst at offset from its replicas in
+// order, falling back to the next replica when one fails or returns data that
+// does not match the chunk's checksum. It returns the size of the chunk.
+func (c *Client) retrieveChunk(ctx context.Context, chunkInfo *metadataPb.ChunkInfo, dst io.WriterAt, offset int64) (int64, error) {
+	return c.retrieveRange(ctx, chunkInfo, 0, 0, dst, offset)
+}
+
+// retrieveRange streams length bytes starting at chunkOffset within a chunk
+// into dst at offset, falling back to the next replica when one fails. A
+// length of 0 reads to the end of the chunk. Only whole chunks can be checked
+// against the chunk's checksum. It returns the number of bytes read.
+func (c *Client) retrieveRange(ctx context.Context, chunkInfo *metadataPb.ChunkInfo, chunkOffset, length int64, dst io.WriterAt, offset int64) (int64, error) {
+	whole := chunkOffset == 0 && length == 0
+	lastErr := fmt.Errorf("no replicas recorded for chunk %s", chunkInfo.ChunkId)
+
+	for _, replica := range chunkInfo.Replicas {
+		// A failed replica may have written part of the range; the next one
+		// overwrites it from the start
+		hash := crc32.New(castagnoli)
+		n, err := c.receiveChunk(ctx, replica, chunkInfo.ChunkId, chunkOffset, length, io.MultiWriter(io.NewOffsetWriter(dst, offset), hash))
+		if err != nil {
+			// Give up once the caller has, rather than trying every replica
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			lastErr = fmt.Errorf("failed to retrieve chunk %s from %s: %v", chunkInfo.ChunkId, replica, err)
+			c.opts.logger.Printf("%v", lastErr)
+			continue
+		}
+
+		if whole && chunkInfo.Checksum != nil && hash.Sum32() != *chunkInfo.Checksum {
+			lastErr = fmt.Errorf("chunk %s from %s is corrupt: expected checksum %08x, got %08x", chunkInfo.ChunkId, replica, *chunkInfo.Checksum, hash.Sum32())
+			c.opts.logger.Printf("%v", lastErr)
+			continue
+		}
+
+		return n, nil
+	}
+
+	return 0, lastErr
+}
+
+// receiveChunk streams a byte range of a chunk from a single storage node
+// into dst
+func (c *Client) receiveChunk(ctx context.Context, address, chunkID string, offset, length int64, dst io.Writer) (int64, error) {
+	// Connect to Storage Node
+	storageClient, err := c.getStorageClient(address)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to storage node: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.transferTimeout)
+	defer cancel()
+
+	stream, err := storageClient.RetrieveChunkStream(ctx, &storagePb.RetrieveChunkRequest{
+		ChunkId: chunkID,
+		Offset:  offset,
+		Length:  length,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for {
+		frame, err := stream.Recv()
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		n, err := dst.Write(frame.Data)
+		total += int64(n)
+		if err != nil {
+			return 0, fmt.Errorf("failed to write chunk data: %v", err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// clientlib/upload.go
+
+package clientlib
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	metadataPb "dfs/proto/metadata"
+)
+
+// UploadError reports an upload that failed after its chunks were allocated.
+// The chunks already stored are kept until the upload expires, so it can be
+// finished with ResumeUpload using UploadID.
+type UploadError struct {
+	UploadID string
+	Err      error
+}
+
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("upload %s incomplete: %v", e.UploadID, e.Err)
+}
+
+func (e *UploadError) Unwrap() error {
+	return e.Err
+}
+
+// ResumeUpload finishes an interrupted upload of filePath, sending only the
+// chunks the storage nodes do not already hold. The file must not have changed
+// since the upload started.
+func (c *Client) ResumeUpload(ctx context.Context, filePath, uploadID string) error {
+	// Open the file
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %v", err)
+	}
+	fileSize := fileInfo.Size()
+
+	// Find out which chunks are already stored
+	var statusResp *metadataPb.GetUploadStatusResponse
+	err = c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		statusResp, err = metadataClient.GetUploadStatus(ctx, &metadataPb.GetUploadStatusRequest{
+			UploadId: uploadID,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get upload status: %w", err)
+	}
+
+	// Make sure the local file is the one the upload was started with
+	chunkSize := statusResp.ChunkSize
+	if chunkSize <= 0 {
+		return fmt.Errorf("upload %s reported invalid chunk size %d", uploadID, chunkSize)
+	}
+	checksums, err := chunkChecksums(file, fileSize, chunkSize)
+	if err != nil {
+		return err
+	}
+	if fileSize != statusResp.FileSize || len(checksums) != len(statusResp.Chunks) {
+		return fmt.Errorf("file %s has changed since upload %s started", filePath, uploadID)
+	}
+	for i, chunkInfo := range statusResp.Chunks {
+		if chunkInfo.Checksum != nil && *chunkInfo.Checksum != checksums[i] {
+			return fmt.Errorf("file %s has changed since upload %s started", filePath, uploadID)
+		}
+	}
+
+	stored := 0
+	for _, ok := range statusResp.Stored {
+		if ok {
+			stored++
+		}
+	}
+	c.opts.logger.Printf("Resuming upload %s of %s: %d of %d chunks already stored", uploadID, statusResp.FileName, stored, len(statusResp.Chunks))
+
+	// Upload the missing chunks, then publish the file
+	if err := c.uploadChunks(ctx, file, fileSize, chunkSize, statusResp.Chunks, statusResp.Stored); err != nil {
+		return &UploadError{UploadID: uploadID, Err: err}
+	}
+	if err := c.commitUpload(ctx, uploadID); err != nil {
+		return &UploadError{UploadID: uploadID, Err: err}
+	}
+
+	return nil
+}
+
+// uploadChunks stores the chunks of file on their replicas in parallel,
+// skipping the chunks marked in stored
+func (c *Client) uploadChunks(ctx context.Context, file *os.File, fileSize, chunkSize int64, chunks []*metadataPb.ChunkInfo, stored []bool) error {
+	var wg sync.WaitGroup
+	progress, finish := c.trackProgress("Uploading", "Upload", fileSize)
+	errChan := make(chan error, len(chunks))
+
+	for i, chunkInfo := range chunks {
+		// Locate the chunk within the file
+		chunkOffset := int64(i) * chunkSize
+		size := min(chunkSize, fileSize-chunkOffset)
+
+		// Chunks stored before an interruption count as done
+		if i < len(stored) && stored[i] {
+			progress <- size
+			continue
+		}
+
+		if err := c.acquire(ctx); err != nil {
+			errChan <- err
+			break
+		}
+		wg.Add(1)
+		go func(chunkInfo *metadataPb.ChunkInfo, chunkOffset, size int64) {
+			defer wg.Done()
+			defer c.release()
+			// Stream the chunk from the file to every replica
+			if err := c.storeChunk(ctx, chunkInfo, file, chunkOffset, size); err != nil {
+				errChan <- err
+				return
+			}
+
+			// Update progress
+			progress <- size
+
+			c.opts.logger.Printf("Chunk %s uploaded successfully.", chunkInfo.ChunkId)
+		}(chunkInfo, chunkOffset, size)
+	}
+	wg.Wait()
+	finish()
+	close(errChan)
+
+	// Check for errors
+	if len(errChan) > 0 {
+		for err := range errChan {
+			c.opts.logger.Printf("%v", err)
+		}
+		return fmt.Errorf("upload failed due to errors during chunk upload")
+	}
+
+	return nil
+}
+
+// commitUpload publishes the file of an upload once every chunk is stored
+func (c *Client) commitUpload(ctx context.Context, uploadID string) error {
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		_, err := metadataClient.CommitFile(ctx, &metadataPb.CommitFileRequest{
+			UploadId: uploadID,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to commit file: %w", err)
+	}
+	return nil
+}
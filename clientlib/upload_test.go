@@ -0,0 +1,362 @@
+// clientlib/upload_test.go
+
+package clientlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	metadataPb "dfs/proto/metadata"
+	storagePb "dfs/proto/storage"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeUpload is an upload known to fakeCluster
+type fakeUpload struct {
+	fileName string
+	fileSize int64
+	chunks   []*metadataPb.ChunkInfo
+}
+
+// fakeCluster is a metadata leader and the only storage node in one gRPC
+// server, keeping files and chunks in memory
+type fakeCluster struct {
+	metadataPb.UnimplementedMetadataServiceServer
+	storagePb.UnimplementedStorageServiceServer
+	addr      string
+	chunkSize int64
+
+	mu        sync.Mutex
+	uploads   map[string]*fakeUpload
+	files     map[string]*fakeUpload
+	data      map[string][]byte // Stored chunks by ID
+	failOnce  map[string]bool   // Chunks whose next store fails
+	uploadIDs int               // Uploads started so far
+	onCommit  func(md5 string)  // Sees the MD5 each commit reports, if set
+	stores    int               // Chunk stores that reached the node
+	retrieves int               // Chunk retrievals that reached the node
+}
+
+// newFakeCluster starts a fake cluster splitting files into chunkSize bytes
+// and returns a client connected to it
+func newFakeCluster(t *testing.T, chunkSize int64) (*fakeCluster, *Client) {
+	t.Helper()
+	f := &fakeCluster{
+		chunkSize: chunkSize,
+		uploads:   make(map[string]*fakeUpload),
+		files:     make(map[string]*fakeUpload),
+		data:      make(map[string][]byte),
+		failOnce:  make(map[string]bool),
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f.addr = lis.Addr().String()
+	grpcServer := grpc.NewServer()
+	metadataPb.RegisterMetadataServiceServer(grpcServer, f)
+	storagePb.RegisterStorageServiceServer(grpcServer, f)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	c, err := NewClient(WithMetadataAddrs(f.addr), WithDownloadDir(t.TempDir()))
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return f, c
+}
+
+func (f *fakeCluster) GetLeader(ctx context.Context, req *metadataPb.GetLeaderRequest) (*metadataPb.GetLeaderResponse, error) {
+	return &metadataPb.GetLeaderResponse{LeaderAddress: f.addr, IsLeader: true, ChunkSize: f.chunkSize}, nil
+}
+
+// newUpload records an upload and returns its ID; the caller holds f.mu
+func (f *fakeCluster) newUpload(fileName string) string {
+	uploadID := fmt.Sprintf("upload%d", f.uploadIDs)
+	f.uploadIDs++
+	f.uploads[uploadID] = &fakeUpload{fileName: fileName}
+	return uploadID
+}
+
+// newChunk describes chunk index of an upload; the caller holds f.mu
+func (f *fakeCluster) newChunk(uploadID string, index int, checksum uint32) *metadataPb.ChunkInfo {
+	return &metadataPb.ChunkInfo{
+		ChunkId:  fmt.Sprintf("%s-%d", uploadID, index),
+		Replicas: []string{f.addr},
+		Checksum: &checksum,
+	}
+}
+
+func (f *fakeCluster) AllocateChunks(ctx context.Context, req *metadataPb.CreateFileRequest) (*metadataPb.AllocateChunksResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	uploadID := f.newUpload(req.FileName)
+	upload := f.uploads[uploadID]
+	upload.fileSize = req.FileSize
+	for i, checksum := range req.ChunkChecksums {
+		upload.chunks = append(upload.chunks, f.newChunk(uploadID, i, checksum))
+	}
+	return &metadataPb.AllocateChunksResponse{UploadId: uploadID, Chunks: upload.chunks}, nil
+}
+
+func (f *fakeCluster) CreateUpload(ctx context.Context, req *metadataPb.CreateUploadRequest) (*metadataPb.CreateUploadResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &metadataPb.CreateUploadResponse{UploadId: f.newUpload(req.FileName), ChunkSize: f.chunkSize}, nil
+}
+
+func (f *fakeCluster) AllocateChunk(ctx context.Context, req *metadataPb.AllocateChunkRequest) (*metadataPb.AllocateChunkResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	upload, exists := f.uploads[req.UploadId]
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "Upload %s not found", req.UploadId)
+	}
+	if int(req.Index) != len(upload.chunks) {
+		return nil, status.Errorf(codes.InvalidArgument, "Chunk %d allocated out of order", req.Index)
+	}
+	chunk := f.newChunk(req.UploadId, int(req.Index), req.Checksum)
+	upload.chunks = append(upload.chunks, chunk)
+	return &metadataPb.AllocateChunkResponse{Chunk: chunk}, nil
+}
+
+func (f *fakeCluster) GetUploadStatus(ctx context.Context, req *metadataPb.GetUploadStatusRequest) (*metadataPb.GetUploadStatusResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	upload, exists := f.uploads[req.UploadId]
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "Upload %s not found", req.UploadId)
+	}
+	resp := &metadataPb.GetUploadStatusResponse{
+		FileName:  upload.fileName,
+		FileSize:  upload.fileSize,
+		Chunks:    upload.chunks,
+		ChunkSize: f.chunkSize,
+	}
+	for _, chunk := range upload.chunks {
+		_, stored := f.data[chunk.ChunkId]
+		resp.Stored = append(resp.Stored, stored)
+	}
+	return resp, nil
+}
+
+func (f *fakeCluster) CommitFile(ctx context.Context, req *metadataPb.CommitFileRequest) (*metadataPb.CommitFileResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	upload, exists := f.uploads[req.UploadId]
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "Upload %s not found", req.UploadId)
+	}
+	for _, chunk := range upload.chunks {
+		if _, stored := f.data[chunk.ChunkId]; !stored {
+			return nil, status.Errorf(codes.FailedPrecondition, "Chunk %s is not stored", chunk.ChunkId)
+		}
+	}
+	if req.FileSize > 0 {
+		upload.fileSize = req.FileSize
+	}
+	if f.onCommit != nil {
+		f.onCommit(req.ContentMd5)
+	}
+	delete(f.uploads, req.UploadId)
+	f.files[upload.fileName] = upload
+	return &metadataPb.CommitFileResponse{Success: true}, nil
+}
+
+func (f *fakeCluster) GetFileInfo(ctx context.Context, req *metadataPb.GetFileRequest) (*metadataPb.GetFileResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, exists := f.files[req.FileName]
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "File %s not found", req.FileName)
+	}
+	return &metadataPb.GetFileResponse{
+		Chunks: file.chunks,
+		Info: &metadataPb.FileInfo{
+			FileName:  file.fileName,
+			FileSize:  file.fileSize,
+			NumChunks: int32(len(file.chunks)),
+			ChunkSize: f.chunkSize,
+		},
+	}, nil
+}
+
+func (f *fakeCluster) StoreChunkStream(stream storagePb.StorageService_StoreChunkStreamServer) error {
+	var chunkID string
+	var checksum *uint32
+	var data []byte
+	for {
+		frame, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if chunkID == "" {
+			chunkID, checksum = frame.ChunkId, frame.Checksum
+		}
+		data = append(data, frame.Data...)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stores++
+	if f.failOnce[chunkID] {
+		delete(f.failOnce, chunkID)
+		return status.Errorf(codes.Unavailable, "Disk unplugged")
+	}
+	if checksum != nil && crc32.Checksum(data, castagnoli) != *checksum {
+		return status.Errorf(codes.DataLoss, "Checksum mismatch")
+	}
+	f.data[chunkID] = data
+	return stream.SendAndClose(&storagePb.StoreChunkResponse{Success: true})
+}
+
+func (f *fakeCluster) RetrieveChunkStream(req *storagePb.RetrieveChunkRequest, stream storagePb.StorageService_RetrieveChunkStreamServer) error {
+	f.mu.Lock()
+	f.retrieves++
+	data, exists := f.data[req.ChunkId]
+	f.mu.Unlock()
+	if !exists {
+		return status.Errorf(codes.NotFound, "Chunk %s not found", req.ChunkId)
+	}
+
+	// Serve the requested range like a storage node, clamped to the chunk
+	start := min(req.Offset, int64(len(data)))
+	end := int64(len(data))
+	if req.Length > 0 {
+		end = min(end, start+req.Length)
+	}
+	data = data[start:end]
+
+	// Send small frames so readers have to reassemble them
+	for len(data) > 0 {
+		n := min(len(data), 3)
+		if err := stream.Send(&storagePb.ChunkFrame{Data: data[:n]}); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// fileData returns the contents of a committed file, or false if it does not
+// exist
+func (f *fakeCluster) fileData(fileName string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, exists := f.files[fileName]
+	if !exists {
+		return "", false
+	}
+	var data []byte
+	for _, chunk := range file.chunks {
+		data = append(data, f.data[chunk.ChunkId]...)
+	}
+	return string(data), true
+}
+
+// counts returns how many chunk stores and retrievals reached the node
+func (f *fakeCluster) counts() (stores, retrieves int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stores, f.retrieves
+}
+
+func TestResumeUpload(t *testing.T) {
+	f, c := newFakeCluster(t, 4)
+	ctx := context.Background()
+
+	content := "0123456789abcdefghij" // Five chunks
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Storing the third chunk fails, so the upload stops short of a commit
+	f.failOnce["upload0-2"] = true
+	err := c.UploadFile(ctx, path, "file.txt")
+	var uploadErr *UploadError
+	if !errors.As(err, &uploadErr) || uploadErr.UploadID != "upload0" {
+		t.Fatalf("UploadFile error = %v, want an UploadError for upload0", err)
+	}
+	if _, exists := f.fileData("file.txt"); exists {
+		t.Fatalf("file published by a failed upload")
+	}
+
+	// Resuming sends only the missing chunk and publishes the file
+	stores, _ := f.counts()
+	if err := c.ResumeUpload(ctx, path, uploadErr.UploadID); err != nil {
+		t.Fatalf("ResumeUpload: %v", err)
+	}
+	if resumed, _ := f.counts(); resumed-stores != 1 {
+		t.Errorf("resume stored %d chunks, want 1", resumed-stores)
+	}
+	if data, _ := f.fileData("file.txt"); data != content {
+		t.Errorf("file = %q, want %q", data, content)
+	}
+}
+
+func TestResumeUploadChangedFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "different size", content: "0123456789abcdefghijk"},
+		{name: "same size, different data", content: "0123456789ABCDEFGHIJ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, c := newFakeCluster(t, 4)
+			ctx := context.Background()
+
+			path := filepath.Join(t.TempDir(), "file.txt")
+			if err := os.WriteFile(path, []byte("0123456789abcdefghij"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			f.failOnce["upload0-4"] = true
+			if err := c.UploadFile(ctx, path, "file.txt"); err == nil {
+				t.Fatalf("UploadFile succeeded despite a failed chunk")
+			}
+
+			// The local file changes before the upload is resumed
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			err := c.ResumeUpload(ctx, path, "upload0")
+			if err == nil || !strings.Contains(err.Error(), "has changed") {
+				t.Fatalf("ResumeUpload error = %v, want the file to be reported as changed", err)
+			}
+			if _, exists := f.fileData("file.txt"); exists {
+				t.Errorf("changed file was published")
+			}
+		})
+	}
+}
+
+// crc32Of returns the chunk checksum of data
+func crc32Of(data string) uint32 {
+	return crc32.Checksum([]byte(data), castagnoli)
+}
@@ -0,0 +1,120 @@
+// clientlib/users.go
+
+package clientlib
+
+import (
+	"context"
+	"fmt"
+
+	metadataPb "dfs/proto/metadata"
+)
+
+// WhoAmI describes the user the client's token belongs to, and whether the
+// cluster requires authentication at all
+func (c *Client) WhoAmI(ctx context.Context) (*metadataPb.WhoAmIResponse, error) {
+	var resp *metadataPb.WhoAmIResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		resp, err = metadataClient.WhoAmI(ctx, &metadataPb.WhoAmIRequest{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to identify caller: %w", err)
+	}
+	return resp, nil
+}
+
+// CreateUser adds a user account. Only administrators may create users.
+func (c *Client) CreateUser(ctx context.Context, name string, admin bool) (*metadataPb.UserInfo, error) {
+	var createResp *metadataPb.CreateUserResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		createResp, err = metadataClient.CreateUser(ctx, &metadataPb.CreateUserRequest{
+			Name:  name,
+			Admin: admin,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user %s: %w", name, err)
+	}
+	return createResp.User, nil
+}
+
+// DeleteUser removes a user account and revokes its tokens. The user's files
+// keep their owner.
+func (c *Client) DeleteUser(ctx context.Context, name string) error {
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		_, err := metadataClient.DeleteUser(ctx, &metadataPb.DeleteUserRequest{
+			Name: name,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete user %s: %w", name, err)
+	}
+	return nil
+}
+
+// ListUsers lists every user account, sorted by name
+func (c *Client) ListUsers(ctx context.Context) ([]*metadataPb.UserInfo, error) {
+	var listResp *metadataPb.ListUsersResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		listResp, err = metadataClient.ListUsers(ctx, &metadataPb.ListUsersRequest{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return listResp.Users, nil
+}
+
+// CreateToken issues an API token for user, or for the caller if user is
+// empty, and returns the token with its description. The token cannot be
+// retrieved again later.
+func (c *Client) CreateToken(ctx context.Context, user, description string) (string, *metadataPb.TokenInfo, error) {
+	var createResp *metadataPb.CreateTokenResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		createResp, err = metadataClient.CreateToken(ctx, &metadataPb.CreateTokenRequest{
+			User:        user,
+			Description: description,
+		})
+		return err
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create token: %w", err)
+	}
+	return createResp.Token, createResp.Info, nil
+}
+
+// ListTokens lists the tokens of user, or of the caller if user is empty
+func (c *Client) ListTokens(ctx context.Context, user string) ([]*metadataPb.TokenInfo, error) {
+	var listResp *metadataPb.ListTokensResponse
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		var err error
+		listResp, err = metadataClient.ListTokens(ctx, &metadataPb.ListTokensRequest{
+			User: user,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	return listResp.Tokens, nil
+}
+
+// RevokeToken removes the token with the given ID
+func (c *Client) RevokeToken(ctx context.Context, id string) error {
+	err := c.callMetadata(ctx, func(ctx context.Context, metadataClient metadataPb.MetadataServiceClient) error {
+		_, err := metadataClient.RevokeToken(ctx, &metadataPb.RevokeTokenRequest{
+			Id: id,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke token %s: %w", id, err)
+	}
+	return nil
+}
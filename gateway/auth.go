@@ -0,0 +1,42 @@
+// gateway/auth.go
+
+package main
+
+import (
+	"context"
+
+	"dfs/auth"
+
+	"google.golang.org/grpc"
+)
+
+// The gateway does not authenticate callers itself. It passes their bearer
+// tokens on to the cluster, which checks them and their permissions.
+
+// forwardToken returns ctx set up to call the cluster with the caller's token
+func forwardToken(ctx context.Context) context.Context {
+	if token, ok := auth.IncomingToken(ctx); ok {
+		return auth.WithToken(ctx, token)
+	}
+	return ctx
+}
+
+// forwardUnary forwards the token of unary calls
+func forwardUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(forwardToken(ctx), req)
+}
+
+// forwardStream forwards the token of streaming calls
+func forwardStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &tokenStream{ServerStream: ss, ctx: forwardToken(ss.Context())})
+}
+
+// tokenStream is a server stream whose context carries the caller's token
+type tokenStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tokenStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,85 @@
+// gateway/main.go
+
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"dfs/clientlib"
+	pb "dfs/proto/client"
+
+	"google.golang.org/grpc"
+)
+
+func main() {
+	// Command-line flags
+	port := flag.String("port", ":50050", "Port to listen on")
+	metadataAddrs := flag.String("metadata", "localhost:50051", "Comma-separated list of metadata service addresses")
+	maxMessageMB := flag.Int64("max_message_mb", 16, "Largest message in megabytes accepted by WriteFile and sent by ReadFile; larger files need the streaming RPCs")
+	writeTimeout := flag.Duration("write_timeout", 10*time.Minute, "Time without data after which a write started with CreateFile is abandoned")
+	concurrency := flag.Int("concurrency", 8, "Maximum number of chunks transferred at once per request")
+	flag.Parse()
+
+	if *maxMessageMB <= 0 {
+		log.Fatalf("Invalid maximum message size: %d", *maxMessageMB)
+	}
+	maxMessage := *maxMessageMB * 1024 * 1024
+
+	client, err := clientlib.NewClient(
+		clientlib.WithMetadataAddrs(strings.Split(*metadataAddrs, ",")...),
+		clientlib.WithConcurrency(*concurrency),
+	)
+	if err != nil {
+		log.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	srv := NewServer(client, maxMessage)
+
+	// Abandon writes whose callers went away
+	stopExpiry := make(chan struct{})
+	go srv.RunExpiry(*writeTimeout, stopExpiry)
+
+	// Listen on the specified port
+	lis, err := net.Listen("tcp", *port)
+	if err != nil {
+		log.Fatalf("Failed to listen on port %s: %v", *port, err)
+	}
+
+	// Create a new gRPC server, leaving room for the data and framing of the
+	// largest unary message
+	grpcServer := grpc.NewServer(
+		grpc.MaxRecvMsgSize(int(maxMessage)+1024*1024),
+		grpc.MaxSendMsgSize(int(maxMessage)+1024*1024),
+		grpc.ChainUnaryInterceptor(forwardUnary),
+		grpc.ChainStreamInterceptor(forwardStream),
+	)
+
+	// Register the ClientService with the gRPC server
+	pb.RegisterClientServiceServer(grpcServer, srv)
+
+	// Channel to listen for interrupt or terminate signals
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-quit
+		log.Println("Shutting down the gateway...")
+		close(stopExpiry)
+		grpcServer.GracefulStop()
+	}()
+
+	log.Printf("Gateway is running on port %s", *port)
+
+	// Start serving
+	if err := grpcServer.Serve(lis); err != nil {
+		log.Fatalf("Failed to serve gRPC server: %v", err)
+	}
+}
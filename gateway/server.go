@@ -0,0 +1,230 @@
+// gateway/server.go
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"dfs/clientlib"
+	pb "dfs/proto/client"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// frameSize is the most file data sent in one ReadFileStream message
+const frameSize = 1024 * 1024
+
+// server implements the ClientServiceServer interface on top of the client
+// library
+type server struct {
+	pb.UnimplementedClientServiceServer
+	client     *clientlib.Client
+	maxMessage int64 // Largest read served by the unary ReadFile
+
+	mu       sync.Mutex
+	sessions map[string]*session // Files being written with CreateFile and WriteFile, by path
+}
+
+// NewServer initializes a new gateway server
+func NewServer(client *clientlib.Client, maxMessage int64) *server {
+	return &server{
+		client:     client,
+		maxMessage: maxMessage,
+		sessions:   make(map[string]*session),
+	}
+}
+
+// gatewayError passes on the gRPC status of a failed cluster operation, and
+// reports anything else as an internal error
+func gatewayError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Errorf(codes.Internal, "%v", err)
+}
+
+// ReadFile reads a file, or a byte range of it, in a single message. Reads
+// larger than the message limit must use ReadFileStream.
+func (s *server) ReadFile(ctx context.Context, req *pb.ReadFileRequest) (*pb.ReadFileResponse, error) {
+	file, size, err := s.open(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	length := size - req.Offset
+	if req.Length > 0 {
+		length = min(length, req.Length)
+	}
+	if length > s.maxMessage {
+		return nil, status.Errorf(codes.ResourceExhausted, "Read of %d bytes exceeds the %d byte message limit; use ReadFileStream", length, s.maxMessage)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, gatewayError(err)
+	}
+
+	return &pb.ReadFileResponse{
+		Data:     data,
+		FileSize: size,
+	}, nil
+}
+
+// ReadFileStream reads a file, or a byte range of it, of any size. Only one
+// chunk of the file is held in memory at a time.
+func (s *server) ReadFileStream(req *pb.ReadFileRequest, stream pb.ClientService_ReadFileStreamServer) error {
+	file, size, err := s.open(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	remaining := size - req.Offset
+	if req.Length > 0 {
+		remaining = min(remaining, req.Length)
+	}
+
+	// The first message carries the file size even if the range is empty
+	resp := &pb.ReadFileResponse{FileSize: size}
+	buf := make([]byte, frameSize)
+	for first := true; first || remaining > 0; first = false {
+		n, err := io.ReadFull(file, buf[:min(remaining, frameSize)])
+		if err != nil {
+			return gatewayError(err)
+		}
+		resp.Data = buf[:n]
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+		remaining -= int64(n)
+		resp = &pb.ReadFileResponse{}
+	}
+
+	return nil
+}
+
+// open opens the file a read request is for, positioned at the requested
+// offset, and returns it with its size
+func (s *server) open(ctx context.Context, req *pb.ReadFileRequest) (io.ReadSeekCloser, int64, error) {
+	if req.FileName == "" {
+		return nil, 0, status.Errorf(codes.InvalidArgument, "File name is required")
+	}
+	if req.Offset < 0 || req.Length < 0 {
+		return nil, 0, status.Errorf(codes.InvalidArgument, "Invalid range: offset %d, length %d", req.Offset, req.Length)
+	}
+
+	file, err := s.client.Open(ctx, req.FileName)
+	if err != nil {
+		return nil, 0, gatewayError(err)
+	}
+
+	size, err := file.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = file.Seek(min(req.Offset, size), io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, 0, gatewayError(err)
+	}
+	if req.Offset > size {
+		file.Close()
+		return nil, 0, status.Errorf(codes.OutOfRange, "Offset %d is past the end of %s (%d bytes)", req.Offset, req.FileName, size)
+	}
+
+	return file, size, nil
+}
+
+// WriteFileStream writes a whole file sent as a stream of messages. The file
+// name is taken from the first message. The file is published once the
+// caller closes its side of the stream; if the stream breaks off, nothing is
+// published.
+func (s *server) WriteFileStream(stream pb.ClientService_WriteFileStreamServer) error {
+	req, err := stream.Recv()
+	if err == io.EOF {
+		return status.Errorf(codes.InvalidArgument, "File name is required")
+	}
+	if err != nil {
+		return err
+	}
+	if req.FileName == "" {
+		return status.Errorf(codes.InvalidArgument, "File name is required")
+	}
+	fileName := req.FileName
+
+	file, err := s.client.Create(stream.Context(), fileName)
+	if err != nil {
+		return gatewayError(err)
+	}
+
+	var written int64
+	for {
+		if _, err := file.Write(req.Data); err != nil {
+			return gatewayError(err)
+		}
+		written += int64(len(req.Data))
+
+		req, err = stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// The upload is abandoned and expires on the metadata service
+			return err
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		return gatewayError(err)
+	}
+
+	log.Printf("Wrote %s (%d bytes)", fileName, written)
+
+	return stream.SendAndClose(&pb.WriteFileResponse{
+		Success:      true,
+		BytesWritten: written,
+		Committed:    true,
+	})
+}
+
+// DeleteFile removes a file, or a directory
+func (s *server) DeleteFile(ctx context.Context, req *pb.DeleteFileRequest) (*pb.DeleteFileResponse, error) {
+	if req.FileName == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "File name is required")
+	}
+
+	if err := s.client.DeleteFile(ctx, req.FileName, req.Recursive); err != nil {
+		return nil, gatewayError(err)
+	}
+
+	log.Printf("Deleted %s", req.FileName)
+
+	return &pb.DeleteFileResponse{
+		Success: true,
+	}, nil
+}
+
+// RunExpiry abandons writes started with CreateFile that have not received
+// data for timeout, until stop is closed
+func (s *server) RunExpiry(timeout time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.expireSessions(timeout)
+		case <-stop:
+			return
+		}
+	}
+}
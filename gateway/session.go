@@ -0,0 +1,209 @@
+// gateway/session.go
+
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"dfs/auth"
+	pb "dfs/proto/client"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// session is a file being written with CreateFile and WriteFile. Its data is
+// streamed into the cluster as it arrives and the file is published once the
+// declared size has been written.
+type session struct {
+	mu      sync.Mutex // Serializes writes
+	file    io.WriteCloser
+	ctx     context.Context // Canceled when the session is abandoned
+	cancel  context.CancelFunc
+	token   string // Token of the caller who started the write
+	size    int64
+	written int64
+
+	lastUsed time.Time // Guarded by server.mu
+}
+
+// CreateFile starts writing a file of a known size. Its contents are sent
+// with WriteFile, and the file is published once file_size bytes have been
+// written. Writing to an existing path creates a new version of the file.
+func (s *server) CreateFile(ctx context.Context, req *pb.CreateFileRequest) (*pb.CreateFileResponse, error) {
+	if req.FileName == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "File name is required")
+	}
+	if req.FileSize < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid file size %d", req.FileSize)
+	}
+
+	s.mu.Lock()
+	_, exists := s.sessions[req.FileName]
+	s.mu.Unlock()
+	if exists {
+		return nil, status.Errorf(codes.AlreadyExists, "File %s is already being written", req.FileName)
+	}
+
+	// The upload outlives this call, so it gets its own context, which
+	// carries on writing with the caller's token
+	base := context.Background()
+	token, ok := auth.IncomingToken(ctx)
+	if ok {
+		base = auth.WithToken(base, token)
+	}
+	sessCtx, cancel := context.WithCancel(base)
+	file, err := s.client.Create(sessCtx, req.FileName)
+	if err != nil {
+		cancel()
+		return nil, gatewayError(err)
+	}
+
+	// An empty file is complete straight away
+	if req.FileSize == 0 {
+		defer cancel()
+		if err := file.Close(); err != nil {
+			return nil, gatewayError(err)
+		}
+		log.Printf("Wrote %s (0 bytes)", req.FileName)
+		return &pb.CreateFileResponse{Success: true}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.sessions[req.FileName]; exists {
+		cancel()
+		return nil, status.Errorf(codes.AlreadyExists, "File %s is already being written", req.FileName)
+	}
+	s.sessions[req.FileName] = &session{
+		file:     file,
+		ctx:      sessCtx,
+		cancel:   cancel,
+		token:    token,
+		size:     req.FileSize,
+		lastUsed: time.Now(),
+	}
+
+	log.Printf("Started writing %s (%d bytes)", req.FileName, req.FileSize)
+
+	return &pb.CreateFileResponse{
+		Success: true,
+	}, nil
+}
+
+// WriteFile appends data to a file started with CreateFile, publishing it once
+// it is complete. Without a preceding CreateFile, data is the whole file and
+// is published straight away.
+func (s *server) WriteFile(ctx context.Context, req *pb.WriteFileRequest) (*pb.WriteFileResponse, error) {
+	if req.FileName == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "File name is required")
+	}
+
+	s.mu.Lock()
+	sess, exists := s.sessions[req.FileName]
+	if exists {
+		sess.lastUsed = time.Now()
+	}
+	s.mu.Unlock()
+
+	if !exists {
+		return s.writeWhole(ctx, req)
+	}
+
+	// Only the caller who started the write may continue it
+	if token, _ := auth.IncomingToken(ctx); !auth.Equal(token, sess.token) {
+		return nil, status.Errorf(codes.PermissionDenied, "File %s is being written by another caller", req.FileName)
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if sess.ctx.Err() != nil {
+		return nil, status.Errorf(codes.Aborted, "Write of %s was abandoned", req.FileName)
+	}
+	if sess.written+int64(len(req.Data)) > sess.size {
+		s.abandon(req.FileName, sess)
+		return nil, status.Errorf(codes.InvalidArgument, "Write of %d bytes exceeds the declared size of %s (%d of %d bytes written)",
+			len(req.Data), req.FileName, sess.written, sess.size)
+	}
+
+	if _, err := sess.file.Write(req.Data); err != nil {
+		s.abandon(req.FileName, sess)
+		return nil, gatewayError(err)
+	}
+	sess.written += int64(len(req.Data))
+
+	resp := &pb.WriteFileResponse{
+		Success:      true,
+		BytesWritten: sess.written,
+	}
+	if sess.written < sess.size {
+		return resp, nil
+	}
+
+	// Publish the file now that all of it has arrived
+	err := sess.file.Close()
+	s.abandon(req.FileName, sess)
+	if err != nil {
+		return nil, gatewayError(err)
+	}
+
+	log.Printf("Wrote %s (%d bytes)", req.FileName, sess.written)
+
+	resp.Committed = true
+	return resp, nil
+}
+
+// writeWhole writes a file in a single call
+func (s *server) writeWhole(ctx context.Context, req *pb.WriteFileRequest) (*pb.WriteFileResponse, error) {
+	file, err := s.client.Create(ctx, req.FileName)
+	if err != nil {
+		return nil, gatewayError(err)
+	}
+	if _, err := file.Write(req.Data); err != nil {
+		return nil, gatewayError(err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, gatewayError(err)
+	}
+
+	log.Printf("Wrote %s (%d bytes)", req.FileName, len(req.Data))
+
+	return &pb.WriteFileResponse{
+		Success:      true,
+		BytesWritten: int64(len(req.Data)),
+		Committed:    true,
+	}, nil
+}
+
+// abandon ends a session. Unless the file was published, its upload is left
+// to expire on the metadata service, which reclaims the stored chunks.
+func (s *server) abandon(fileName string, sess *session) {
+	sess.cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions[fileName] == sess {
+		delete(s.sessions, fileName)
+	}
+}
+
+// expireSessions abandons the sessions that have not received data for
+// timeout
+func (s *server) expireSessions(timeout time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for fileName, sess := range s.sessions {
+		if time.Since(sess.lastUsed) > timeout {
+			sess.cancel()
+			delete(s.sessions, fileName)
+			log.Printf("Abandoned write of %s after %v without data", fileName, timeout)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// gateway/session_test.go
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	pb "dfs/proto/client"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeFile is an upload that keeps what is written to it
+type fakeFile struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (f *fakeFile) Close() error {
+	f.closed = true
+	return nil
+}
+
+// withToken returns a context of an incoming call made with token
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+// startSession adds a session for fileName of size bytes, started by the
+// caller with token, as CreateFile would
+func startSession(s *server, fileName, token string, size int64) (*session, *fakeFile) {
+	file := &fakeFile{}
+	ctx, cancel := context.WithCancel(context.Background())
+	sess := &session{
+		file:     file,
+		ctx:      ctx,
+		cancel:   cancel,
+		token:    token,
+		size:     size,
+		lastUsed: time.Now(),
+	}
+	s.sessions[fileName] = sess
+	return sess, file
+}
+
+func TestWriteFileSession(t *testing.T) {
+	type write struct {
+		token     string
+		data      string
+		code      codes.Code
+		written   int64
+		committed bool
+	}
+
+	tests := []struct {
+		name      string
+		writes    []write
+		contents  string
+		published bool // The upload was closed, publishing the file
+		open      bool // The session is still there afterwards
+	}{
+		{
+			name: "declared size in several writes",
+			writes: []write{
+				{token: "alice", data: "hello ", written: 6},
+				{token: "alice", data: "world", written: 11, committed: true},
+			},
+			contents:  "hello world",
+			published: true,
+		},
+		{
+			name: "partial write",
+			writes: []write{
+				{token: "alice", data: "hello", written: 5},
+			},
+			contents: "hello",
+			open:     true,
+		},
+		{
+			name: "write beyond the declared size",
+			writes: []write{
+				{token: "alice", data: "hello ", written: 6},
+				{token: "alice", data: "world!", code: codes.InvalidArgument},
+			},
+			contents: "hello ",
+		},
+		{
+			name: "another caller's write",
+			writes: []write{
+				{token: "alice", data: "hello ", written: 6},
+				{token: "mallory", data: "world", code: codes.PermissionDenied},
+				{token: "alice", data: "world", written: 11, committed: true},
+			},
+			contents:  "hello world",
+			published: true,
+		},
+		{
+			name: "write without a token",
+			writes: []write{
+				{data: "hello", code: codes.PermissionDenied},
+			},
+			open: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(nil, 1024)
+			_, file := startSession(s, "file.txt", "alice", 11)
+
+			for i, w := range tt.writes {
+				ctx := context.Background()
+				if w.token != "" {
+					ctx = withToken(w.token)
+				}
+				resp, err := s.WriteFile(ctx, &pb.WriteFileRequest{FileName: "file.txt", Data: []byte(w.data)})
+				if status.Code(err) != w.code {
+					t.Fatalf("write %d error = %v, want code %v", i, err, w.code)
+				}
+				if err != nil {
+					continue
+				}
+				if resp.BytesWritten != w.written || resp.Committed != w.committed {
+					t.Errorf("write %d = %d bytes, committed %v, want %d bytes, committed %v",
+						i, resp.BytesWritten, resp.Committed, w.written, w.committed)
+				}
+			}
+
+			if file.String() != tt.contents {
+				t.Errorf("uploaded %q, want %q", file.String(), tt.contents)
+			}
+			if file.closed != tt.published {
+				t.Errorf("published = %v, want %v", file.closed, tt.published)
+			}
+			if _, open := s.sessions["file.txt"]; open != tt.open {
+				t.Errorf("session open = %v, want %v", open, tt.open)
+			}
+		})
+	}
+}
+
+func TestWriteFileAbandoned(t *testing.T) {
+	s := NewServer(nil, 1024)
+	sess, file := startSession(s, "file.txt", "alice", 11)
+
+	// A write racing with the session being abandoned must not reach the
+	// upload
+	sess.cancel()
+	_, err := s.WriteFile(withToken("alice"), &pb.WriteFileRequest{FileName: "file.txt", Data: []byte("hello")})
+	if status.Code(err) != codes.Aborted {
+		t.Fatalf("WriteFile error = %v, want code %v", err, codes.Aborted)
+	}
+	if file.Len() != 0 {
+		t.Errorf("abandoned upload received %d bytes", file.Len())
+	}
+}
+
+func TestExpireSessions(t *testing.T) {
+	s := NewServer(nil, 1024)
+	idle, idleFile := startSession(s, "idle.txt", "alice", 10)
+	active, _ := startSession(s, "active.txt", "alice", 10)
+	idle.lastUsed = time.Now().Add(-time.Hour)
+
+	s.expireSessions(time.Minute)
+
+	if _, open := s.sessions["idle.txt"]; open {
+		t.Errorf("idle session was not expired")
+	}
+	if idle.ctx.Err() == nil {
+		t.Errorf("upload of the idle session was not canceled")
+	}
+	if idleFile.closed {
+		t.Errorf("idle session was published")
+	}
+	if _, open := s.sessions["active.txt"]; !open || active.ctx.Err() != nil {
+		t.Errorf("active session was expired")
+	}
+}
@@ -0,0 +1,271 @@
+// metadata/access.go
+
+package main
+
+import (
+	"context"
+	"log"
+
+	"dfs/auth"
+	pb "dfs/proto/metadata"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Modes follow Unix permission bits, but there are no groups and nothing is
+// executed, so only the read and write bits of the owner and of everyone else
+// are enforced. Without an execute bit, read permission on a directory also
+// stands for search permission: reaching anything below it needs both.
+const (
+	permRead  uint32 = 04
+	permWrite uint32 = 02
+
+	defaultFileMode uint32 = 0644
+	defaultDirMode  uint32 = 0755
+
+	// rootDirMode lets everyone create entries in the root directory. Only
+	// the owner of an entry there may remove it.
+	rootDirMode uint32 = 0777
+)
+
+// mode returns the permission bits of the file
+func (f *FileMetadata) mode() uint32 {
+	if f.Mode == nil {
+		return defaultFileMode
+	}
+	return *f.Mode
+}
+
+// mode returns the permission bits of the directory
+func (d *DirMetadata) mode() uint32 {
+	if d.Mode == nil {
+		return defaultDirMode
+	}
+	return *d.Mode
+}
+
+// ownerName returns the user new files and directories created by id belong
+// to
+func ownerName(id *auth.Identity) string {
+	if id == nil {
+		return ""
+	}
+	return id.User
+}
+
+// perms returns the owner and mode of the file or directory at p, or false if
+// there is nothing at p
+func (t tree) perms(p string) (string, uint32, bool) {
+	if fileMeta, exists := t.files[p]; exists {
+		return fileMeta.Owner, fileMeta.mode(), true
+	}
+	if p == "" {
+		return "", rootDirMode, true
+	}
+	if dirMeta, exists := t.dirs[p]; exists {
+		return dirMeta.Owner, dirMeta.mode(), true
+	}
+	return "", 0, false
+}
+
+// allowed reports whether id may access an entry with the given owner and
+// mode. As on Unix, the owner is only granted what the owner bits allow.
+func allowed(id *auth.Identity, owner string, mode, perm uint32) bool {
+	if id == nil || id.Admin {
+		return true
+	}
+	if owner != "" && owner == id.User {
+		return (mode>>6)&perm != 0
+	}
+	return mode&perm != 0
+}
+
+// permissionDenied is the error for an access id is not allowed
+func permissionDenied(p string) error {
+	return status.Errorf(codes.PermissionDenied, "Permission denied on /%s", p)
+}
+
+// authorizeSearch fails with PermissionDenied unless id may look up p in t,
+// which needs read permission on every directory above it
+func authorizeSearch(id *auth.Identity, t tree, p string) error {
+	for dir := parentDir(p); dir != ""; dir = parentDir(dir) {
+		owner, mode, exists := t.perms(dir)
+		if exists && !allowed(id, owner, mode, permRead) {
+			return permissionDenied(dir)
+		}
+	}
+	return nil
+}
+
+// authorize fails with PermissionDenied unless id may look up the entry at p
+// in t and access it. A missing entry is left for the caller to report.
+func authorize(id *auth.Identity, t tree, p string, perm uint32) error {
+	if err := authorizeSearch(id, t, p); err != nil {
+		return err
+	}
+	owner, mode, exists := t.perms(p)
+	if exists && !allowed(id, owner, mode, perm) {
+		return permissionDenied(p)
+	}
+	return nil
+}
+
+// authorizeCreate checks that id may create an entry at p, which needs write
+// permission on the closest existing directory above it, since missing
+// parents are created along with it. The caller must hold s.mu.
+func (s *server) authorizeCreate(id *auth.Identity, p string) error {
+	dir := parentDir(p)
+	for dir != "" && !s.isDir(dir) {
+		dir = parentDir(dir)
+	}
+	return authorize(id, s.live(), dir, permWrite)
+}
+
+// authorizeWrite checks that id may write a file at p: a new version of an
+// existing file needs write permission on the file, and a new file on its
+// directory. The caller must hold s.mu.
+func (s *server) authorizeWrite(id *auth.Identity, p string) error {
+	if _, exists := s.files[p]; exists {
+		return authorize(id, s.live(), p, permWrite)
+	}
+	return s.authorizeCreate(id, p)
+}
+
+// authorizeRemove checks that id may delete the entry at p or move it away,
+// which needs write permission on its directory. In the root directory,
+// which everyone may write to, only the owner of an entry may remove it. The
+// caller must hold s.mu.
+func (s *server) authorizeRemove(id *auth.Identity, p string) error {
+	t := s.live()
+	if err := authorize(id, t, parentDir(p), permWrite); err != nil {
+		return err
+	}
+	if parentDir(p) == "" && id != nil && !id.Admin {
+		if owner, _, exists := t.perms(p); exists && owner != id.User {
+			return permissionDenied(p)
+		}
+	}
+	return nil
+}
+
+// authorizeRemoveTree checks that id may delete the directory p with
+// everything below it, which also needs write permission on every directory
+// being emptied. The caller must hold s.mu.
+func (s *server) authorizeRemoveTree(id *auth.Identity, p string) error {
+	if err := s.authorizeRemove(id, p); err != nil {
+		return err
+	}
+	_, dirs := s.descendants(p)
+	for _, dir := range append(dirs, p) {
+		if err := authorize(id, s.live(), dir, permWrite); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authorizeOwner checks that id owns the entry at p in t or is an
+// administrator. The root directory belongs to the administrators.
+func authorizeOwner(id *auth.Identity, t tree, p string) error {
+	if id == nil || id.Admin {
+		return nil
+	}
+	if owner, _, exists := t.perms(p); exists && (p == "" || owner != id.User) {
+		return permissionDenied(p)
+	}
+	return nil
+}
+
+// applySetPermissions changes the mode of the file or directory at p, and its
+// owner unless newOwner is empty. The caller must hold s.mu.
+func (s *server) applySetPermissions(p string, mode *uint32, newOwner string) error {
+	if fileMeta, exists := s.files[p]; exists {
+		if mode != nil {
+			fileMeta.Mode = mode
+		}
+		if newOwner != "" {
+			fileMeta.Owner = newOwner
+		}
+		return nil
+	}
+	if dirMeta, exists := s.dirs[p]; exists {
+		if mode != nil {
+			dirMeta.Mode = mode
+		}
+		if newOwner != "" {
+			dirMeta.Owner = newOwner
+		}
+		return nil
+	}
+	return status.Errorf(codes.NotFound, "%s not found", p)
+}
+
+// SetPermissions changes the mode or the owner of a file or a directory. The
+// owner may change the mode; only administrators may give an entry away.
+func (s *server) SetPermissions(ctx context.Context, req *pb.SetPermissionsRequest) (*pb.SetPermissionsResponse, error) {
+	// Only the leader accepts writes
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	p, err := cleanPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	if p == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Cannot change the permissions of the root directory")
+	}
+	if req.Mode == nil && req.Owner == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Mode or owner is required")
+	}
+	if req.Mode != nil && *req.Mode > 0777 {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid mode %o", *req.Mode)
+	}
+
+	id := caller(ctx)
+	if req.Owner != "" {
+		if err := requireAdmin(id); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	if _, _, exists := s.live().perms(p); !exists {
+		s.mu.Unlock()
+		return nil, status.Errorf(codes.NotFound, "%s not found", p)
+	}
+	err = authorizeOwner(id, s.live(), p)
+	if err == nil && req.Owner != "" && req.Owner != auth.RootUser {
+		if _, exists := s.users[req.Owner]; !exists {
+			err = status.Errorf(codes.NotFound, "User %s not found", req.Owner)
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.commit(ctx, &command{
+		Op:       opSetPermissions,
+		FileName: p,
+		Mode:     req.Mode,
+		Owner:    req.Owner,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Changed permissions of %s", p)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entry(s.live(), p)
+	if entry == nil {
+		return nil, status.Errorf(codes.Aborted, "%s was removed while its permissions were changed", p)
+	}
+	return &pb.SetPermissionsResponse{
+		Entry: entry,
+	}, nil
+}
@@ -0,0 +1,135 @@
+// metadata/access_test.go
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"dfs/auth"
+	pb "dfs/proto/metadata"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	alice = &auth.Identity{User: "alice"}
+	bob   = &auth.Identity{User: "bob"}
+	admin = &auth.Identity{User: "carol", Admin: true}
+)
+
+// chmodCmd gives the entry at p to owner with the given mode
+func chmodCmd(p, owner string, mode uint32) *command {
+	return &command{Op: opSetPermissions, FileName: p, Mode: &mode, Owner: owner}
+}
+
+// newAccessTestServer returns a server holding alice's private home
+// directory and a public directory of files with unusual modes
+func newAccessTestServer(t *testing.T) *server {
+	s := newTestServer()
+	mustApply(t, s,
+		fileCmd("home/alice/notes", "c1"),
+		fileCmd("pub/f", "c2"),
+		fileCmd("pub/others", "c3"),
+		fileCmd("pub/group", "c4"),
+		chmodCmd("home", "", 0755),
+		chmodCmd("home/alice", "alice", 0700),
+		chmodCmd("home/alice/notes", "alice", 0644),
+		chmodCmd("pub", "alice", 0755),
+		chmodCmd("pub/f", "alice", 0644),
+		chmodCmd("pub/others", "alice", 0066),
+		chmodCmd("pub/group", "alice", 0070),
+	)
+	return s
+}
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name string
+		id   *auth.Identity
+		path string
+		perm uint32
+		code codes.Code
+	}{
+		{name: "owner reads own file", id: alice, path: "home/alice/notes", perm: permRead},
+		{name: "owner writes own file", id: alice, path: "home/alice/notes", perm: permWrite},
+		{name: "other reads private directory", id: bob, path: "home/alice", perm: permRead, code: codes.PermissionDenied},
+		{name: "other reads file in private directory", id: bob, path: "home/alice/notes", perm: permRead, code: codes.PermissionDenied},
+		{name: "other looks up missing entry in private directory", id: bob, path: "home/alice/missing", perm: permRead, code: codes.PermissionDenied},
+		{name: "owner looks up missing entry", id: alice, path: "home/alice/missing", perm: permRead},
+		{name: "other reads readable file", id: bob, path: "pub/f", perm: permRead},
+		{name: "other writes read-only file", id: bob, path: "pub/f", perm: permWrite, code: codes.PermissionDenied},
+		{name: "owner bits bind the owner", id: alice, path: "pub/others", perm: permRead, code: codes.PermissionDenied},
+		{name: "other bits apply to others", id: bob, path: "pub/others", perm: permWrite},
+		{name: "group bits do not apply to the owner", id: alice, path: "pub/group", perm: permRead, code: codes.PermissionDenied},
+		{name: "group bits do not apply to others", id: bob, path: "pub/group", perm: permRead, code: codes.PermissionDenied},
+		{name: "everyone writes the root", id: bob, path: "", perm: permWrite},
+		{name: "admin bypasses modes", id: admin, path: "home/alice/notes", perm: permWrite},
+		{name: "admin bypasses group-only mode", id: admin, path: "pub/group", perm: permRead},
+		{name: "cluster bypasses modes", id: auth.Root, path: "home/alice/notes", perm: permWrite},
+		{name: "disabled auth bypasses modes", id: nil, path: "home/alice/notes", perm: permWrite},
+	}
+
+	s := newAccessTestServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authorize(tt.id, s.live(), tt.path, tt.perm)
+			if status.Code(err) != tt.code {
+				t.Errorf("authorize(%q) error = %v, want code %v", tt.path, err, tt.code)
+			}
+		})
+	}
+}
+
+func TestAuthorizeRemove(t *testing.T) {
+	tests := []struct {
+		name string
+		id   *auth.Identity
+		path string
+		code codes.Code
+	}{
+		{name: "owner removes from the root", id: alice, path: "pub"},
+		{name: "other removes from the root", id: bob, path: "pub", code: codes.PermissionDenied},
+		{name: "admin removes from the root", id: admin, path: "pub"},
+		{name: "other removes from read-only directory", id: bob, path: "pub/f", code: codes.PermissionDenied},
+		{name: "owner removes from own directory", id: alice, path: "pub/f"},
+		{name: "owner removes from unwritable directory", id: alice, path: "home/alice", code: codes.PermissionDenied},
+	}
+
+	s := newAccessTestServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.authorizeRemove(tt.id, tt.path)
+			if status.Code(err) != tt.code {
+				t.Errorf("authorizeRemove(%q) error = %v, want code %v", tt.path, err, tt.code)
+			}
+		})
+	}
+}
+
+func TestStatNeedsSearchPermission(t *testing.T) {
+	tests := []struct {
+		name string
+		id   *auth.Identity
+		path string
+		code codes.Code
+	}{
+		{name: "owner", id: alice, path: "home/alice/notes"},
+		{name: "other", id: bob, path: "home/alice/notes", code: codes.PermissionDenied},
+		{name: "other on missing entry", id: bob, path: "home/alice/missing", code: codes.PermissionDenied},
+		{name: "other on the directory itself", id: bob, path: "home/alice"},
+		{name: "admin", id: admin, path: "home/alice/notes"},
+	}
+
+	s := newAccessTestServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := auth.NewContext(context.Background(), tt.id)
+			_, err := s.Stat(ctx, &pb.StatRequest{Path: tt.path})
+			if status.Code(err) != tt.code {
+				t.Errorf("Stat(%q) error = %v, want code %v", tt.path, err, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// metadata/auth.go
+
+package main
+
+import (
+	"context"
+
+	"dfs/auth"
+	pb "dfs/proto/metadata"
+	rpb "dfs/proto/raft"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// authRules restricts the RPCs meant for the cluster's own nodes to callers
+// holding the cluster token. Clients may find the leader before presenting a
+// token.
+var authRules = auth.Rules{
+	Public: []string{
+		pb.MetadataService_GetLeader_FullMethodName,
+	},
+	Internal: []string{
+		pb.MetadataService_RegisterNode_FullMethodName,
+		pb.MetadataService_Heartbeat_FullMethodName,
+		pb.MetadataService_ReportCorruptChunk_FullMethodName,
+		pb.MetadataService_VerifyToken_FullMethodName,
+		pb.MetadataService_CheckChunkWrite_FullMethodName,
+		"/" + rpb.RaftService_ServiceDesc.ServiceName + "/",
+	},
+}
+
+// clusterDialOptions returns the options for connecting to the other metadata
+// nodes and to storage nodes, which authenticate this node by the cluster
+// token
+func clusterDialOptions(clusterToken string) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithPerRPCCredentials(auth.Credentials(clusterToken)),
+	}
+}
+
+// authEnabled reports whether callers must present a token
+func (s *server) authEnabled() bool {
+	return s.clusterToken != ""
+}
+
+// authenticate resolves a token to the identity of its holder: the cluster
+// token to the root user, and API tokens to the user they were issued to
+func (s *server) authenticate(ctx context.Context, token string) (*auth.Identity, error) {
+	if s.authEnabled() && auth.Equal(token, s.clusterToken) {
+		return auth.Root, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if tokenMeta, exists := s.tokens[auth.HashToken(token)]; exists {
+		if user, exists := s.users[tokenMeta.User]; exists {
+			return &auth.Identity{User: user.Name, Admin: user.Admin}, nil
+		}
+	}
+	return nil, status.Errorf(codes.Unauthenticated, "Invalid token")
+}
+
+// caller returns the identity of the user making a request, or nil when
+// authentication is disabled and every caller may do anything
+func caller(ctx context.Context) *auth.Identity {
+	id, _ := auth.FromContext(ctx)
+	return id
+}
+
+// requireAdmin fails with PermissionDenied unless id is an administrator
+func requireAdmin(id *auth.Identity) error {
+	if id != nil && !id.Admin {
+		return status.Errorf(codes.PermissionDenied, "Only administrators may do this")
+	}
+	return nil
+}
+
+// identityInfo describes an authenticated identity
+func identityInfo(id *auth.Identity) *pb.UserInfo {
+	return &pb.UserInfo{
+		Name:  id.User,
+		Admin: id.Admin,
+	}
+}
+
+// WhoAmI describes the user the caller's token belongs to
+func (s *server) WhoAmI(ctx context.Context, req *pb.WhoAmIRequest) (*pb.WhoAmIResponse, error) {
+	resp := &pb.WhoAmIResponse{
+		AuthEnabled: s.authEnabled(),
+	}
+
+	id := caller(ctx)
+	if id == nil {
+		return resp, nil
+	}
+	resp.User = identityInfo(id)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user, exists := s.users[id.User]; exists {
+		resp.User.Created = user.Created
+	}
+	return resp, nil
+}
+
+// VerifyToken authenticates a token presented to a storage node, which has no
+// user accounts of its own
+func (s *server) VerifyToken(ctx context.Context, req *pb.VerifyTokenRequest) (*pb.VerifyTokenResponse, error) {
+	id, err := s.authenticate(ctx, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.VerifyTokenResponse{
+		User: identityInfo(id),
+	}, nil
+}
@@ -0,0 +1,128 @@
+// metadata/gc.go
+
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	storagePb "dfs/proto/storage"
+)
+
+// deleteChunkTimeout bounds a single DeleteChunk call
+const deleteChunkTimeout = 30 * time.Second
+
+// RunGarbageCollection periodically expires uploads that were not committed
+// within uploadTimeout, prunes older file versions outside the retention
+// policy and deletes the chunks of deleted files, pruned versions and expired
+// uploads from the storage nodes while this node is the leader, until stop is
+// closed
+func (s *server) RunGarbageCollection(interval, uploadTimeout time.Duration, retention retentionPolicy, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, isLeader, _ := s.raft.status(); !isLeader {
+				continue
+			}
+			s.expireUploads(uploadTimeout)
+			s.pruneVersions(retention)
+			s.collectGarbage()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// collectGarbage deletes every garbage chunk from its replicas and forgets
+// the chunks that are gone from all of them. Replicas on dead nodes are
+// retried on a later pass.
+func (s *server) collectGarbage() {
+	// Copy the work out so no lock is held while calling storage nodes
+	s.mu.Lock()
+	garbage := make(map[string][]string, len(s.garbage))
+	for chunkID, replicas := range s.garbage {
+		garbage[chunkID] = append([]string(nil), replicas...)
+	}
+	s.mu.Unlock()
+
+	var collected []string
+	for chunkID, replicas := range garbage {
+		// A live file may have reused the chunk ID; keep its copies
+		inUse := s.liveReplicas(chunkID)
+
+		deleted := true
+		for _, replica := range replicas {
+			if inUse[replica] {
+				continue
+			}
+			if !s.nodes.isAlive(replica) {
+				deleted = false
+				continue
+			}
+			if err := s.deleteChunk(chunkID, replica); err != nil {
+				log.Printf("GC: failed to delete chunk %s from %s: %v", chunkID, replica, err)
+				deleted = false
+			}
+		}
+
+		if deleted {
+			collected = append(collected, chunkID)
+		}
+	}
+
+	if len(collected) == 0 {
+		return
+	}
+
+	if err := s.commit(context.Background(), &command{Op: opForgetChunks, ChunkIDs: collected}); err != nil {
+		log.Printf("GC: failed to record deleted chunks: %v", err)
+		return
+	}
+
+	log.Printf("GC: deleted %d chunks", len(collected))
+}
+
+// liveReplicas returns the storage nodes holding chunkID for a file or file
+// version that still exists or is being uploaded
+func (s *server) liveReplicas(chunkID string) map[string]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files := s.allFiles()
+	for _, upload := range s.uploads {
+		files = append(files, upload.File)
+	}
+
+	replicas := make(map[string]bool)
+	for _, fileMeta := range files {
+		for _, chunk := range fileMeta.Chunks {
+			if chunk.ChunkID != chunkID {
+				continue
+			}
+			for _, replica := range chunk.Replicas {
+				replicas[replica] = true
+			}
+		}
+	}
+	return replicas
+}
+
+// deleteChunk removes chunkID from the storage node at address
+func (s *server) deleteChunk(chunkID, address string) error {
+	client, err := s.nodes.client(address)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deleteChunkTimeout)
+	defer cancel()
+
+	_, err = client.DeleteChunk(ctx, &storagePb.DeleteChunkRequest{
+		ChunkId: chunkID,
+	})
+	return err
+}
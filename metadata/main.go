@@ -10,8 +10,11 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
+	"dfs/auth"
 	pb "dfs/proto/metadata"
+	rpb "dfs/proto/raft"
 
 	"google.golang.org/grpc"
 )
@@ -19,21 +22,82 @@ import (
 func main() {
 	// Command-line flags
 	port := flag.String("port", ":50051", "The server port")
-	storageNodes := flag.String("storage_nodes", "localhost:50052,localhost:50053", "Comma-separated list of storage node addresses")
 	chunkSizeMB := flag.Int64("chunk_size_mb", 64, "Chunk size in megabytes")
+	replicationFactor := flag.Int("replication_factor", 2, "Number of storage nodes each chunk is copied to")
+	dataDir := flag.String("data_dir", "metadata_data", "Directory to persist the metadata log and snapshots")
+	snapshotInterval := flag.Duration("snapshot_interval", time.Minute, "Interval between metadata snapshots")
+	advertiseAddr := flag.String("advertise_addr", "", "Address other metadata nodes and clients reach this node at (defaults to localhost plus -port)")
+	peers := flag.String("peers", "", "Comma-separated list of all metadata node addresses in the Raft cluster, including this one")
+	heartbeatInterval := flag.Duration("heartbeat_interval", 5*time.Second, "How often storage nodes send heartbeats")
+	nodeTimeout := flag.Duration("node_timeout", 15*time.Second, "Time without a heartbeat after which a storage node is considered dead")
+	repairInterval := flag.Duration("repair_interval", 10*time.Second, "Interval between scans for under-replicated chunks")
+	repairConcurrency := flag.Int("repair_concurrency", 4, "Maximum number of chunks re-replicated at once")
+	gcInterval := flag.Duration("gc_interval", 30*time.Second, "Interval between deletions of chunks belonging to deleted files")
+	uploadTimeout := flag.Duration("upload_timeout", 24*time.Hour, "Time after which an upload that was not committed or resumed is abandoned")
+	keepVersions := flag.Int("keep_versions", 0, "Number of versions kept per file, counting the current one (0 keeps all)")
+	keepDays := flag.Int("keep_days", 0, "Days after which older file versions are pruned (0 keeps them forever)")
+	clusterTokenFile := flag.String("cluster_token_file", "", "File holding the token cluster nodes authenticate to each other with; enables authentication (disabled if empty)")
 	flag.Parse()
 
-	// Parse storage node addresses
-	storageNs := strings.Split(*storageNodes, ",")
-	for i, node := range storageNs {
-		storageNs[i] = strings.TrimSpace(node)
+	if *replicationFactor < 1 {
+		log.Fatalf("Replication factor must be at least 1, got %d", *replicationFactor)
+	}
+	if *repairConcurrency < 1 {
+		log.Fatalf("Repair concurrency must be at least 1, got %d", *repairConcurrency)
+	}
+
+	// Identify this node by the address it is reachable at
+	selfAddr := *advertiseAddr
+	if selfAddr == "" {
+		selfAddr = "localhost" + *port
+	}
+
+	// Parse the Raft peers, excluding this node
+	var raftPeers []string
+	for _, peer := range strings.Split(*peers, ",") {
+		peer = strings.TrimSpace(peer)
+		if peer != "" && peer != selfAddr {
+			raftPeers = append(raftPeers, peer)
+		}
 	}
 
 	// Convert chunk size from MB to bytes
 	chunkSize := *chunkSizeMB * 1024 * 1024
 
-	// Create a new Metadata server
-	srv := NewServer(storageNs, chunkSize)
+	// Callers must present a token once the cluster has one
+	var clusterToken string
+	if *clusterTokenFile != "" {
+		var err error
+		clusterToken, err = auth.ReadTokenFile(*clusterTokenFile)
+		if err != nil {
+			log.Fatalf("Failed to read cluster token: %v", err)
+		}
+	}
+
+	// Track the storage nodes that register with this node
+	nodes := newNodeRegistry(*heartbeatInterval, *nodeTimeout, clusterDialOptions(clusterToken))
+	stopMonitor := make(chan struct{})
+	go nodes.RunMonitor(stopMonitor)
+
+	// Create a new Metadata server, replaying any persisted state
+	srv := NewServer(nodes, chunkSize, *replicationFactor, *dataDir, selfAddr, raftPeers, clusterToken)
+
+	// Periodically snapshot the namespace to keep the log short
+	stopSnapshots := make(chan struct{})
+	go srv.RunSnapshots(*snapshotInterval, stopSnapshots)
+
+	// Re-replicate chunks that lost replicas to dead storage nodes
+	stopRepairs := make(chan struct{})
+	go srv.RunRepairs(*repairInterval, *repairConcurrency, stopRepairs)
+
+	// Prune old file versions and delete the chunks of deleted files from the
+	// storage nodes
+	retention := retentionPolicy{
+		keep:    *keepVersions,
+		keepFor: time.Duration(*keepDays) * 24 * time.Hour,
+	}
+	stopGC := make(chan struct{})
+	go srv.RunGarbageCollection(*gcInterval, *uploadTimeout, retention, stopGC)
 
 	// Listen on the specified port
 	lis, err := net.Listen("tcp", *port)
@@ -41,12 +105,20 @@ func main() {
 		log.Fatalf("Failed to listen on port %s: %v", *port, err)
 	}
 
-	// Create a new gRPC server
-	grpcServer := grpc.NewServer()
+	// Create a new gRPC server, authenticating every call if enabled
+	var serverOpts []grpc.ServerOption
+	if srv.authEnabled() {
+		serverOpts = auth.ServerOptions(srv.authenticate, authRules)
+		log.Printf("Authentication is enabled")
+	}
+	grpcServer := grpc.NewServer(serverOpts...)
 
 	// Register the MetadataService with the gRPC server
 	pb.RegisterMetadataServiceServer(grpcServer, srv)
 
+	// Register the Raft endpoint used by the other metadata nodes
+	rpb.RegisterRaftServiceServer(grpcServer, srv.RaftService())
+
 	// Channel to listen for interrupt or terminate signals
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
@@ -66,5 +138,14 @@ func main() {
 	// Gracefully stop the server
 	grpcServer.GracefulStop()
 
+	// Flush a final snapshot and stop participating in the Raft cluster
+	close(stopSnapshots)
+	close(stopMonitor)
+	close(stopRepairs)
+	close(stopGC)
+	if err := srv.Close(); err != nil {
+		log.Printf("Failed to close metadata store: %v", err)
+	}
+
 	log.Println("Metadata Service stopped.")
 }
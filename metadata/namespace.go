@@ -0,0 +1,505 @@
+// metadata/namespace.go
+
+package main
+
+import (
+	"context"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	pb "dfs/proto/metadata"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DirMetadata holds metadata for a single directory
+type DirMetadata struct {
+	Path    string
+	Created string
+	Owner   string  // User the directory belongs to; empty for directories owned by the administrators
+	Mode    *uint32 // Permission bits; nil for directories created before permissions, which get the default
+}
+
+// cleanPath converts a client supplied path to the form used as a key in the
+// namespace: slash-separated, relative to the root and without "." or ".."
+// elements. The root directory is the empty path.
+func cleanPath(p string) (string, error) {
+	if strings.ContainsRune(p, 0) {
+		return "", status.Errorf(codes.InvalidArgument, "Invalid path %q", p)
+	}
+	return strings.TrimPrefix(path.Clean("/"+p), "/"), nil
+}
+
+// parentDir returns the directory containing p
+func parentDir(p string) string {
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[:i]
+	}
+	return ""
+}
+
+// isUnder reports whether p is strictly inside the directory dir
+func isUnder(p, dir string) bool {
+	return dir == "" || strings.HasPrefix(p, dir+"/")
+}
+
+// tree is a read-only view of a namespace: the live one or a snapshot
+type tree struct {
+	files map[string]*FileMetadata
+	dirs  map[string]*DirMetadata
+}
+
+// tree returns the live namespace, or the namespace as of the named snapshot.
+// The caller must hold s.mu.
+func (s *server) tree(snapshot string) (tree, error) {
+	if snapshot == "" {
+		return tree{files: s.files, dirs: s.dirs}, nil
+	}
+	snap, exists := s.snapshots[snapshot]
+	if !exists {
+		return tree{}, status.Errorf(codes.NotFound, "Snapshot %s not found", snapshot)
+	}
+	return tree{files: snap.Files, dirs: snap.Dirs}, nil
+}
+
+// isDir reports whether p is the root or an existing directory
+func (t tree) isDir(p string) bool {
+	if p == "" {
+		return true
+	}
+	_, exists := t.dirs[p]
+	return exists
+}
+
+// live returns the live namespace. The caller must hold s.mu.
+func (s *server) live() tree {
+	return tree{files: s.files, dirs: s.dirs}
+}
+
+// isDir reports whether p is the root or an existing directory in the live
+// namespace. The caller must hold s.mu.
+func (s *server) isDir(p string) bool {
+	return s.live().isDir(p)
+}
+
+// checkCreate reports whether a file or directory can be created at p, which
+// must not exist and must not be below a file. Missing parent directories are
+// allowed. The caller must hold s.mu.
+func (s *server) checkCreate(p string) error {
+	if _, exists := s.files[p]; exists {
+		return status.Errorf(codes.AlreadyExists, "File %s already exists", p)
+	}
+	return s.checkWrite(p)
+}
+
+// checkWrite reports whether a file can be written at p, either as a new file
+// or as a new version of an existing one. The caller must hold s.mu.
+func (s *server) checkWrite(p string) error {
+	if p == "" {
+		return status.Errorf(codes.InvalidArgument, "Path is required")
+	}
+	if _, exists := s.dirs[p]; exists {
+		return status.Errorf(codes.AlreadyExists, "Directory %s already exists", p)
+	}
+	for dir := parentDir(p); dir != ""; dir = parentDir(dir) {
+		if _, exists := s.files[dir]; exists {
+			return status.Errorf(codes.FailedPrecondition, "%s is a file", dir)
+		}
+	}
+	return nil
+}
+
+// mkdirAll creates the directory p along with any missing parents, owned by
+// owner. The caller must hold s.mu and have checked that no file is in the
+// way.
+func (s *server) mkdirAll(p, created, owner string) {
+	for dir := p; dir != ""; dir = parentDir(dir) {
+		if _, exists := s.dirs[dir]; exists {
+			return
+		}
+		mode := defaultDirMode
+		s.dirs[dir] = &DirMetadata{Path: dir, Created: created, Owner: owner, Mode: &mode}
+	}
+}
+
+// applyMkdir creates the directory p, owned by owner. With parents, missing
+// parent directories are created too and an existing directory is not an
+// error. The caller must hold s.mu.
+func (s *server) applyMkdir(p string, created time.Time, parents bool, owner string) error {
+	if _, exists := s.dirs[p]; exists && parents {
+		return nil
+	}
+	if err := s.checkCreate(p); err != nil {
+		return err
+	}
+	if !parents && !s.isDir(parentDir(p)) {
+		return status.Errorf(codes.NotFound, "Directory %s not found", parentDir(p))
+	}
+	s.mkdirAll(p, created.Format("2006-01-02 15:04:05"), owner)
+	return nil
+}
+
+// moveFile moves the file at src and its older versions to dst. The caller
+// must hold s.mu.
+func (s *server) moveFile(src, dst string) {
+	fileMeta := s.files[src]
+	delete(s.files, src)
+	fileMeta.FileName = dst
+	s.files[dst] = fileMeta
+
+	if versions, exists := s.versions[src]; exists {
+		delete(s.versions, src)
+		for _, version := range versions {
+			version.FileName = dst
+		}
+		s.versions[dst] = versions
+	}
+}
+
+// removeFile removes the file at p with all its versions and queues their
+// chunks for garbage collection. The caller must hold s.mu.
+func (s *server) removeFile(p string) {
+	s.discardChunks(s.files[p].Chunks)
+	delete(s.files, p)
+	for _, version := range s.versions[p] {
+		s.discardChunks(version.Chunks)
+	}
+	delete(s.versions, p)
+}
+
+// descendants returns the paths of the files and directories below the
+// directory p. The caller must hold s.mu.
+func (s *server) descendants(p string) (files, dirs []string) {
+	for filePath := range s.files {
+		if isUnder(filePath, p) {
+			files = append(files, filePath)
+		}
+	}
+	for dirPath := range s.dirs {
+		if isUnder(dirPath, p) {
+			dirs = append(dirs, dirPath)
+		}
+	}
+	return files, dirs
+}
+
+// discardChunks queues chunks for garbage collection. The caller must hold
+// s.mu.
+func (s *server) discardChunks(chunks []*ChunkInfo) {
+	for _, chunk := range chunks {
+		s.garbage[chunk.ChunkID] = append(s.garbage[chunk.ChunkID], chunk.Replicas...)
+	}
+}
+
+// applyRename moves the file or directory tree at src to dst in one step, so
+// readers see either the old or the new namespace. Chunks are not touched;
+// only the paths they are reachable by change. With overwrite, a file at dst
+// is replaced by a file, or an empty directory at dst by a directory, and the
+// chunks of a replaced file are queued for garbage collection. The caller
+// must hold s.mu.
+func (s *server) applyRename(src, dst string, overwrite bool) error {
+	if src == "" || dst == "" {
+		return status.Errorf(codes.InvalidArgument, "Cannot move the root directory")
+	}
+	_, srcIsFile := s.files[src]
+	_, srcIsDir := s.dirs[src]
+	if !srcIsFile && !srcIsDir {
+		return status.Errorf(codes.NotFound, "%s not found", src)
+	}
+	if src == dst {
+		return nil
+	}
+	if isUnder(dst, src) {
+		return status.Errorf(codes.InvalidArgument, "Cannot move %s into itself", src)
+	}
+
+	// Check what is at the destination before changing anything
+	_, dstIsFile := s.files[dst]
+	_, dstIsDir := s.dirs[dst]
+	switch {
+	case dstIsFile && !overwrite:
+		return status.Errorf(codes.AlreadyExists, "File %s already exists", dst)
+	case dstIsFile && srcIsDir:
+		return status.Errorf(codes.FailedPrecondition, "Cannot replace file %s with a directory", dst)
+	case dstIsFile:
+		// A file replaces a file
+	case dstIsDir && !overwrite:
+		return status.Errorf(codes.AlreadyExists, "Directory %s already exists", dst)
+	case dstIsDir && srcIsFile:
+		return status.Errorf(codes.FailedPrecondition, "Cannot replace directory %s with a file", dst)
+	case dstIsDir:
+		if files, dirs := s.descendants(dst); len(files)+len(dirs) > 0 {
+			return status.Errorf(codes.FailedPrecondition, "Directory %s is not empty", dst)
+		}
+	default:
+		if err := s.checkCreate(dst); err != nil {
+			return err
+		}
+		if !s.isDir(parentDir(dst)) {
+			return status.Errorf(codes.NotFound, "Directory %s not found", parentDir(dst))
+		}
+	}
+
+	// Clear the destination
+	if dstIsFile {
+		s.removeFile(dst)
+	}
+	if dstIsDir {
+		delete(s.dirs, dst)
+	}
+
+	if srcIsFile {
+		s.moveFile(src, dst)
+		return nil
+	}
+
+	// Everything below the directory moves with it
+	files, dirs := s.descendants(src)
+	dirMeta := s.dirs[src]
+	delete(s.dirs, src)
+	dirMeta.Path = dst
+	s.dirs[dst] = dirMeta
+
+	for _, filePath := range files {
+		s.moveFile(filePath, dst+strings.TrimPrefix(filePath, src))
+	}
+	for _, dirPath := range dirs {
+		subdir := s.dirs[dirPath]
+		delete(s.dirs, dirPath)
+		subdir.Path = dst + strings.TrimPrefix(dirPath, src)
+		s.dirs[subdir.Path] = subdir
+	}
+	return nil
+}
+
+// applyDeleteDir removes the directory p. With recursive, the files and
+// directories below it are removed too and their chunks queued for garbage
+// collection; otherwise the directory must be empty. The caller must hold
+// s.mu.
+func (s *server) applyDeleteDir(p string, recursive bool) error {
+	if p == "" {
+		return status.Errorf(codes.InvalidArgument, "Cannot delete the root directory")
+	}
+	if _, exists := s.dirs[p]; !exists {
+		return status.Errorf(codes.NotFound, "Directory %s not found", p)
+	}
+
+	files, dirs := s.descendants(p)
+	if !recursive && len(files)+len(dirs) > 0 {
+		return status.Errorf(codes.FailedPrecondition, "Directory %s is not empty", p)
+	}
+
+	for _, filePath := range files {
+		s.removeFile(filePath)
+	}
+	for _, dirPath := range dirs {
+		delete(s.dirs, dirPath)
+	}
+	delete(s.dirs, p)
+	return nil
+}
+
+// entry describes the file or directory at p in t, or returns nil if there
+// is none. The caller must hold s.mu.
+func (s *server) entry(t tree, p string) *pb.DirEntry {
+	if fileMeta, exists := t.files[p]; exists {
+		return &pb.DirEntry{
+			Path:    p,
+			File:    s.fileInfo(fileMeta),
+			Created: fileMeta.UploadDate,
+			Owner:   fileMeta.Owner,
+			Mode:    fileMeta.mode(),
+		}
+	}
+	if p == "" {
+		return &pb.DirEntry{IsDir: true, Mode: rootDirMode}
+	}
+	if dirMeta, exists := t.dirs[p]; exists {
+		return &pb.DirEntry{
+			Path:    p,
+			IsDir:   true,
+			Created: dirMeta.Created,
+			Owner:   dirMeta.Owner,
+			Mode:    dirMeta.mode(),
+		}
+	}
+	return nil
+}
+
+// Mkdir creates a directory
+func (s *server) Mkdir(ctx context.Context, req *pb.MkdirRequest) (*pb.MkdirResponse, error) {
+	// Only the leader accepts writes
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	dirPath, err := cleanPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	if dirPath == "" {
+		if req.Parents {
+			return &pb.MkdirResponse{Success: true}, nil
+		}
+		return nil, status.Errorf(codes.AlreadyExists, "The root directory already exists")
+	}
+
+	// Creating missing parents needs permission on the closest existing
+	// directory, but an existing directory is left alone
+	id := caller(ctx)
+	s.mu.Lock()
+	_, exists := s.dirs[dirPath]
+	if !exists || !req.Parents {
+		err = s.authorizeCreate(id, dirPath)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = s.commit(ctx, &command{
+		Op:        opMkdir,
+		FileName:  dirPath,
+		Time:      &now,
+		Recursive: req.Parents,
+		Owner:     ownerName(id),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Created directory %s", dirPath)
+
+	return &pb.MkdirResponse{
+		Success: true,
+	}, nil
+}
+
+// ListDir lists the files and directories directly inside a directory, as it
+// is now or as of a snapshot
+func (s *server) ListDir(ctx context.Context, req *pb.ListDirRequest) (*pb.ListDirResponse, error) {
+	dirPath, err := cleanPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.tree(req.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	if !t.isDir(dirPath) {
+		if _, exists := t.files[dirPath]; exists {
+			return nil, status.Errorf(codes.FailedPrecondition, "%s is not a directory", dirPath)
+		}
+		return nil, status.Errorf(codes.NotFound, "Directory %s not found", dirPath)
+	}
+	if err := authorize(caller(ctx), t, dirPath, permRead); err != nil {
+		return nil, err
+	}
+
+	var entries []*pb.DirEntry
+	for p := range t.dirs {
+		if parentDir(p) == dirPath {
+			entries = append(entries, s.entry(t, p))
+		}
+	}
+	for p := range t.files {
+		if parentDir(p) == dirPath {
+			entries = append(entries, s.entry(t, p))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	return &pb.ListDirResponse{
+		Entries: entries,
+	}, nil
+}
+
+// Stat describes a file or a directory, as it is now or as of a snapshot
+func (s *server) Stat(ctx context.Context, req *pb.StatRequest) (*pb.StatResponse, error) {
+	p, err := cleanPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.tree(req.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like ListDir, only callers who can see into the directory learn
+	// what it holds
+	if err := authorizeSearch(caller(ctx), t, p); err != nil {
+		return nil, err
+	}
+	entry := s.entry(t, p)
+	if entry == nil {
+		return nil, status.Errorf(codes.NotFound, "%s not found", p)
+	}
+
+	return &pb.StatResponse{
+		Entry: entry,
+	}, nil
+}
+
+// Rename atomically moves a file or a directory tree to a new path. Only the
+// namespace changes; chunks stay where they are.
+func (s *server) Rename(ctx context.Context, req *pb.RenameRequest) (*pb.RenameResponse, error) {
+	// Only the leader accepts writes
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	src, err := cleanPath(req.Src)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := cleanPath(req.Dst)
+	if err != nil {
+		return nil, err
+	}
+
+	// Moving needs permission to remove src and to create or replace dst
+	id := caller(ctx)
+	s.mu.Lock()
+	err = s.authorizeRemove(id, src)
+	if err == nil {
+		if _, _, exists := s.live().perms(dst); exists && dst != "" {
+			err = s.authorizeRemove(id, dst)
+		} else {
+			err = s.authorizeCreate(id, dst)
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.commit(ctx, &command{
+		Op:        opRename,
+		FileName:  src,
+		Dest:      dst,
+		Overwrite: req.Overwrite,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Renamed %s to %s", src, dst)
+
+	return &pb.RenameResponse{
+		Success: true,
+	}, nil
+}
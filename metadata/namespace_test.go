@@ -0,0 +1,403 @@
+// metadata/namespace_test.go
+
+package main
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestServer returns a server with an empty namespace and no Raft node,
+// for driving apply directly
+func newTestServer() *server {
+	return &server{
+		files:     make(map[string]*FileMetadata),
+		uploads:   make(map[string]*pendingUpload),
+		dirs:      make(map[string]*DirMetadata),
+		versions:  make(map[string][]*FileMetadata),
+		snapshots: make(map[string]*SnapshotMetadata),
+		garbage:   make(map[string][]string),
+		users:     make(map[string]*UserMetadata),
+		tokens:    make(map[string]*TokenMetadata),
+		chunkSize: 1024,
+		replicas:  2,
+	}
+}
+
+// mkdirCmd creates the directory p and its missing parents
+func mkdirCmd(p string) *command {
+	now := time.Now()
+	return &command{Op: opMkdir, FileName: p, Time: &now, Recursive: true}
+}
+
+// fileCmd creates a file at p made of the given chunks, each on one replica
+func fileCmd(p string, chunkIDs ...string) *command {
+	fileMeta := &FileMetadata{
+		FileName:   p,
+		UploadDate: "2024-01-01 00:00:00",
+	}
+	for _, chunkID := range chunkIDs {
+		fileMeta.Chunks = append(fileMeta.Chunks, &ChunkInfo{ChunkID: chunkID, Replicas: []string{"node1"}})
+	}
+	return &command{Op: opCreateFile, File: fileMeta}
+}
+
+// uploadCmds uploads a new version of the file at p made of the given chunks
+func uploadCmds(uploadID, p string, chunkIDs ...string) []*command {
+	fileMeta := fileCmd(p, chunkIDs...).File
+	upload := &pendingUpload{UploadID: uploadID, File: fileMeta, Active: time.Now()}
+	return []*command{
+		{Op: opCreateUpload, Upload: upload},
+		{Op: opCommitUpload, UploadID: uploadID},
+	}
+}
+
+// mustApply applies cmds in order, failing the test on the first error
+func mustApply(t *testing.T, s *server, cmds ...*command) {
+	t.Helper()
+	for _, cmd := range cmds {
+		if err := s.applyCommand(cmd); err != nil {
+			t.Fatalf("apply %s %s: %v", cmd.Op, cmd.FileName, err)
+		}
+	}
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// equalStrings reports whether a and b hold the same strings in the same order
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		code codes.Code
+	}{
+		{in: "", want: ""},
+		{in: "/", want: ""},
+		{in: "a", want: "a"},
+		{in: "/a/b/", want: "a/b"},
+		{in: "a//b", want: "a/b"},
+		{in: "./a/./b", want: "a/b"},
+		{in: "a/../b", want: "b"},
+		{in: "/../../a", want: "a"},
+		{in: "..", want: ""},
+		{in: "a/b/..", want: "a"},
+		{in: "a\x00b", code: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		got, err := cleanPath(tt.in)
+		if status.Code(err) != tt.code {
+			t.Errorf("cleanPath(%q) error = %v, want code %v", tt.in, err, tt.code)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("cleanPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestApplyDeleteDir(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		recursive   bool
+		code        codes.Code
+		wantFiles   []string
+		wantDirs    []string
+		wantGarbage []string
+	}{
+		{
+			name:      "root",
+			path:      "",
+			recursive: true,
+			code:      codes.InvalidArgument,
+			wantFiles: []string{"a/b/f", "ab/g", "top"},
+			wantDirs:  []string{"a", "a/b", "ab", "empty"},
+		},
+		{
+			name:      "missing",
+			path:      "missing",
+			code:      codes.NotFound,
+			wantFiles: []string{"a/b/f", "ab/g", "top"},
+			wantDirs:  []string{"a", "a/b", "ab", "empty"},
+		},
+		{
+			name:      "file",
+			path:      "top",
+			code:      codes.NotFound,
+			wantFiles: []string{"a/b/f", "ab/g", "top"},
+			wantDirs:  []string{"a", "a/b", "ab", "empty"},
+		},
+		{
+			name:      "non-empty without recursive",
+			path:      "a",
+			code:      codes.FailedPrecondition,
+			wantFiles: []string{"a/b/f", "ab/g", "top"},
+			wantDirs:  []string{"a", "a/b", "ab", "empty"},
+		},
+		{
+			name:      "empty",
+			path:      "empty",
+			wantFiles: []string{"a/b/f", "ab/g", "top"},
+			wantDirs:  []string{"a", "a/b", "ab"},
+		},
+		{
+			name:        "recursive leaves directories sharing a prefix",
+			path:        "a",
+			recursive:   true,
+			wantFiles:   []string{"ab/g", "top"},
+			wantDirs:    []string{"ab", "empty"},
+			wantGarbage: []string{"c1"},
+		},
+		{
+			name:        "recursive subdirectory",
+			path:        "a/b",
+			recursive:   true,
+			wantFiles:   []string{"ab/g", "top"},
+			wantDirs:    []string{"a", "ab", "empty"},
+			wantGarbage: []string{"c1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer()
+			mustApply(t, s,
+				mkdirCmd("empty"),
+				fileCmd("a/b/f", "c1"),
+				fileCmd("ab/g", "c2"),
+				fileCmd("top", "c3"),
+			)
+
+			err := s.applyCommand(&command{Op: opDeleteDir, FileName: tt.path, Recursive: tt.recursive})
+			if status.Code(err) != tt.code {
+				t.Fatalf("delete %q: error = %v, want code %v", tt.path, err, tt.code)
+			}
+			if got := sortedKeys(s.files); !equalStrings(got, tt.wantFiles) {
+				t.Errorf("files = %v, want %v", got, tt.wantFiles)
+			}
+			if got := sortedKeys(s.dirs); !equalStrings(got, tt.wantDirs) {
+				t.Errorf("dirs = %v, want %v", got, tt.wantDirs)
+			}
+			if got := sortedKeys(s.garbage); !equalStrings(got, tt.wantGarbage) {
+				t.Errorf("garbage = %v, want %v", got, tt.wantGarbage)
+			}
+		})
+	}
+}
+
+func TestApplyRename(t *testing.T) {
+	tests := []struct {
+		name        string
+		src, dst    string
+		overwrite   bool
+		code        codes.Code
+		wantFiles   []string
+		wantDirs    []string
+		wantGarbage []string
+	}{
+		{
+			name:      "root",
+			src:       "",
+			dst:       "x",
+			code:      codes.InvalidArgument,
+			wantFiles: []string{"f1", "f2", "full/x", "src/sub/f", "srcx/g"},
+			wantDirs:  []string{"empty", "full", "src", "src/sub", "srcx"},
+		},
+		{
+			name:      "missing source",
+			src:       "missing",
+			dst:       "x",
+			code:      codes.NotFound,
+			wantFiles: []string{"f1", "f2", "full/x", "src/sub/f", "srcx/g"},
+			wantDirs:  []string{"empty", "full", "src", "src/sub", "srcx"},
+		},
+		{
+			name:      "onto itself",
+			src:       "f1",
+			dst:       "f1",
+			wantFiles: []string{"f1", "f2", "full/x", "src/sub/f", "srcx/g"},
+			wantDirs:  []string{"empty", "full", "src", "src/sub", "srcx"},
+		},
+		{
+			name:      "into itself",
+			src:       "src",
+			dst:       "src/sub/new",
+			code:      codes.InvalidArgument,
+			wantFiles: []string{"f1", "f2", "full/x", "src/sub/f", "srcx/g"},
+			wantDirs:  []string{"empty", "full", "src", "src/sub", "srcx"},
+		},
+		{
+			name:      "file to a new path",
+			src:       "f1",
+			dst:       "full/f1",
+			wantFiles: []string{"f2", "full/f1", "full/x", "src/sub/f", "srcx/g"},
+			wantDirs:  []string{"empty", "full", "src", "src/sub", "srcx"},
+		},
+		{
+			name:      "file into a missing directory",
+			src:       "f1",
+			dst:       "missing/f1",
+			code:      codes.NotFound,
+			wantFiles: []string{"f1", "f2", "full/x", "src/sub/f", "srcx/g"},
+			wantDirs:  []string{"empty", "full", "src", "src/sub", "srcx"},
+		},
+		{
+			name:      "file below a file",
+			src:       "f1",
+			dst:       "f2/f1",
+			code:      codes.FailedPrecondition,
+			wantFiles: []string{"f1", "f2", "full/x", "src/sub/f", "srcx/g"},
+			wantDirs:  []string{"empty", "full", "src", "src/sub", "srcx"},
+		},
+		{
+			name:      "file onto a file without overwrite",
+			src:       "f1",
+			dst:       "f2",
+			code:      codes.AlreadyExists,
+			wantFiles: []string{"f1", "f2", "full/x", "src/sub/f", "srcx/g"},
+			wantDirs:  []string{"empty", "full", "src", "src/sub", "srcx"},
+		},
+		{
+			name:        "file onto a file with overwrite",
+			src:         "f1",
+			dst:         "f2",
+			overwrite:   true,
+			wantFiles:   []string{"f2", "full/x", "src/sub/f", "srcx/g"},
+			wantDirs:    []string{"empty", "full", "src", "src/sub", "srcx"},
+			wantGarbage: []string{"c2"},
+		},
+		{
+			name:      "directory onto a file",
+			src:       "src",
+			dst:       "f2",
+			overwrite: true,
+			code:      codes.FailedPrecondition,
+			wantFiles: []string{"f1", "f2", "full/x", "src/sub/f", "srcx/g"},
+			wantDirs:  []string{"empty", "full", "src", "src/sub", "srcx"},
+		},
+		{
+			name:      "file onto a directory",
+			src:       "f1",
+			dst:       "empty",
+			overwrite: true,
+			code:      codes.FailedPrecondition,
+			wantFiles: []string{"f1", "f2", "full/x", "src/sub/f", "srcx/g"},
+			wantDirs:  []string{"empty", "full", "src", "src/sub", "srcx"},
+		},
+		{
+			name:      "directory onto a directory without overwrite",
+			src:       "src",
+			dst:       "empty",
+			code:      codes.AlreadyExists,
+			wantFiles: []string{"f1", "f2", "full/x", "src/sub/f", "srcx/g"},
+			wantDirs:  []string{"empty", "full", "src", "src/sub", "srcx"},
+		},
+		{
+			name:      "directory onto an empty directory",
+			src:       "src",
+			dst:       "empty",
+			overwrite: true,
+			wantFiles: []string{"empty/sub/f", "f1", "f2", "full/x", "srcx/g"},
+			wantDirs:  []string{"empty", "empty/sub", "full", "srcx"},
+		},
+		{
+			name:      "directory onto a non-empty directory",
+			src:       "src",
+			dst:       "full",
+			overwrite: true,
+			code:      codes.FailedPrecondition,
+			wantFiles: []string{"f1", "f2", "full/x", "src/sub/f", "srcx/g"},
+			wantDirs:  []string{"empty", "full", "src", "src/sub", "srcx"},
+		},
+		{
+			name:      "directory tree leaves directories sharing a prefix",
+			src:       "src",
+			dst:       "full/moved",
+			wantFiles: []string{"f1", "f2", "full/moved/sub/f", "full/x", "srcx/g"},
+			wantDirs:  []string{"empty", "full", "full/moved", "full/moved/sub", "srcx"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer()
+			mustApply(t, s,
+				mkdirCmd("empty"),
+				fileCmd("f1", "c1"),
+				fileCmd("f2", "c2"),
+				fileCmd("full/x", "c3"),
+				fileCmd("src/sub/f", "c4"),
+				fileCmd("srcx/g", "c5"),
+			)
+
+			err := s.applyCommand(&command{Op: opRename, FileName: tt.src, Dest: tt.dst, Overwrite: tt.overwrite})
+			if status.Code(err) != tt.code {
+				t.Fatalf("rename %q to %q: error = %v, want code %v", tt.src, tt.dst, err, tt.code)
+			}
+			if got := sortedKeys(s.files); !equalStrings(got, tt.wantFiles) {
+				t.Errorf("files = %v, want %v", got, tt.wantFiles)
+			}
+			if got := sortedKeys(s.dirs); !equalStrings(got, tt.wantDirs) {
+				t.Errorf("dirs = %v, want %v", got, tt.wantDirs)
+			}
+			if got := sortedKeys(s.garbage); !equalStrings(got, tt.wantGarbage) {
+				t.Errorf("garbage = %v, want %v", got, tt.wantGarbage)
+			}
+
+			// Moved entries know their new paths
+			for p, fileMeta := range s.files {
+				if fileMeta.FileName != p {
+					t.Errorf("file at %s is named %s", p, fileMeta.FileName)
+				}
+			}
+			for p, dirMeta := range s.dirs {
+				if dirMeta.Path != p {
+					t.Errorf("directory at %s is named %s", p, dirMeta.Path)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyRenameMovesVersions(t *testing.T) {
+	s := newTestServer()
+	mustApply(t, s, uploadCmds("u1", "a", "c1")...)
+	mustApply(t, s, uploadCmds("u2", "a", "c2")...)
+	mustApply(t, s, &command{Op: opRename, FileName: "a", Dest: "b"})
+
+	if _, exists := s.versions["a"]; exists {
+		t.Errorf("versions left behind at a")
+	}
+	versions := s.versions["b"]
+	if len(versions) != 1 || versions[0].FileName != "b" || versions[0].Chunks[0].ChunkID != "c1" {
+		t.Fatalf("versions at b = %v, want version 1 with chunk c1", versions)
+	}
+	if fileMeta := s.findVersion("b", 2); fileMeta == nil || fileMeta.Chunks[0].ChunkID != "c2" {
+		t.Errorf("version 2 at b = %v, want chunk c2", fileMeta)
+	}
+}
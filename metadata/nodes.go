@@ -0,0 +1,240 @@
+// metadata/nodes.go
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	pb "dfs/proto/metadata"
+	storagePb "dfs/proto/storage"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// storageNode tracks a registered storage node and the stats it last reported
+type storageNode struct {
+	Address       string
+	CapacityBytes int64
+	FreeBytes     int64
+	ChunkCount    int64
+	LastHeartbeat time.Time
+	Alive         bool
+}
+
+// nodeRegistry keeps track of the storage nodes that have registered with
+// this metadata node and whether they are still sending heartbeats. It is
+// soft state: storage nodes report to every metadata node, so a newly elected
+// leader learns about them within one heartbeat interval.
+type nodeRegistry struct {
+	mu                sync.Mutex
+	nodes             map[string]*storageNode
+	clients           map[string]storagePb.StorageServiceClient
+	heartbeatInterval time.Duration
+	timeout           time.Duration // A node is dead after this long without a heartbeat
+	started           time.Time
+	dialOptions       []grpc.DialOption // For connecting to storage nodes
+}
+
+// newNodeRegistry creates an empty registry whose storage nodes are dialed
+// with dialOptions
+func newNodeRegistry(heartbeatInterval, timeout time.Duration, dialOptions []grpc.DialOption) *nodeRegistry {
+	return &nodeRegistry{
+		nodes:             make(map[string]*storageNode),
+		clients:           make(map[string]storagePb.StorageServiceClient),
+		heartbeatInterval: heartbeatInterval,
+		timeout:           timeout,
+		started:           time.Now(),
+		dialOptions:       dialOptions,
+	}
+}
+
+// settled reports whether every live storage node has had a chance to
+// register since this metadata node started. Until then a node missing from
+// the registry may simply not have reported yet.
+func (r *nodeRegistry) settled() bool {
+	return time.Since(r.started) > r.timeout
+}
+
+// register adds or refreshes a storage node
+func (r *nodeRegistry) register(address string, stats *pb.NodeStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	node, exists := r.nodes[address]
+	if !exists {
+		node = &storageNode{Address: address}
+		r.nodes[address] = node
+	}
+	if !node.Alive {
+		log.Printf("Storage node %s registered", address)
+	}
+	node.update(stats)
+}
+
+// heartbeat records a heartbeat from a storage node. It returns false if the
+// node has not registered with this metadata node.
+func (r *nodeRegistry) heartbeat(address string, stats *pb.NodeStats) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	node, exists := r.nodes[address]
+	if !exists {
+		return false
+	}
+	if !node.Alive {
+		log.Printf("Storage node %s is alive again", address)
+	}
+	node.update(stats)
+	return true
+}
+
+// update refreshes the node's stats and marks it alive. The caller must hold
+// the registry lock.
+func (n *storageNode) update(stats *pb.NodeStats) {
+	n.CapacityBytes = stats.GetCapacityBytes()
+	n.FreeBytes = stats.GetFreeBytes()
+	n.ChunkCount = stats.GetChunkCount()
+	n.LastHeartbeat = time.Now()
+	n.Alive = true
+}
+
+// isAlive reports whether address belongs to a registered node that is still
+// sending heartbeats
+func (r *nodeRegistry) isAlive(address string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	node, exists := r.nodes[address]
+	return exists && node.Alive
+}
+
+// liveNodes returns a copy of every node that is currently alive
+func (r *nodeRegistry) liveNodes() []storageNode {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var live []storageNode
+	for _, node := range r.nodes {
+		if node.Alive {
+			live = append(live, *node)
+		}
+	}
+	return live
+}
+
+// place picks replicas distinct live nodes for each of numChunks chunks of
+// chunkSize bytes. Chunks go to the nodes with the most free space, counting
+// the space already promised to earlier chunks of the same allocation.
+func (r *nodeRegistry) place(numChunks, replicas int, chunkSize int64) ([][]string, error) {
+	live := r.liveNodes()
+	if len(live) < replicas {
+		return nil, fmt.Errorf("replication factor %d requires %d live storage nodes, have %d", replicas, replicas, len(live))
+	}
+
+	// Order by address first so ties are broken the same way every time
+	sort.Slice(live, func(i, j int) bool { return live[i].Address < live[j].Address })
+
+	placements := make([][]string, numChunks)
+	for i := range placements {
+		sort.SliceStable(live, func(a, b int) bool { return live[a].FreeBytes > live[b].FreeBytes })
+
+		nodes := make([]string, replicas)
+		for r := range nodes {
+			nodes[r] = live[r].Address
+			live[r].FreeBytes -= chunkSize
+		}
+		placements[i] = nodes
+	}
+
+	return placements, nil
+}
+
+// pickNode returns the live node with the most free space that is not in
+// exclude
+func (r *nodeRegistry) pickNode(exclude []string) (string, error) {
+	live := r.liveNodes()
+	sort.Slice(live, func(i, j int) bool {
+		if live[i].FreeBytes != live[j].FreeBytes {
+			return live[i].FreeBytes > live[j].FreeBytes
+		}
+		return live[i].Address < live[j].Address
+	})
+
+	for _, node := range live {
+		if !contains(exclude, node.Address) {
+			return node.Address, nil
+		}
+	}
+
+	return "", fmt.Errorf("no live storage node available outside %v", exclude)
+}
+
+// client retrieves or creates a StorageServiceClient for the given address
+func (r *nodeRegistry) client(address string) (storagePb.StorageServiceClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if client, exists := r.clients[address]; exists {
+		return client, nil
+	}
+
+	conn, err := grpc.Dial(address, r.dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to storage node at %s: %v", address, err)
+	}
+
+	client := storagePb.NewStorageServiceClient(conn)
+	r.clients[address] = client
+	return client, nil
+}
+
+// RunMonitor marks nodes dead once they miss heartbeats for longer than the
+// timeout, until stop is closed
+func (r *nodeRegistry) RunMonitor(stop <-chan struct{}) {
+	ticker := time.NewTicker(r.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.mu.Lock()
+			for _, node := range r.nodes {
+				if node.Alive && time.Since(node.LastHeartbeat) > r.timeout {
+					node.Alive = false
+					log.Printf("Storage node %s marked dead after %v without a heartbeat", node.Address, r.timeout)
+				}
+			}
+			r.mu.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// RegisterNode adds a storage node to the set chunks can be placed on
+func (s *server) RegisterNode(ctx context.Context, req *pb.RegisterNodeRequest) (*pb.RegisterNodeResponse, error) {
+	if req.Address == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Storage node address is required")
+	}
+
+	s.nodes.register(req.Address, req.Stats)
+
+	return &pb.RegisterNodeResponse{
+		HeartbeatIntervalMs: s.nodes.heartbeatInterval.Milliseconds(),
+	}, nil
+}
+
+// Heartbeat records that a storage node is alive along with its latest stats
+func (s *server) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest) (*pb.HeartbeatResponse, error) {
+	known := s.nodes.heartbeat(req.Address, req.Stats)
+	return &pb.HeartbeatResponse{
+		Reregister: !known,
+	}, nil
+}
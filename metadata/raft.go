@@ -0,0 +1,770 @@
+// metadata/raft.go
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	rpb "dfs/proto/raft"
+
+	"google.golang.org/grpc"
+)
+
+const (
+	heartbeatInterval  = 100 * time.Millisecond
+	electionTimeoutMin = 500 * time.Millisecond
+	electionTimeoutMax = 1000 * time.Millisecond
+	raftRPCTimeout     = time.Second
+	proposeTimeout     = 5 * time.Second
+	maxAppendEntries   = 64 // Maximum number of entries sent in one AppendEntries call
+)
+
+// raftRole is the role a node currently plays in the cluster
+type raftRole int
+
+const (
+	follower raftRole = iota
+	candidate
+	leader
+)
+
+func (r raftRole) String() string {
+	switch r {
+	case follower:
+		return "follower"
+	case candidate:
+		return "candidate"
+	case leader:
+		return "leader"
+	}
+	return "unknown"
+}
+
+// notLeaderError is returned when a command is proposed to a node that is not
+// the leader. Leader holds the address of the current leader, if known.
+// Pending is set when the node lost leadership after appending the command,
+// which a new leader may still commit.
+type notLeaderError struct {
+	leader  string
+	pending bool
+}
+
+func (e *notLeaderError) Error() string {
+	msg := "not the leader"
+	if e.pending {
+		msg = "lost leadership before the command was committed; it may still be applied"
+	}
+	if e.leader == "" {
+		return msg + "; no leader is currently elected"
+	}
+	return fmt.Sprintf("%s; current leader is %s", msg, e.leader)
+}
+
+// stateMachine is the state replicated through the Raft log
+type stateMachine interface {
+	// applyCommand applies a committed command
+	applyCommand(cmd *command) error
+	// snapshotState encodes the current state as a snapshot ending at index and term
+	snapshotState(index, term uint64) ([]byte, error)
+	// restoreState replaces the current state with an encoded snapshot
+	restoreState(data []byte) error
+}
+
+// proposal tracks a command proposed by this node until it is applied
+type proposal struct {
+	term uint64
+	done chan error
+}
+
+// raftNode replicates the metadata command log across the metadata cluster
+// using the Raft consensus algorithm. Nodes are identified by the address
+// their gRPC server is reachable at.
+type raftNode struct {
+	rpb.UnimplementedRaftServiceServer
+
+	mu               sync.Mutex
+	applyCond        *sync.Cond
+	id               string
+	peers            []string
+	store            *store
+	fsm              stateMachine
+	role             raftRole
+	leaderID         string
+	commitIndex      uint64
+	lastApplied      uint64
+	nextIndex        map[string]uint64
+	matchIndex       map[string]uint64
+	electionDeadline time.Time
+	proposals        map[uint64]*proposal
+	wake             map[string]chan struct{} // Per-peer replication triggers while leader
+	clients          map[string]rpb.RaftServiceClient
+	dialOptions      []grpc.DialOption // For connecting to peers
+	stopped          bool
+	stop             chan struct{}
+}
+
+// newRaftNode creates a Raft node for id. peers lists the other members of
+// the cluster and are dialed with dialOptions. The state machine must already
+// reflect the store's snapshot.
+func newRaftNode(id string, peers []string, st *store, fsm stateMachine, dialOptions []grpc.DialOption) *raftNode {
+	r := &raftNode{
+		id:          id,
+		peers:       peers,
+		store:       st,
+		fsm:         fsm,
+		role:        follower,
+		commitIndex: st.snapIndex,
+		lastApplied: st.snapIndex,
+		nextIndex:   make(map[string]uint64),
+		matchIndex:  make(map[string]uint64),
+		proposals:   make(map[uint64]*proposal),
+		wake:        make(map[string]chan struct{}),
+		clients:     make(map[string]rpb.RaftServiceClient),
+		dialOptions: dialOptions,
+		stop:        make(chan struct{}),
+	}
+	r.applyCond = sync.NewCond(&r.mu)
+	r.resetElectionDeadline()
+
+	// A single-node cluster has nobody to wait for
+	if len(peers) == 0 {
+		r.electionDeadline = time.Now()
+	}
+
+	return r
+}
+
+// start launches the election timer and the applier
+func (r *raftNode) start() {
+	go r.runTimer()
+	go r.runApplier()
+}
+
+// close stops the node and closes its store
+func (r *raftNode) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopped {
+		return nil
+	}
+	r.stopped = true
+	close(r.stop)
+	r.applyCond.Broadcast()
+
+	return r.store.close()
+}
+
+// status reports the current leader, whether this node is the leader and the
+// current term
+func (r *raftNode) status() (string, bool, uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.leaderID, r.role == leader, r.store.hard.Term
+}
+
+// propose appends cmd to the log and waits until it has been committed and
+// applied, returning the result of applying it
+func (r *raftNode) propose(ctx context.Context, cmd *command) error {
+	r.mu.Lock()
+	if r.role != leader {
+		leaderID := r.leaderID
+		r.mu.Unlock()
+		return &notLeaderError{leader: leaderID}
+	}
+
+	entry := &walEntry{
+		Index:   r.store.lastIndex() + 1,
+		Term:    r.store.hard.Term,
+		Command: cmd,
+	}
+	if err := r.store.append(entry); err != nil {
+		r.mu.Unlock()
+		return err
+	}
+
+	p := &proposal{term: entry.Term, done: make(chan error, 1)}
+	r.proposals[entry.Index] = p
+	r.advanceCommitIndex()
+	r.triggerReplication()
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, proposeTimeout)
+	defer cancel()
+
+	select {
+	case err := <-p.done:
+		return err
+	case <-ctx.Done():
+		r.mu.Lock()
+		delete(r.proposals, entry.Index)
+		r.mu.Unlock()
+		return fmt.Errorf("command was not committed: %v", ctx.Err())
+	}
+}
+
+// snapshot compacts the log by snapshotting the state machine at the last
+// applied entry
+func (r *raftNode) snapshot() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopped || r.lastApplied == r.store.snapIndex {
+		return nil
+	}
+
+	term, ok := r.store.term(r.lastApplied)
+	if !ok {
+		return fmt.Errorf("term of applied entry %d is unknown", r.lastApplied)
+	}
+
+	data, err := r.fsm.snapshotState(r.lastApplied, term)
+	if err != nil {
+		return err
+	}
+	return r.store.saveSnapshot(r.lastApplied, term, data)
+}
+
+// runTimer starts an election whenever the election deadline passes without
+// hearing from a leader
+func (r *raftNode) runTimer() {
+	ticker := time.NewTicker(heartbeatInterval / 5)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.mu.Lock()
+			if r.role != leader && time.Now().After(r.electionDeadline) {
+				r.startElection()
+			}
+			r.mu.Unlock()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// runApplier applies committed entries to the state machine in log order and
+// completes the matching proposals
+func (r *raftNode) runApplier() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for {
+		for r.lastApplied >= r.commitIndex && !r.stopped {
+			r.applyCond.Wait()
+		}
+		if r.stopped {
+			return
+		}
+
+		for r.lastApplied < r.commitIndex {
+			entry := r.store.entry(r.lastApplied + 1)
+			if entry == nil {
+				log.Fatalf("Raft: committed entry %d is missing from the log", r.lastApplied+1)
+			}
+
+			err := r.fsm.applyCommand(entry.Command)
+			r.lastApplied = entry.Index
+
+			if p, ok := r.proposals[entry.Index]; ok {
+				delete(r.proposals, entry.Index)
+				// A different entry was committed in place of the proposal
+				if p.term != entry.Term {
+					err = &notLeaderError{leader: r.leaderID}
+				}
+				p.done <- err
+			}
+		}
+	}
+}
+
+// resetElectionDeadline picks a new randomized election deadline. The caller
+// must hold r.mu.
+func (r *raftNode) resetElectionDeadline() {
+	timeout := electionTimeoutMin + time.Duration(rand.Int63n(int64(electionTimeoutMax-electionTimeoutMin)))
+	r.electionDeadline = time.Now().Add(timeout)
+}
+
+// persistHardState records the current term and vote. Raft cannot make
+// progress safely without it, so a failure is fatal. The caller must hold r.mu.
+func (r *raftNode) persistHardState(term uint64, votedFor string) {
+	if err := r.store.saveHardState(hardState{Term: term, VotedFor: votedFor}); err != nil {
+		log.Fatalf("Raft: failed to persist hard state: %v", err)
+	}
+}
+
+// stepDown reverts to follower, adopting term if it is newer than the current
+// one. The caller must hold r.mu.
+func (r *raftNode) stepDown(term uint64) {
+	if term > r.store.hard.Term {
+		r.persistHardState(term, "")
+		r.leaderID = ""
+	}
+	if r.role != follower {
+		log.Printf("Raft: stepping down to follower in term %d", r.store.hard.Term)
+	}
+
+	// Nobody is left to commit the pending proposals, so fail them rather
+	// than leave their callers waiting out the timeout
+	for index, p := range r.proposals {
+		delete(r.proposals, index)
+		p.done <- &notLeaderError{leader: r.leaderID, pending: true}
+	}
+	r.role = follower
+	r.wake = make(map[string]chan struct{})
+}
+
+// clusterSize returns the number of voting members including this node
+func (r *raftNode) clusterSize() int {
+	return len(r.peers) + 1
+}
+
+// startElection makes this node a candidate and requests votes from its peers.
+// The caller must hold r.mu.
+func (r *raftNode) startElection() {
+	r.role = candidate
+	r.leaderID = ""
+	r.persistHardState(r.store.hard.Term+1, r.id)
+	r.resetElectionDeadline()
+
+	term := r.store.hard.Term
+	votes := 1
+	log.Printf("Raft: starting election for term %d", term)
+
+	if votes*2 > r.clusterSize() {
+		r.becomeLeader()
+		return
+	}
+
+	req := &rpb.RequestVoteRequest{
+		Term:         term,
+		CandidateId:  r.id,
+		LastLogIndex: r.store.lastIndex(),
+		LastLogTerm:  r.store.lastTerm(),
+	}
+
+	for _, peer := range r.peers {
+		go func(peer string) {
+			ctx, cancel := context.WithTimeout(context.Background(), raftRPCTimeout)
+			defer cancel()
+
+			resp, err := r.client(peer).RequestVote(ctx, req)
+			if err != nil {
+				return
+			}
+
+			r.mu.Lock()
+			defer r.mu.Unlock()
+
+			if resp.Term > r.store.hard.Term {
+				r.stepDown(resp.Term)
+				return
+			}
+			if r.role != candidate || r.store.hard.Term != term || !resp.VoteGranted {
+				return
+			}
+
+			votes++
+			if votes*2 > r.clusterSize() {
+				r.becomeLeader()
+			}
+		}(peer)
+	}
+}
+
+// becomeLeader takes over leadership for the current term. The caller must
+// hold r.mu.
+func (r *raftNode) becomeLeader() {
+	r.role = leader
+	r.leaderID = r.id
+	term := r.store.hard.Term
+	log.Printf("Raft: elected leader for term %d", term)
+
+	for _, peer := range r.peers {
+		r.nextIndex[peer] = r.store.lastIndex() + 1
+		r.matchIndex[peer] = 0
+
+		wake := make(chan struct{}, 1)
+		r.wake[peer] = wake
+		go r.runReplicator(peer, term, wake)
+	}
+
+	// Entries from earlier terms only commit once an entry from the current
+	// term does
+	entry := &walEntry{
+		Index:   r.store.lastIndex() + 1,
+		Term:    term,
+		Command: &command{Op: opNoop},
+	}
+	if err := r.store.append(entry); err != nil {
+		log.Fatalf("Raft: failed to append to log: %v", err)
+	}
+	r.advanceCommitIndex()
+	r.triggerReplication()
+}
+
+// triggerReplication wakes every replicator. The caller must hold r.mu.
+func (r *raftNode) triggerReplication() {
+	for _, wake := range r.wake {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// advanceCommitIndex commits the highest entry from the current term that is
+// stored on a majority of the cluster. The caller must hold r.mu.
+func (r *raftNode) advanceCommitIndex() {
+	for index := r.store.lastIndex(); index > r.commitIndex; index-- {
+		if term, _ := r.store.term(index); term != r.store.hard.Term {
+			break
+		}
+
+		replicas := 1
+		for _, peer := range r.peers {
+			if r.matchIndex[peer] >= index {
+				replicas++
+			}
+		}
+		if replicas*2 > r.clusterSize() {
+			r.commitIndex = index
+			r.applyCond.Broadcast()
+			return
+		}
+	}
+}
+
+// runReplicator sends log entries and heartbeats to peer for as long as this
+// node remains leader of term
+func (r *raftNode) runReplicator(peer string, term uint64, wake chan struct{}) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		r.mu.Lock()
+		active := r.role == leader && r.store.hard.Term == term && !r.stopped
+		r.mu.Unlock()
+		if !active {
+			return
+		}
+
+		r.replicateTo(peer, term)
+
+		select {
+		case <-ticker.C:
+		case <-wake:
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// replicateTo sends peer the entries it is missing, or the snapshot if those
+// entries have already been compacted
+func (r *raftNode) replicateTo(peer string, term uint64) {
+	r.mu.Lock()
+	next := r.nextIndex[peer]
+	if next <= r.store.snapIndex {
+		r.mu.Unlock()
+		r.sendSnapshot(peer, term)
+		return
+	}
+
+	prevIndex := next - 1
+	prevTerm, _ := r.store.term(prevIndex)
+	entries := r.store.entriesFrom(next, maxAppendEntries)
+	pbEntries := make([]*rpb.LogEntry, len(entries))
+	for i, entry := range entries {
+		data, err := json.Marshal(entry.Command)
+		if err != nil {
+			r.mu.Unlock()
+			log.Printf("Raft: failed to encode entry %d: %v", entry.Index, err)
+			return
+		}
+		pbEntries[i] = &rpb.LogEntry{
+			Index:   entry.Index,
+			Term:    entry.Term,
+			Command: data,
+		}
+	}
+	req := &rpb.AppendEntriesRequest{
+		Term:         term,
+		LeaderId:     r.id,
+		PrevLogIndex: prevIndex,
+		PrevLogTerm:  prevTerm,
+		Entries:      pbEntries,
+		LeaderCommit: r.commitIndex,
+	}
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), raftRPCTimeout)
+	defer cancel()
+
+	resp, err := r.client(peer).AppendEntries(ctx, req)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if resp.Term > r.store.hard.Term {
+		r.stepDown(resp.Term)
+		return
+	}
+	if r.role != leader || r.store.hard.Term != term {
+		return
+	}
+
+	if resp.Success {
+		match := prevIndex + uint64(len(entries))
+		if match > r.matchIndex[peer] {
+			r.matchIndex[peer] = match
+		}
+		r.nextIndex[peer] = match + 1
+		r.advanceCommitIndex()
+	} else if resp.ConflictIndex > next {
+		// The peer's snapshot already holds the entries sent, which are
+		// committed and so match ours; carry on from the end of it
+		r.nextIndex[peer] = min(resp.ConflictIndex, r.store.lastIndex()+1)
+	} else if resp.ConflictIndex > 0 && resp.ConflictIndex < next {
+		r.nextIndex[peer] = resp.ConflictIndex
+	} else if next > 1 {
+		r.nextIndex[peer] = next - 1
+	}
+
+	// Keep going while the peer is behind
+	if r.nextIndex[peer] <= r.store.lastIndex() {
+		if wake, ok := r.wake[peer]; ok {
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// sendSnapshot installs the latest snapshot on peer
+func (r *raftNode) sendSnapshot(peer string, term uint64) {
+	r.mu.Lock()
+	data, err := r.store.readSnapshot()
+	if err != nil {
+		r.mu.Unlock()
+		log.Printf("Raft: failed to read snapshot for %s: %v", peer, err)
+		return
+	}
+	req := &rpb.InstallSnapshotRequest{
+		Term:              term,
+		LeaderId:          r.id,
+		LastIncludedIndex: r.store.snapIndex,
+		LastIncludedTerm:  r.store.snapTerm,
+		Data:              data,
+	}
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), raftRPCTimeout)
+	defer cancel()
+
+	resp, err := r.client(peer).InstallSnapshot(ctx, req)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if resp.Term > r.store.hard.Term {
+		r.stepDown(resp.Term)
+		return
+	}
+	if r.role != leader || r.store.hard.Term != term {
+		return
+	}
+
+	if req.LastIncludedIndex > r.matchIndex[peer] {
+		r.matchIndex[peer] = req.LastIncludedIndex
+	}
+	r.nextIndex[peer] = req.LastIncludedIndex + 1
+	log.Printf("Raft: installed snapshot at index %d on %s", req.LastIncludedIndex, peer)
+}
+
+// client returns a RaftService client for peer, dialing it on first use
+func (r *raftNode) client(peer string) rpb.RaftServiceClient {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if client, exists := r.clients[peer]; exists {
+		return client
+	}
+
+	// Dialing is non-blocking, so this only fails on an invalid target
+	conn, err := grpc.Dial(peer, r.dialOptions...)
+	if err != nil {
+		log.Fatalf("Raft: invalid peer address %s: %v", peer, err)
+	}
+
+	client := rpb.NewRaftServiceClient(conn)
+	r.clients[peer] = client
+	return client
+}
+
+// RequestVote handles a vote request from a candidate
+func (r *raftNode) RequestVote(ctx context.Context, req *rpb.RequestVoteRequest) (*rpb.RequestVoteResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.Term > r.store.hard.Term {
+		r.stepDown(req.Term)
+	}
+
+	resp := &rpb.RequestVoteResponse{Term: r.store.hard.Term}
+	if req.Term < r.store.hard.Term {
+		return resp, nil
+	}
+
+	// Only vote for candidates whose log is at least as up to date as ours
+	upToDate := req.LastLogTerm > r.store.lastTerm() ||
+		(req.LastLogTerm == r.store.lastTerm() && req.LastLogIndex >= r.store.lastIndex())
+	votedFor := r.store.hard.VotedFor
+
+	if upToDate && (votedFor == "" || votedFor == req.CandidateId) {
+		r.persistHardState(r.store.hard.Term, req.CandidateId)
+		r.resetElectionDeadline()
+		resp.VoteGranted = true
+	}
+
+	return resp, nil
+}
+
+// AppendEntries handles log replication and heartbeats from the leader
+func (r *raftNode) AppendEntries(ctx context.Context, req *rpb.AppendEntriesRequest) (*rpb.AppendEntriesResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.Term < r.store.hard.Term {
+		return &rpb.AppendEntriesResponse{Term: r.store.hard.Term}, nil
+	}
+	if req.Term > r.store.hard.Term || r.role != follower {
+		r.stepDown(req.Term)
+	}
+	r.leaderID = req.LeaderId
+	r.resetElectionDeadline()
+
+	resp := &rpb.AppendEntriesResponse{Term: r.store.hard.Term}
+
+	// Entries up to the snapshot are already committed here; point the
+	// leader past them
+	if req.PrevLogIndex < r.store.snapIndex {
+		resp.ConflictIndex = r.store.snapIndex + 1
+		return resp, nil
+	}
+
+	// The log is missing entries before the new ones
+	if req.PrevLogIndex > r.store.lastIndex() {
+		resp.ConflictIndex = r.store.lastIndex() + 1
+		return resp, nil
+	}
+
+	// The log disagrees at PrevLogIndex; skip back over the conflicting term
+	if term, _ := r.store.term(req.PrevLogIndex); term != req.PrevLogTerm {
+		index := req.PrevLogIndex
+		for index > r.store.snapIndex+1 {
+			if t, _ := r.store.term(index - 1); t != term {
+				break
+			}
+			index--
+		}
+		resp.ConflictIndex = index
+		return resp, nil
+	}
+
+	// Append any new entries, replacing a conflicting suffix
+	var newEntries []*walEntry
+	for i, pbEntry := range req.Entries {
+		if existing := r.store.entry(pbEntry.Index); existing != nil {
+			if existing.Term == pbEntry.Term {
+				continue
+			}
+			if err := r.store.truncateFrom(pbEntry.Index); err != nil {
+				log.Fatalf("Raft: failed to truncate log: %v", err)
+			}
+		}
+
+		for _, pbEntry := range req.Entries[i:] {
+			var cmd command
+			if err := json.Unmarshal(pbEntry.Command, &cmd); err != nil {
+				return nil, fmt.Errorf("failed to decode entry %d: %v", pbEntry.Index, err)
+			}
+			newEntries = append(newEntries, &walEntry{
+				Index:   pbEntry.Index,
+				Term:    pbEntry.Term,
+				Command: &cmd,
+			})
+		}
+		break
+	}
+	if len(newEntries) > 0 {
+		if err := r.store.append(newEntries...); err != nil {
+			log.Fatalf("Raft: failed to append to log: %v", err)
+		}
+	}
+
+	// Advance the commit index up to the last entry known to match the leader
+	lastNew := req.PrevLogIndex + uint64(len(req.Entries))
+	if req.LeaderCommit > r.commitIndex {
+		r.commitIndex = req.LeaderCommit
+		if lastNew < r.commitIndex {
+			r.commitIndex = lastNew
+		}
+		r.applyCond.Broadcast()
+	}
+
+	resp.Success = true
+	return resp, nil
+}
+
+// InstallSnapshot replaces the local state with the leader's snapshot
+func (r *raftNode) InstallSnapshot(ctx context.Context, req *rpb.InstallSnapshotRequest) (*rpb.InstallSnapshotResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.Term < r.store.hard.Term {
+		return &rpb.InstallSnapshotResponse{Term: r.store.hard.Term}, nil
+	}
+	if req.Term > r.store.hard.Term || r.role != follower {
+		r.stepDown(req.Term)
+	}
+	r.leaderID = req.LeaderId
+	r.resetElectionDeadline()
+
+	resp := &rpb.InstallSnapshotResponse{Term: r.store.hard.Term}
+
+	// Nothing to do if the snapshot is older than what has been applied
+	if req.LastIncludedIndex <= r.lastApplied {
+		return resp, nil
+	}
+
+	if err := r.store.saveSnapshot(req.LastIncludedIndex, req.LastIncludedTerm, req.Data); err != nil {
+		return nil, fmt.Errorf("failed to save snapshot: %v", err)
+	}
+	if err := r.fsm.restoreState(req.Data); err != nil {
+		return nil, fmt.Errorf("failed to restore snapshot: %v", err)
+	}
+
+	r.lastApplied = req.LastIncludedIndex
+	if r.commitIndex < req.LastIncludedIndex {
+		r.commitIndex = req.LastIncludedIndex
+	}
+	log.Printf("Raft: restored snapshot at index %d from %s", req.LastIncludedIndex, req.LeaderId)
+
+	return resp, nil
+}
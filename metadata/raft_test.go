@@ -0,0 +1,633 @@
+// metadata/raft_test.go
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	rpb "dfs/proto/raft"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testFSM records the commands applied to it and the snapshots restored
+type testFSM struct {
+	mu       sync.Mutex
+	applied  []string // File name of every applied command other than no-ops
+	restored []byte
+}
+
+func (f *testFSM) applyCommand(cmd *command) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if cmd.Op != opNoop {
+		f.applied = append(f.applied, cmd.File.FileName)
+	}
+	return nil
+}
+
+func (f *testFSM) snapshotState(index, term uint64) ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"index":%d,"term":%d}`, index, term)), nil
+}
+
+func (f *testFSM) restoreState(data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.restored = data
+	return nil
+}
+
+// hasApplied reports whether a command for fileName has been applied
+func (f *testFSM) hasApplied(fileName string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, applied := range f.applied {
+		if applied == fileName {
+			return true
+		}
+	}
+	return false
+}
+
+// newTestRaftNode returns a stopped Raft node for id whose log holds one
+// no-op entry per term in terms, after a snapshot ending at snapIndex in
+// term 1 if snapIndex is set
+func newTestRaftNode(t *testing.T, id string, peers []string, snapIndex uint64, terms ...uint64) (*raftNode, *testFSM) {
+	t.Helper()
+	dir := t.TempDir()
+	st, _, err := openStore(dir)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	if snapIndex > 0 {
+		if err := st.saveSnapshot(snapIndex, 1, []byte("{}")); err != nil {
+			t.Fatalf("save snapshot: %v", err)
+		}
+	}
+	for i, term := range terms {
+		entry := &walEntry{Index: snapIndex + uint64(i) + 1, Term: term, Command: &command{Op: opNoop}}
+		if err := st.append(entry); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if len(terms) > 0 {
+		if err := st.saveHardState(hardState{Term: terms[len(terms)-1]}); err != nil {
+			t.Fatalf("save hard state: %v", err)
+		}
+	}
+
+	fsm := &testFSM{}
+	return newRaftNode(id, peers, st, fsm, nil), fsm
+}
+
+// logTerms returns the term of every entry in st after its snapshot
+func logTerms(st *store) []uint64 {
+	var terms []uint64
+	for _, entry := range st.entries {
+		terms = append(terms, entry.Term)
+	}
+	return terms
+}
+
+// pbEntries returns no-op log entries starting at index, one per term
+func pbEntries(t *testing.T, index uint64, terms ...uint64) []*rpb.LogEntry {
+	t.Helper()
+	data, err := json.Marshal(&command{Op: opNoop})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []*rpb.LogEntry
+	for i, term := range terms {
+		entries = append(entries, &rpb.LogEntry{Index: index + uint64(i), Term: term, Command: data})
+	}
+	return entries
+}
+
+func TestAppendEntries(t *testing.T) {
+	tests := []struct {
+		name          string
+		snapIndex     uint64
+		log           []uint64 // Terms of the follower's entries
+		term          uint64   // Leader term; the follower is in the term of its last entry
+		prevIndex     uint64
+		prevTerm      uint64
+		entries       []uint64 // Terms of the entries sent after prevIndex
+		success       bool
+		conflictIndex uint64
+		want          []uint64 // Terms of the follower's entries afterwards
+	}{
+		{
+			name:    "append to an empty log",
+			term:    1,
+			entries: []uint64{1, 1},
+			success: true,
+			want:    []uint64{1, 1},
+		},
+		{
+			name:      "heartbeat",
+			log:       []uint64{1, 1},
+			term:      1,
+			prevIndex: 2,
+			prevTerm:  1,
+			success:   true,
+			want:      []uint64{1, 1},
+		},
+		{
+			name:      "stale leader",
+			log:       []uint64{1, 2},
+			term:      1,
+			prevIndex: 2,
+			prevTerm:  1,
+			entries:   []uint64{1},
+			want:      []uint64{1, 2},
+		},
+		{
+			name:          "missing entries",
+			log:           []uint64{1},
+			term:          2,
+			prevIndex:     3,
+			prevTerm:      2,
+			entries:       []uint64{2},
+			conflictIndex: 2,
+			want:          []uint64{1},
+		},
+		{
+			name:          "conflicting term is skipped as a whole",
+			log:           []uint64{1, 2, 2, 2},
+			term:          3,
+			prevIndex:     4,
+			prevTerm:      3,
+			entries:       []uint64{3},
+			conflictIndex: 2,
+			want:          []uint64{1, 2, 2, 2},
+		},
+		{
+			name:      "conflicting suffix is truncated",
+			log:       []uint64{1, 2, 2, 2},
+			term:      3,
+			prevIndex: 1,
+			prevTerm:  1,
+			entries:   []uint64{3, 3},
+			success:   true,
+			want:      []uint64{1, 3, 3},
+		},
+		{
+			name:      "stale duplicate keeps later entries",
+			log:       []uint64{1, 1, 1},
+			term:      1,
+			prevIndex: 0,
+			prevTerm:  0,
+			entries:   []uint64{1},
+			success:   true,
+			want:      []uint64{1, 1, 1},
+		},
+		{
+			name:          "entries before the snapshot",
+			snapIndex:     5,
+			log:           []uint64{2},
+			term:          2,
+			prevIndex:     2,
+			prevTerm:      1,
+			entries:       []uint64{1, 1},
+			conflictIndex: 6,
+			want:          []uint64{2},
+		},
+		{
+			name:      "entries right after the snapshot",
+			snapIndex: 5,
+			log:       []uint64{1},
+			term:      2,
+			prevIndex: 5,
+			prevTerm:  1,
+			entries:   []uint64{2, 2},
+			success:   true,
+			want:      []uint64{2, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newTestRaftNode(t, "a", []string{"b"}, tt.snapIndex, tt.log...)
+			dir := r.store.dir
+
+			resp, err := r.AppendEntries(context.Background(), &rpb.AppendEntriesRequest{
+				Term:         tt.term,
+				LeaderId:     "b",
+				PrevLogIndex: tt.prevIndex,
+				PrevLogTerm:  tt.prevTerm,
+				Entries:      pbEntries(t, tt.prevIndex+1, tt.entries...),
+			})
+			if err != nil {
+				t.Fatalf("AppendEntries: %v", err)
+			}
+			if resp.Success != tt.success || resp.ConflictIndex != tt.conflictIndex {
+				t.Errorf("success = %v, conflict index = %d, want %v, %d", resp.Success, resp.ConflictIndex, tt.success, tt.conflictIndex)
+			}
+			if got := logTerms(r.store); !equalIndexes(got, tt.want) {
+				t.Errorf("log terms = %v, want %v", got, tt.want)
+			}
+
+			// The log on disk matches the log in memory
+			r.close()
+			st, _, err := openStore(dir)
+			if err != nil {
+				t.Fatalf("reopen store: %v", err)
+			}
+			defer st.close()
+			if got := logTerms(st); !equalIndexes(got, tt.want) {
+				t.Errorf("log terms after reopening = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstallSnapshot(t *testing.T) {
+	tests := []struct {
+		name      string
+		applied   uint64 // Entries the follower has applied
+		lastIndex uint64 // LastIncludedIndex of the snapshot
+		lastTerm  uint64 // LastIncludedTerm of the snapshot
+		restored  bool
+		snapIndex uint64   // Snapshot index of the follower afterwards
+		want      []uint64 // Terms of the follower's entries afterwards
+	}{
+		{
+			name:      "older than the applied entries",
+			applied:   3,
+			lastIndex: 2,
+			lastTerm:  1,
+			want:      []uint64{1, 1, 1, 2, 2},
+		},
+		{
+			name:      "matching the log keeps later entries",
+			applied:   1,
+			lastIndex: 3,
+			lastTerm:  1,
+			restored:  true,
+			snapIndex: 3,
+			want:      []uint64{2, 2},
+		},
+		{
+			name:      "conflicting with the log discards it",
+			applied:   1,
+			lastIndex: 4,
+			lastTerm:  3,
+			restored:  true,
+			snapIndex: 4,
+		},
+		{
+			name:      "past the end of the log",
+			lastIndex: 9,
+			lastTerm:  3,
+			restored:  true,
+			snapIndex: 9,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, fsm := newTestRaftNode(t, "a", []string{"b"}, 0, 1, 1, 1, 2, 2)
+			r.lastApplied = tt.applied
+			r.commitIndex = tt.applied
+			dir := r.store.dir
+			data := []byte(fmt.Sprintf(`{"index":%d,"term":%d}`, tt.lastIndex, tt.lastTerm))
+
+			_, err := r.InstallSnapshot(context.Background(), &rpb.InstallSnapshotRequest{
+				Term:              3,
+				LeaderId:          "b",
+				LastIncludedIndex: tt.lastIndex,
+				LastIncludedTerm:  tt.lastTerm,
+				Data:              data,
+			})
+			if err != nil {
+				t.Fatalf("InstallSnapshot: %v", err)
+			}
+
+			if restored := fsm.restored != nil; restored != tt.restored {
+				t.Errorf("restored = %v, want %v", restored, tt.restored)
+			}
+			if tt.restored && (r.lastApplied != tt.lastIndex || r.commitIndex != tt.lastIndex) {
+				t.Errorf("applied %d, committed %d, want %d", r.lastApplied, r.commitIndex, tt.lastIndex)
+			}
+			if r.store.snapIndex != tt.snapIndex {
+				t.Errorf("snapshot index = %d, want %d", r.store.snapIndex, tt.snapIndex)
+			}
+			if got := logTerms(r.store); !equalIndexes(got, tt.want) {
+				t.Errorf("log terms = %v, want %v", got, tt.want)
+			}
+
+			// The installed snapshot and the remaining log survive a restart
+			r.close()
+			st, snapData, err := openStore(dir)
+			if err != nil {
+				t.Fatalf("reopen store: %v", err)
+			}
+			defer st.close()
+			if st.snapIndex != tt.snapIndex {
+				t.Errorf("snapshot index after reopening = %d, want %d", st.snapIndex, tt.snapIndex)
+			}
+			if tt.restored && string(snapData) != string(data) {
+				t.Errorf("snapshot after reopening = %s, want %s", snapData, data)
+			}
+			if got := logTerms(st); !equalIndexes(got, tt.want) {
+				t.Errorf("log terms after reopening = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// stubRaftClient answers AppendEntries calls with a fixed response
+type stubRaftClient struct {
+	rpb.RaftServiceClient
+	resp *rpb.AppendEntriesResponse
+}
+
+func (c *stubRaftClient) AppendEntries(ctx context.Context, in *rpb.AppendEntriesRequest, opts ...grpc.CallOption) (*rpb.AppendEntriesResponse, error) {
+	return c.resp, nil
+}
+
+func TestReplicateToConflict(t *testing.T) {
+	tests := []struct {
+		name          string
+		next          uint64
+		conflictIndex uint64
+		want          uint64
+	}{
+		{name: "no hint steps back one entry", next: 5, want: 4},
+		{name: "earlier conflict", next: 5, conflictIndex: 2, want: 2},
+		{name: "follower snapshot ahead of next", next: 2, conflictIndex: 8, want: 8},
+		{name: "follower snapshot past the log", next: 2, conflictIndex: 50, want: 11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newTestRaftNode(t, "a", []string{"b"}, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1)
+			defer r.close()
+			r.role = leader
+			r.nextIndex["b"] = tt.next
+			r.clients["b"] = &stubRaftClient{resp: &rpb.AppendEntriesResponse{Term: 1, ConflictIndex: tt.conflictIndex}}
+
+			r.replicateTo("b", 1)
+			if got := r.nextIndex["b"]; got != tt.want {
+				t.Errorf("next index = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// testNetwork connects Raft nodes in memory and can cut nodes off
+type testNetwork struct {
+	mu       sync.RWMutex // Held for reading for the length of every call
+	nodes    map[string]*raftNode
+	isolated map[string]bool
+	closed   bool
+}
+
+// isolate cuts the given nodes off from the rest, healing any earlier cut
+func (n *testNetwork) isolate(ids ...string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.isolated = make(map[string]bool)
+	for _, id := range ids {
+		n.isolated[id] = true
+	}
+}
+
+// reach returns the node to, or an error if from cannot reach it. The caller
+// must hold n.mu for reading.
+func (n *testNetwork) reach(from, to string) (*raftNode, error) {
+	if n.closed || n.isolated[from] || n.isolated[to] {
+		return nil, status.Errorf(codes.Unavailable, "%s cannot reach %s", from, to)
+	}
+	return n.nodes[to], nil
+}
+
+// testRaftClient calls a peer through a testNetwork
+type testRaftClient struct {
+	net      *testNetwork
+	from, to string
+}
+
+func (c *testRaftClient) RequestVote(ctx context.Context, in *rpb.RequestVoteRequest, opts ...grpc.CallOption) (*rpb.RequestVoteResponse, error) {
+	c.net.mu.RLock()
+	defer c.net.mu.RUnlock()
+	peer, err := c.net.reach(c.from, c.to)
+	if err != nil {
+		return nil, err
+	}
+	return peer.RequestVote(ctx, in)
+}
+
+func (c *testRaftClient) AppendEntries(ctx context.Context, in *rpb.AppendEntriesRequest, opts ...grpc.CallOption) (*rpb.AppendEntriesResponse, error) {
+	c.net.mu.RLock()
+	defer c.net.mu.RUnlock()
+	peer, err := c.net.reach(c.from, c.to)
+	if err != nil {
+		return nil, err
+	}
+	return peer.AppendEntries(ctx, in)
+}
+
+func (c *testRaftClient) InstallSnapshot(ctx context.Context, in *rpb.InstallSnapshotRequest, opts ...grpc.CallOption) (*rpb.InstallSnapshotResponse, error) {
+	c.net.mu.RLock()
+	defer c.net.mu.RUnlock()
+	peer, err := c.net.reach(c.from, c.to)
+	if err != nil {
+		return nil, err
+	}
+	return peer.InstallSnapshot(ctx, in)
+}
+
+// waitFor polls cond until it holds, failing the test after timeout
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// leaderAmong returns the node among ids that is leader in the highest term,
+// or nil if none is
+func leaderAmong(net *testNetwork, ids []string) *raftNode {
+	var found *raftNode
+	var foundTerm uint64
+	for _, id := range ids {
+		node := net.nodes[id]
+		if _, isLeader, term := node.status(); isLeader && term >= foundTerm {
+			found, foundTerm = node, term
+		}
+	}
+	return found
+}
+
+func TestElectionWithPartition(t *testing.T) {
+	tests := []struct {
+		name     string
+		nodes    int
+		isolated int // Nodes cut off from the majority at first
+	}{
+		{name: "three nodes, one cut off", nodes: 3, isolated: 1},
+		{name: "five nodes, two cut off", nodes: 5, isolated: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for i := 0; i < tt.nodes; i++ {
+				ids = append(ids, fmt.Sprintf("node%d", i))
+			}
+
+			net := &testNetwork{nodes: make(map[string]*raftNode), isolated: make(map[string]bool)}
+			fsms := make(map[string]*testFSM)
+			for _, id := range ids {
+				var peers []string
+				for _, peer := range ids {
+					if peer != id {
+						peers = append(peers, peer)
+					}
+				}
+				node, fsm := newTestRaftNode(t, id, peers, 0)
+				for _, peer := range peers {
+					node.clients[peer] = &testRaftClient{net: net, from: id, to: peer}
+				}
+				net.nodes[id] = node
+				fsms[id] = fsm
+			}
+			t.Cleanup(func() {
+				net.mu.Lock()
+				net.closed = true
+				net.mu.Unlock()
+				// Let responses already received be handled before the
+				// stores close
+				time.Sleep(heartbeatInterval)
+				for _, node := range net.nodes {
+					node.close()
+				}
+			})
+
+			majority, minority := ids[:tt.nodes-tt.isolated], ids[tt.nodes-tt.isolated:]
+			net.isolate(minority...)
+			for _, node := range net.nodes {
+				node.start()
+			}
+
+			// The majority elects a leader and commits without the minority
+			var first *raftNode
+			waitFor(t, 10*time.Second, "a leader in the majority", func() bool {
+				first = leaderAmong(net, majority)
+				return first != nil
+			})
+			if err := first.propose(context.Background(), &command{Op: opCreateFile, File: &FileMetadata{FileName: "first"}}); err != nil {
+				t.Fatalf("propose to %s: %v", first.id, err)
+			}
+			for _, id := range minority {
+				if _, isLeader, _ := net.nodes[id].status(); isLeader {
+					t.Errorf("cut off node %s became leader", id)
+				}
+				if fsms[id].hasApplied("first") {
+					t.Errorf("cut off node %s applied a command it never received", id)
+				}
+			}
+
+			// Once the leader is cut off instead, the rest elect a new one that
+			// holds everything committed so far
+			net.isolate(first.id)
+			var rest []string
+			for _, id := range ids {
+				if id != first.id {
+					rest = append(rest, id)
+				}
+			}
+			var second *raftNode
+			waitFor(t, 10*time.Second, "a new leader", func() bool {
+				second = leaderAmong(net, rest)
+				return second != nil
+			})
+			if err := second.propose(context.Background(), &command{Op: opCreateFile, File: &FileMetadata{FileName: "second"}}); err != nil {
+				t.Fatalf("propose to %s: %v", second.id, err)
+			}
+			if !fsms[second.id].hasApplied("first") {
+				t.Errorf("new leader %s lost a committed command", second.id)
+			}
+
+			// The old leader still accepts a proposal it cannot commit
+			first.mu.Lock()
+			staleIndex := first.store.lastIndex() + 1
+			first.mu.Unlock()
+			stale := make(chan error, 1)
+			go func() {
+				stale <- first.propose(context.Background(), &command{Op: opCreateFile, File: &FileMetadata{FileName: "stale"}})
+			}()
+			waitFor(t, 5*time.Second, "the stale proposal to be appended", func() bool {
+				first.mu.Lock()
+				defer first.mu.Unlock()
+				return first.store.lastIndex() >= staleIndex
+			})
+
+			// After healing, the old leader steps down and fails the proposal
+			// instead of leaving it to time out, and every node catches up
+			net.isolate()
+			select {
+			case err := <-stale:
+				var notLeader *notLeaderError
+				if !errors.As(err, &notLeader) {
+					t.Errorf("stale proposal error = %v, want not the leader", err)
+				}
+			case <-time.After(proposeTimeout / 2):
+				t.Errorf("stale proposal was not failed when %s stepped down", first.id)
+			}
+			for _, id := range ids {
+				fsm := fsms[id]
+				waitFor(t, 10*time.Second, id+" to catch up", func() bool {
+					return fsm.hasApplied("first") && fsm.hasApplied("second")
+				})
+				if fsm.hasApplied("stale") {
+					t.Errorf("%s applied a proposal the old leader failed", id)
+				}
+			}
+		})
+	}
+}
+
+func TestNotLeaderStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    *notLeaderError
+		tagged bool
+	}{
+		{name: "rejected before appending", err: &notLeaderError{leader: "node1"}, tagged: true},
+		{name: "no leader elected", err: &notLeaderError{}, tagged: true},
+		{name: "lost leadership while pending", err: &notLeaderError{leader: "node1", pending: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := status.Convert(notLeaderStatus(tt.err))
+			if st.Code() != codes.Unavailable {
+				t.Fatalf("code = %v, want Unavailable", st.Code())
+			}
+			tagged := false
+			for _, detail := range st.Details() {
+				if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Reason == notLeaderReason {
+					tagged = true
+				}
+			}
+			if tagged != tt.tagged {
+				t.Errorf("tagged = %v, want %v", tagged, tt.tagged)
+			}
+		})
+	}
+}
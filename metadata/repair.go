@@ -0,0 +1,331 @@
+// metadata/repair.go
+
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	pb "dfs/proto/metadata"
+	storagePb "dfs/proto/storage"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// replicateTimeout bounds a single node-to-node chunk copy
+const replicateTimeout = 5 * time.Minute
+
+// repairState records the progress of the re-replication loop for the admin
+// RPC
+type repairState struct {
+	mu              sync.Mutex
+	underReplicated int64
+	lost            int64
+	repaired        int64
+	failed          int64
+	lastScan        time.Time
+	inProgress      map[string]*pb.RepairTask // Keyed by chunk ID
+}
+
+// newRepairState creates an empty repair state
+func newRepairState() *repairState {
+	return &repairState{
+		inProgress: make(map[string]*pb.RepairTask),
+	}
+}
+
+// repairJob is a chunk with fewer live replicas than its file requires
+type repairJob struct {
+	fileName string
+	chunkID  string
+	checksum *uint32
+	live     []string // Replicas on live storage nodes
+	factor   int
+}
+
+// RunRepairs periodically re-replicates under-replicated chunks while this
+// node is the leader, until stop is closed
+func (s *server) RunRepairs(interval time.Duration, concurrency int, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Only the leader repairs, and only once storage nodes have had
+			// time to report in
+			if _, isLeader, _ := s.raft.status(); !isLeader || !s.nodes.settled() {
+				continue
+			}
+			s.repairPass(concurrency)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// findUnderReplicated scans the namespace for chunks whose live replica count
+// is below the file's replication factor. It also returns the number of
+// chunks without any live replica, which cannot be repaired.
+func (s *server) findUnderReplicated() ([]repairJob, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var jobs []repairJob
+	var lost int64
+
+	// Snapshots share chunks with live files, so look at each chunk once
+	seen := make(map[string]bool)
+
+	for _, fileMeta := range s.allFiles() {
+		for _, chunk := range fileMeta.Chunks {
+			if seen[chunk.ChunkID] {
+				continue
+			}
+			seen[chunk.ChunkID] = true
+
+			var live []string
+			for _, replica := range chunk.Replicas {
+				if s.nodes.isAlive(replica) {
+					live = append(live, replica)
+				}
+			}
+
+			if len(live) >= fileMeta.ReplicationFactor {
+				continue
+			}
+			if len(live) == 0 {
+				lost++
+				continue
+			}
+
+			jobs = append(jobs, repairJob{
+				fileName: fileMeta.FileName,
+				chunkID:  chunk.ChunkID,
+				checksum: chunk.Checksum,
+				live:     live,
+				factor:   fileMeta.ReplicationFactor,
+			})
+		}
+	}
+
+	return jobs, lost
+}
+
+// repairPass runs one scan and repairs every under-replicated chunk it finds,
+// at most concurrency chunks at a time
+func (s *server) repairPass(concurrency int) {
+	jobs, lost := s.findUnderReplicated()
+
+	s.repair.mu.Lock()
+	s.repair.underReplicated = int64(len(jobs))
+	s.repair.lost = lost
+	s.repair.lastScan = time.Now()
+	s.repair.mu.Unlock()
+
+	if lost > 0 {
+		log.Printf("Repair: %d chunks have no live replica and cannot be repaired", lost)
+	}
+	if len(jobs) == 0 {
+		return
+	}
+	log.Printf("Repair: found %d under-replicated chunks", len(jobs))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for _, job := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(job repairJob) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.repairChunk(job)
+		}(job)
+	}
+	wg.Wait()
+}
+
+// repairChunk copies a chunk from a surviving replica to new storage nodes
+// until it is back at its replication factor, then records the new replicas.
+// A source that cannot be copied from, because its node is unreachable or
+// its copy is corrupt, is skipped in favour of the next live one; a target
+// that fails to take the copy is replaced by another node.
+func (s *server) repairChunk(job repairJob) {
+	replicas := append([]string(nil), job.live...)
+	exclude := append([]string(nil), job.live...) // Replicas and failed targets
+	sources := job.live
+
+	for len(replicas) < job.factor {
+		target, err := s.nodes.pickNode(exclude)
+		if err != nil {
+			log.Printf("Repair: cannot place a new replica of chunk %s: %v", job.chunkID, err)
+			if len(exclude) > len(replicas) {
+				s.repair.mu.Lock()
+				s.repair.failed++
+				s.repair.mu.Unlock()
+			}
+			break
+		}
+		exclude = append(exclude, target)
+
+		// Copy from the first source that works
+		for len(sources) > 0 {
+			err = s.copyChunk(job.chunkID, job.checksum, sources[0], target)
+			if err == nil || !sourceFailed(err) {
+				break
+			}
+			log.Printf("Repair: failed to copy chunk %s from %s to %s: %v", job.chunkID, sources[0], target, err)
+			sources = sources[1:]
+		}
+		if len(sources) == 0 {
+			s.repair.mu.Lock()
+			s.repair.failed++
+			s.repair.mu.Unlock()
+			break
+		}
+		if err != nil {
+			log.Printf("Repair: %s failed to store chunk %s, trying another node: %v", target, job.chunkID, err)
+			continue
+		}
+
+		replicas = append(replicas, target)
+		s.repair.mu.Lock()
+		s.repair.repaired++
+		s.repair.mu.Unlock()
+	}
+
+	if len(replicas) == len(job.live) {
+		return
+	}
+
+	// Only add the new replicas; the list may have changed since the scan,
+	// and a replica dropped meanwhile must not come back
+	err := s.commit(context.Background(), &command{
+		Op:       opAddReplicas,
+		FileName: job.fileName,
+		ChunkID:  job.chunkID,
+		Replicas: replicas[len(job.live):],
+	})
+	if err != nil {
+		log.Printf("Repair: failed to record new replicas of chunk %s: %v", job.chunkID, err)
+		return
+	}
+
+	log.Printf("Repair: chunk %s has new replicas %v", job.chunkID, replicas[len(job.live):])
+}
+
+// sourceFailed reports whether a ReplicateChunk error is the fault of the
+// source replica rather than of the target node
+func sourceFailed(err error) bool {
+	code := status.Code(err)
+	return code == codes.FailedPrecondition || code == codes.DataLoss
+}
+
+// copyChunk instructs target to copy chunkID from source. The copy is
+// rejected if it does not match checksum.
+func (s *server) copyChunk(chunkID string, checksum *uint32, source, target string) error {
+	s.repair.mu.Lock()
+	s.repair.inProgress[chunkID] = &pb.RepairTask{
+		ChunkId:   chunkID,
+		Source:    source,
+		Target:    target,
+		StartedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	s.repair.mu.Unlock()
+
+	defer func() {
+		s.repair.mu.Lock()
+		delete(s.repair.inProgress, chunkID)
+		s.repair.mu.Unlock()
+	}()
+
+	client, err := s.nodes.client(target)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), replicateTimeout)
+	defer cancel()
+
+	_, err = client.ReplicateChunk(ctx, &storagePb.ReplicateChunkRequest{
+		ChunkId:  chunkID,
+		Source:   source,
+		Checksum: checksum,
+	})
+	return err
+}
+
+// GetRepairStatus reports the progress of chunk re-replication
+func (s *server) GetRepairStatus(ctx context.Context, req *pb.GetRepairStatusRequest) (*pb.GetRepairStatusResponse, error) {
+	if err := requireAdmin(caller(ctx)); err != nil {
+		return nil, err
+	}
+
+	_, isLeader, _ := s.raft.status()
+
+	s.repair.mu.Lock()
+	defer s.repair.mu.Unlock()
+
+	resp := &pb.GetRepairStatusResponse{
+		IsLeader:              isLeader,
+		UnderReplicatedChunks: s.repair.underReplicated,
+		LostChunks:            s.repair.lost,
+		RepairedChunks:        s.repair.repaired,
+		FailedRepairs:         s.repair.failed,
+	}
+	if !s.repair.lastScan.IsZero() {
+		resp.LastScan = s.repair.lastScan.Format("2006-01-02 15:04:05")
+	}
+	for _, task := range s.repair.inProgress {
+		resp.InProgress = append(resp.InProgress, task)
+	}
+
+	return resp, nil
+}
+
+// ReportCorruptChunk drops a replica that a storage node's scrubber found to
+// be corrupt. The repair loop then re-replicates the chunk from a healthy copy.
+func (s *server) ReportCorruptChunk(ctx context.Context, req *pb.ReportCorruptChunkRequest) (*pb.ReportCorruptChunkResponse, error) {
+	// Only the leader accepts writes
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	// Find the live file the chunk belongs to, in any of its versions
+	s.mu.Lock()
+	var fileName string
+	for _, fileMeta := range s.allFiles() {
+		for _, chunk := range fileMeta.Chunks {
+			if chunk.ChunkID == req.ChunkId && contains(chunk.Replicas, req.Address) {
+				fileName = fileMeta.FileName
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	// The chunk may belong to a deleted file or have been dropped already
+	if fileName == "" {
+		log.Printf("Ignoring corrupt chunk %s on %s: no file uses that replica", req.ChunkId, req.Address)
+		return &pb.ReportCorruptChunkResponse{Success: true}, nil
+	}
+
+	err := s.commit(ctx, &command{
+		Op:       opDropReplicas,
+		FileName: fileName,
+		ChunkID:  req.ChunkId,
+		Replicas: []string{req.Address},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Dropped corrupt replica of chunk %s on %s", req.ChunkId, req.Address)
+
+	return &pb.ReportCorruptChunkResponse{
+		Success: true,
+	}, nil
+}
@@ -0,0 +1,119 @@
+// metadata/repair_test.go
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	pb "dfs/proto/metadata"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestApplyAddReplicas(t *testing.T) {
+	s := newTestServer()
+	mustApply(t, s,
+		fileCmd("f", "c1"),
+		&command{Op: opAddReplicas, FileName: "f", ChunkID: "c1", Replicas: []string{"node2"}},
+		// The scrubber drops a corrupt replica while a repair is running
+		&command{Op: opDropReplicas, FileName: "f", ChunkID: "c1", Replicas: []string{"node1"}},
+		&command{Op: opAddReplicas, FileName: "f", ChunkID: "c1", Replicas: []string{"node2", "node3"}},
+	)
+
+	got := s.files["f"].Chunks[0].Replicas
+	if want := []string{"node2", "node3"}; !equalStrings(got, want) {
+		t.Errorf("replicas = %v, want %v", got, want)
+	}
+}
+
+func TestRepairChunk(t *testing.T) {
+	refused := status.Error(codes.Internal, "disk full")
+	badSource := func(bad string) func(string) error {
+		return func(source string) error {
+			if source == bad {
+				return status.Errorf(codes.FailedPrecondition, "Failed to fetch chunk from %s", source)
+			}
+			return nil
+		}
+	}
+
+	tests := []struct {
+		name      string
+		live      []string                      // Replicas of the chunk
+		replicate map[string]func(string) error // Behaviour of each target
+		want      []string
+		failed    int64
+	}{
+		{
+			name: "first target",
+			live: []string{"node1"},
+			want: []string{"node1", "node4"},
+		},
+		{
+			name:      "failed target is replaced",
+			live:      []string{"node1"},
+			replicate: map[string]func(string) error{"node4": func(string) error { return refused }},
+			want:      []string{"node1", "node3"},
+		},
+		{
+			name:      "failed source is skipped",
+			live:      []string{"node1", "node2"},
+			replicate: map[string]func(string) error{"node4": badSource("node1")},
+			want:      []string{"node1", "node2", "node4"},
+		},
+		{
+			name: "every target fails",
+			live: []string{"node1"},
+			replicate: map[string]func(string) error{
+				"node2": func(string) error { return refused },
+				"node3": func(string) error { return refused },
+				"node4": func(string) error { return refused },
+			},
+			want:   []string{"node1"},
+			failed: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// node4 has the most free space, so it is tried first
+			nodes := make(map[string]*fakeStorage)
+			for i := 1; i <= 4; i++ {
+				address := fmt.Sprintf("node%d", i)
+				nodes[address] = &fakeStorage{chunks: map[string]uint32{}, replicate: tt.replicate[address]}
+			}
+			s := newTestLeader(t, nodes)
+			s.repair = newRepairState()
+			for i := 1; i <= 4; i++ {
+				s.nodes.register(fmt.Sprintf("node%d", i), &pb.NodeStats{FreeBytes: int64(i)})
+			}
+
+			checksum := uint32(7)
+			file := &FileMetadata{
+				FileName:          "f",
+				UploadDate:        "2024-01-01 00:00:00",
+				ReplicationFactor: len(tt.live) + 1,
+				Chunks:            []*ChunkInfo{{ChunkID: "c1", Replicas: tt.live, Checksum: &checksum}},
+			}
+			mustApply(t, s, &command{Op: opCreateFile, File: file})
+
+			jobs, _ := s.findUnderReplicated()
+			if len(jobs) != 1 {
+				t.Fatalf("found %d under-replicated chunks, want 1", len(jobs))
+			}
+			s.repairChunk(jobs[0])
+
+			s.mu.Lock()
+			got := s.files["f"].Chunks[0].Replicas
+			s.mu.Unlock()
+			if !equalStrings(got, tt.want) {
+				t.Errorf("replicas = %v, want %v", got, tt.want)
+			}
+			if s.repair.failed != tt.failed {
+				t.Errorf("failed repairs = %d, want %d", s.repair.failed, tt.failed)
+			}
+		})
+	}
+}
@@ -4,13 +4,17 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
 	pb "dfs/proto/metadata"
+	rpb "dfs/proto/raft"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -20,41 +24,428 @@ type server struct {
 	pb.UnimplementedMetadataServiceServer
 	mu        sync.Mutex
 	files     map[string]*FileMetadata
-	storageNs []string
+	uploads   map[string]*pendingUpload    // Uploads not yet committed, by upload ID
+	dirs      map[string]*DirMetadata      // Directories by path; the root is implicit
+	versions  map[string][]*FileMetadata   // Older versions of each file by path, oldest first
+	snapshots map[string]*SnapshotMetadata // Read-only copies of the namespace, by name
+	garbage   map[string][]string          // Chunks of deleted files, by chunk ID, with the replicas still holding them
+	users     map[string]*UserMetadata     // User accounts by name
+	tokens    map[string]*TokenMetadata    // API tokens by the hash of the token
+	nodes     *nodeRegistry
 	chunkSize int64
+	replicas  int // Number of copies kept of every chunk
+	raft      *raftNode
+	repair    *repairState
+
+	// clusterToken authenticates the cluster's own nodes. Callers are only
+	// authenticated when it is set.
+	clusterToken string
 }
 
 // FileMetadata holds metadata for a single file
 type FileMetadata struct {
-	FileName   string
-	FileSize   int64
-	Chunks     []*ChunkInfo
-	UploadDate string
+	FileName          string // Full path of the file
+	FileSize          int64
+	Chunks            []*ChunkInfo
+	UploadDate        string
+	ReplicationFactor int
+	ChunkSize         int64   // Zero for files written before the chunk size was recorded
+	Version           int64   // Zero for files written before versioning, which count as version 1
+	Owner             string  // User the file belongs to; empty for files owned by the administrators
+	Mode              *uint32 // Permission bits; nil for files written before permissions, which get the default
+	ContentMD5        string  // Hex MD5 of the contents; empty if the uploader did not report it
+}
+
+// info summarizes the file for listings
+func (f *FileMetadata) info() *pb.FileInfo {
+	return &pb.FileInfo{
+		FileName:    f.FileName,
+		FileSize:    f.FileSize,
+		NumChunks:   int32(len(f.Chunks)),
+		NumReplicas: int32(f.ReplicationFactor),
+		UploadDate:  f.UploadDate,
+		ChunkSize:   f.ChunkSize,
+		Version:     f.version(),
+		Owner:       f.Owner,
+		Mode:        f.mode(),
+		ContentMd5:  f.ContentMD5,
+	}
+}
+
+// fileInfo summarizes a file for clients. Files from before the chunk size
+// was recorded used the configured one.
+func (s *server) fileInfo(f *FileMetadata) *pb.FileInfo {
+	info := f.info()
+	if info.ChunkSize == 0 {
+		info.ChunkSize = s.chunkSize
+	}
+	return info
 }
 
 // ChunkInfo holds information about a single chunk
 type ChunkInfo struct {
-	ChunkID     string
-	StorageNode string
+	ChunkID  string
+	Replicas []string // Storage nodes holding a copy of the chunk
+	Checksum *uint32  // CRC32C of the chunk data; nil for chunks written before checksums
 }
 
-// NewServer initializes a new Metadata server
-func NewServer(storageNodes []string, chunkSize int64) *server {
-	return &server{
+// toProto converts the chunk to its protobuf representation
+func (c *ChunkInfo) toProto() *pb.ChunkInfo {
+	return &pb.ChunkInfo{
+		ChunkId:  c.ChunkID,
+		Replicas: append([]string(nil), c.Replicas...),
+		Checksum: c.Checksum,
+	}
+}
+
+// NewServer initializes a new Metadata server, restoring the namespace
+// persisted in dataDir. Chunks are placed on the live storage nodes in nodes.
+// id is the address this node is reachable at and peers lists the addresses
+// of the other members of the metadata cluster. A non-empty clusterToken
+// enables authentication and is sent to the other metadata nodes.
+func NewServer(nodes *nodeRegistry, chunkSize int64, replicationFactor int, dataDir, id string, peers []string, clusterToken string) *server {
+	st, snapData, err := openStore(dataDir)
+	if err != nil {
+		log.Fatalf("Failed to open metadata store: %v", err)
+	}
+
+	s := &server{
 		files:     make(map[string]*FileMetadata),
-		storageNs: storageNodes,
+		uploads:   make(map[string]*pendingUpload),
+		dirs:      make(map[string]*DirMetadata),
+		versions:  make(map[string][]*FileMetadata),
+		snapshots: make(map[string]*SnapshotMetadata),
+		garbage:   make(map[string][]string),
+		users:     make(map[string]*UserMetadata),
+		tokens:    make(map[string]*TokenMetadata),
+		nodes:     nodes,
 		chunkSize: chunkSize,
+		replicas:  replicationFactor,
+		repair:    newRepairState(),
+
+		clusterToken: clusterToken,
+	}
+	if err := s.restoreState(snapData); err != nil {
+		log.Fatalf("Failed to restore metadata snapshot: %v", err)
+	}
+
+	// Log entries after the snapshot are re-applied once Raft commits them
+	s.raft = newRaftNode(id, peers, st, s, clusterDialOptions(clusterToken))
+	s.raft.start()
+
+	log.Printf("Restored %d files from %s (snapshot index %d, %d log entries pending replay)",
+		len(s.files), dataDir, st.snapIndex, len(st.entries))
+
+	return s
+}
+
+// RaftService returns the Raft endpoint to register alongside the metadata
+// service
+func (s *server) RaftService() rpb.RaftServiceServer {
+	return s.raft
+}
+
+// commit replicates a command through Raft and waits for it to be applied.
+// The returned error is always a gRPC status. The caller must not hold s.mu.
+func (s *server) commit(ctx context.Context, cmd *command) error {
+	err := s.raft.propose(ctx, cmd)
+	if err == nil {
+		return nil
 	}
+
+	var notLeader *notLeaderError
+	if errors.As(err, &notLeader) {
+		return notLeaderStatus(notLeader)
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Errorf(codes.Internal, "Failed to commit %s: %v", cmd.Op, err)
 }
 
-// AllocateChunks allocates chunks for a new file
-func (s *server) AllocateChunks(ctx context.Context, req *pb.CreateFileRequest) (*pb.AllocateChunksResponse, error) {
+// checkLeader fails with Unavailable unless this node is the Raft leader, so
+// clients can redirect writes before doing any work
+func (s *server) checkLeader() error {
+	leaderID, isLeader, _ := s.raft.status()
+	if !isLeader {
+		return notLeaderStatus(&notLeaderError{leader: leaderID})
+	}
+	return nil
+}
+
+// errorDomain and notLeaderReason identify the ErrorInfo of calls that were
+// rejected because this node is not the leader. The client library looks for
+// the same values.
+const (
+	errorDomain     = "dfs"
+	notLeaderReason = "NOT_LEADER"
+)
+
+// notLeaderStatus converts a notLeaderError into an Unavailable status. Unless
+// the command may still be applied, the status carries an ErrorInfo with
+// reason notLeaderReason, telling clients that the call can safely be sent
+// again to the new leader.
+func notLeaderStatus(err *notLeaderError) error {
+	st := status.New(codes.Unavailable, err.Error())
+	if err.pending {
+		return st.Err()
+	}
+	tagged, detailErr := st.WithDetails(&errdetails.ErrorInfo{Reason: notLeaderReason, Domain: errorDomain})
+	if detailErr != nil {
+		return st.Err()
+	}
+	return tagged.Err()
+}
+
+// applyCommand applies a committed command to the in-memory state
+func (s *server) applyCommand(cmd *command) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.apply(cmd)
+}
+
+// apply applies a committed command to the in-memory state. Every node
+// applies the same commands in the same order, so apply must be
+// deterministic. The caller must hold s.mu.
+func (s *server) apply(cmd *command) error {
+	switch cmd.Op {
+	case opNoop:
+	case opCreateFile:
+		if err := s.checkCreate(cmd.File.FileName); err != nil {
+			return err
+		}
+		s.mkdirAll(parentDir(cmd.File.FileName), cmd.File.UploadDate, cmd.File.Owner)
+		s.files[cmd.File.FileName] = cmd.File
+	case opCreateUpload:
+		s.uploads[cmd.Upload.UploadID] = cmd.Upload
+	case opAddChunk:
+		upload, exists := s.uploads[cmd.UploadID]
+		if !exists {
+			return status.Errorf(codes.NotFound, "Upload %s not found", cmd.UploadID)
+		}
+		// A retried allocation keeps the chunk committed first
+		allocated := int64(len(upload.File.Chunks))
+		if cmd.Index < allocated {
+			return nil
+		}
+		if cmd.Index > allocated {
+			return status.Errorf(codes.FailedPrecondition, "Expected chunk %d of upload %s, got %d", allocated, cmd.UploadID, cmd.Index)
+		}
+		upload.File.Chunks = append(upload.File.Chunks, cmd.Chunk)
+	case opCommitUpload:
+		upload, exists := s.uploads[cmd.UploadID]
+		if !exists {
+			return status.Errorf(codes.NotFound, "Upload %s not found", cmd.UploadID)
+		}
+		if err := s.checkWrite(upload.File.FileName); err != nil {
+			return err
+		}
+		if upload.File.FileSize == unknownSize {
+			upload.File.FileSize = cmd.Size
+		}
+		upload.File.ContentMD5 = cmd.MD5
+		s.mkdirAll(parentDir(upload.File.FileName), upload.File.UploadDate, upload.File.Owner)
+		s.addVersion(upload.File)
+		delete(s.uploads, cmd.UploadID)
+	case opRenewUpload:
+		upload, exists := s.uploads[cmd.UploadID]
+		if !exists {
+			return status.Errorf(codes.NotFound, "Upload %s not found", cmd.UploadID)
+		}
+		upload.Active = *cmd.Time
+	case opAbortUpload:
+		upload, exists := s.uploads[cmd.UploadID]
+		if !exists {
+			return status.Errorf(codes.NotFound, "Upload %s not found", cmd.UploadID)
+		}
+		delete(s.uploads, cmd.UploadID)
+		s.discardChunks(upload.File.Chunks)
+	case opAddReplicas:
+		chunks := s.findChunk(cmd.ChunkID)
+		if len(chunks) == 0 {
+			return status.Errorf(codes.NotFound, "Chunk %s of file %s not found", cmd.ChunkID, cmd.FileName)
+		}
+		for _, chunk := range chunks {
+			for _, replica := range cmd.Replicas {
+				if !contains(chunk.Replicas, replica) {
+					chunk.Replicas = append(chunk.Replicas, replica)
+				}
+			}
+		}
+	case opDropReplicas:
+		chunks := s.findChunk(cmd.ChunkID)
+		if len(chunks) == 0 {
+			return status.Errorf(codes.NotFound, "Chunk %s of file %s not found", cmd.ChunkID, cmd.FileName)
+		}
+		for _, chunk := range chunks {
+			var kept []string
+			for _, replica := range chunk.Replicas {
+				if !contains(cmd.Replicas, replica) {
+					kept = append(kept, replica)
+				}
+			}
+			chunk.Replicas = kept
+		}
+	case opDeleteFile:
+		if _, exists := s.files[cmd.FileName]; !exists {
+			return status.Errorf(codes.NotFound, "File %s not found", cmd.FileName)
+		}
+		s.removeFile(cmd.FileName)
+	case opMkdir:
+		return s.applyMkdir(cmd.FileName, *cmd.Time, cmd.Recursive, cmd.Owner)
+	case opRename:
+		return s.applyRename(cmd.FileName, cmd.Dest, cmd.Overwrite)
+	case opDeleteDir:
+		return s.applyDeleteDir(cmd.FileName, cmd.Recursive)
+	case opPruneVersions:
+		s.applyPruneVersions(cmd.FileName, cmd.Versions)
+	case opCreateSnapshot:
+		return s.applyCreateSnapshot(cmd.Snapshot, cmd.FileName, *cmd.Time, cmd.Owner)
+	case opDeleteSnapshot:
+		return s.applyDeleteSnapshot(cmd.Snapshot)
+	case opForgetChunks:
+		for _, chunkID := range cmd.ChunkIDs {
+			delete(s.garbage, chunkID)
+		}
+	case opSetPermissions:
+		return s.applySetPermissions(cmd.FileName, cmd.Mode, cmd.Owner)
+	case opCreateUser:
+		return s.applyCreateUser(cmd.User)
+	case opDeleteUser:
+		return s.applyDeleteUser(cmd.Name)
+	case opCreateToken:
+		return s.applyCreateToken(cmd.Token)
+	case opRevokeToken:
+		return s.applyRevokeToken(cmd.Name)
+	default:
+		log.Printf("Ignoring unknown command %q", cmd.Op)
+	}
+	return nil
+}
+
+// findChunk returns every copy of the chunk with chunkID held by a file, an
+// older version or a snapshot. Snapshots share chunks with live files, so a
+// change to the replicas of a chunk applies to all of its copies. The caller
+// must hold s.mu.
+func (s *server) findChunk(chunkID string) []*ChunkInfo {
+	var chunks []*ChunkInfo
+	for _, fileMeta := range s.allFiles() {
+		for _, chunk := range fileMeta.Chunks {
+			if chunk.ChunkID == chunkID {
+				chunks = append(chunks, chunk)
+			}
+		}
+	}
+	return chunks
+}
+
+// contains reports whether list includes value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotState encodes the namespace as a snapshot ending at index and term
+func (s *server) snapshotState(index, term uint64) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(&snapshot{
+		Index:     index,
+		Term:      term,
+		Files:     s.files,
+		Uploads:   s.uploads,
+		Dirs:      s.dirs,
+		Versions:  s.versions,
+		Snapshots: s.snapshots,
+		Garbage:   s.garbage,
+		Users:     s.users,
+		Tokens:    s.tokens,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot: %v", err)
+	}
+	return data, nil
+}
+
+// restoreState replaces the namespace with an encoded snapshot
+func (s *server) restoreState(data []byte) error {
+	snap, err := decodeSnapshot(data)
+	if err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.files = snap.Files
+	s.uploads = snap.Uploads
+	s.dirs = snap.Dirs
+	s.versions = snap.Versions
+	s.snapshots = snap.Snapshots
+	s.garbage = snap.Garbage
+	s.users = snap.Users
+	s.tokens = snap.Tokens
+	return nil
+}
+
+// Snapshot writes a snapshot of the namespace and compacts the log
+func (s *server) Snapshot() error {
+	return s.raft.snapshot()
+}
+
+// RunSnapshots periodically snapshots the namespace until stop is closed
+func (s *server) RunSnapshots(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Snapshot(); err != nil {
+				log.Printf("Failed to snapshot metadata: %v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Close writes a final snapshot and stops the Raft node
+func (s *server) Close() error {
+	if err := s.Snapshot(); err != nil {
+		log.Printf("Failed to snapshot metadata: %v", err)
+	}
+	return s.raft.close()
+}
 
-	// Check if file already exists
-	if _, exists := s.files[req.FileName]; exists {
-		return nil, status.Errorf(codes.AlreadyExists, "File %s already exists", req.FileName)
+// AllocateChunks allocates chunks for a new file and starts an upload. The
+// file only appears in the namespace once CommitFile is called.
+func (s *server) AllocateChunks(ctx context.Context, req *pb.CreateFileRequest) (*pb.AllocateChunksResponse, error) {
+	// Only the leader accepts writes
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	fileName, err := cleanPath(req.FileName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check that nothing is in the way of the file and that the caller may
+	// write it
+	id := caller(ctx)
+	s.mu.Lock()
+	err = s.checkWrite(fileName)
+	if err == nil {
+		err = s.authorizeWrite(id, fileName)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate chunk size
@@ -69,85 +460,198 @@ func (s *server) AllocateChunks(ctx context.Context, req *pb.CreateFileRequest)
 		return nil, status.Errorf(codes.InvalidArgument, "File size too small or chunk size invalid")
 	}
 
-	// Assign storage nodes in a round-robin fashion
+	// The client must split the file the same way the server does
+	if req.ChunkSize != 0 && req.ChunkSize != chunkSize {
+		return nil, status.Errorf(codes.FailedPrecondition, "Chunk size %d does not match the server's %d", req.ChunkSize, chunkSize)
+	}
+
+	// The client checksums every chunk so storage nodes can verify the data
+	if len(req.ChunkChecksums) != numChunks {
+		return nil, status.Errorf(codes.InvalidArgument, "Expected %d chunk checksums, got %d", numChunks, len(req.ChunkChecksums))
+	}
+
+	// Place every replica of a chunk on a different live storage node
+	placements, err := s.nodes.place(numChunks, s.replicas, chunkSize)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "Failed to place chunks: %v", err)
+	}
+
+	uploadID, err := newUploadID()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to create upload ID: %v", err)
+	}
+
 	chunks := make([]*ChunkInfo, numChunks)
 	pbChunks := make([]*pb.ChunkInfo, numChunks)
 
 	currentTime := time.Now().Format("2006-01-02 15:04:05")
 
 	for i := 0; i < numChunks; i++ {
-		chunkID := fmt.Sprintf("%s_%d", req.FileName, i)
-		storageNode := s.storageNs[i%len(s.storageNs)] // Round-robin assignment
+		chunkID, err := newChunkID()
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to create chunk ID: %v", err)
+		}
 
+		checksum := req.ChunkChecksums[i]
 		chunkInfo := &ChunkInfo{
-			ChunkID:     chunkID,
-			StorageNode: storageNode,
+			ChunkID:  chunkID,
+			Replicas: placements[i],
+			Checksum: &checksum,
 		}
 		chunks[i] = chunkInfo
-
-		pbChunkInfo := &pb.ChunkInfo{
-			ChunkId:     chunkID,
-			StorageNode: storageNode,
-		}
-		pbChunks[i] = pbChunkInfo
+		pbChunks[i] = chunkInfo.toProto()
 	}
 
-	// Store metadata
-	fileMeta := &FileMetadata{
-		FileName:   req.FileName,
-		FileSize:   req.FileSize,
-		Chunks:     chunks,
-		UploadDate: currentTime,
+	// Record the pending upload through Raft before handing out the
+	// allocation. The file stays hidden until the client commits it.
+	mode := defaultFileMode
+	upload := &pendingUpload{
+		UploadID: uploadID,
+		File: &FileMetadata{
+			FileName:          fileName,
+			FileSize:          req.FileSize,
+			Chunks:            chunks,
+			UploadDate:        currentTime,
+			ReplicationFactor: s.replicas,
+			ChunkSize:         chunkSize,
+			Owner:             ownerName(id),
+			Mode:              &mode,
+		},
+		Active: time.Now(),
+	}
+	if err := s.commit(ctx, &command{Op: opCreateUpload, Upload: upload}); err != nil {
+		return nil, err
 	}
-	s.files[req.FileName] = fileMeta
 
-	log.Printf("Allocated %d chunks for file %s", numChunks, req.FileName)
+	log.Printf("Allocated %d chunks with %d replicas each for file %s (upload %s)", numChunks, s.replicas, fileName, uploadID)
 
 	return &pb.AllocateChunksResponse{
-		Chunks: pbChunks,
+		Chunks:   pbChunks,
+		UploadId: uploadID,
 	}, nil
 }
 
-// GetFileInfo retrieves metadata for a specified file
+// GetFileInfo retrieves metadata for a specified file, or for one of its
+// versions, as it is now or as of a snapshot
 func (s *server) GetFileInfo(ctx context.Context, req *pb.GetFileRequest) (*pb.GetFileResponse, error) {
+	fileName, err := cleanPath(req.FileName)
+	if err != nil {
+		return nil, err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	fileMeta, exists := s.files[req.FileName]
+	t, err := s.tree(req.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	fileMeta, exists := t.files[fileName]
 	if !exists {
-		return nil, status.Errorf(codes.NotFound, "File %s not found", req.FileName)
+		if t.isDir(fileName) {
+			return nil, status.Errorf(codes.FailedPrecondition, "%s is a directory", fileName)
+		}
+		return nil, status.Errorf(codes.NotFound, "File %s not found", fileName)
+	}
+	if err := authorize(caller(ctx), t, fileName, permRead); err != nil {
+		return nil, err
+	}
+	if req.Version != 0 && req.Version != fileMeta.version() {
+		// Snapshots only hold the version that was current when taken
+		fileMeta = nil
+		if req.Snapshot == "" {
+			fileMeta = s.findVersion(fileName, req.Version)
+		}
+		if fileMeta == nil {
+			return nil, status.Errorf(codes.NotFound, "Version %d of file %s not found", req.Version, fileName)
+		}
 	}
 
 	pbChunks := make([]*pb.ChunkInfo, len(fileMeta.Chunks))
 	for i, chunk := range fileMeta.Chunks {
-		pbChunks[i] = &pb.ChunkInfo{
-			ChunkId:     chunk.ChunkID,
-			StorageNode: chunk.StorageNode,
-		}
+		pbChunks[i] = chunk.toProto()
 	}
 
 	return &pb.GetFileResponse{
 		Chunks: pbChunks,
+		Info:   s.fileInfo(fileMeta),
 	}, nil
 }
 
-// ListFiles retrieves the list of all files with metadata
+// DeleteFile removes a file or a directory from the namespace. A directory
+// must be empty unless the request is recursive. Chunks are deleted from the
+// storage nodes asynchronously by the garbage collector.
+func (s *server) DeleteFile(ctx context.Context, req *pb.DeleteFileRequest) (*pb.DeleteFileResponse, error) {
+	// Only the leader accepts writes
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	fileName, err := cleanPath(req.FileName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Deleting a directory with everything in it needs permission to empty
+	// every directory below it
+	id := caller(ctx)
+	s.mu.Lock()
+	isDir := s.isDir(fileName)
+	if isDir && req.Recursive {
+		err = s.authorizeRemoveTree(id, fileName)
+	} else {
+		err = s.authorizeRemove(id, fileName)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := &command{Op: opDeleteFile, FileName: fileName}
+	if isDir {
+		cmd = &command{Op: opDeleteDir, FileName: fileName, Recursive: req.Recursive}
+	}
+	if err := s.commit(ctx, cmd); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Deleted %s", fileName)
+
+	return &pb.DeleteFileResponse{
+		Success: true,
+	}, nil
+}
+
+// ListFiles retrieves the list of all files the caller may read, with
+// metadata
 func (s *server) ListFiles(ctx context.Context, req *pb.ListFilesRequest) (*pb.ListFilesResponse, error) {
+	id := caller(ctx)
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	t := s.live()
 	var files []*pb.FileInfo
-	for _, fileMeta := range s.files {
-		files = append(files, &pb.FileInfo{
-			FileName:    fileMeta.FileName,
-			FileSize:    fileMeta.FileSize,
-			NumChunks:   int32(len(fileMeta.Chunks)),
-			NumReplicas: 1, // Adjust based on actual replication logic
-			UploadDate:  fileMeta.UploadDate,
-		})
+	for p, fileMeta := range s.files {
+		if authorize(id, t, p, permRead) == nil {
+			files = append(files, s.fileInfo(fileMeta))
+		}
 	}
 
 	return &pb.ListFilesResponse{
 		Files: files,
 	}, nil
 }
+
+// GetLeader reports the current Raft leader so clients can find the node that
+// accepts writes
+func (s *server) GetLeader(ctx context.Context, req *pb.GetLeaderRequest) (*pb.GetLeaderResponse, error) {
+	leaderID, isLeader, term := s.raft.status()
+	return &pb.GetLeaderResponse{
+		LeaderAddress: leaderID,
+		IsLeader:      isLeader,
+		Term:          term,
+		ChunkSize:     s.chunkSize,
+	}, nil
+}
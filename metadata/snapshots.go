@@ -0,0 +1,233 @@
+// metadata/snapshots.go
+
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	pb "dfs/proto/metadata"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SnapshotMetadata holds a read-only copy of the namespace below a directory
+// as it was when the snapshot was taken. Chunks are never modified once
+// written, so the copied files share their chunks with the live files instead
+// of duplicating the data; a chunk is only deleted once neither a live file
+// nor a snapshot uses it.
+type SnapshotMetadata struct {
+	Name    string
+	Path    string // Directory the snapshot was taken of; empty for the whole namespace
+	Created string
+	Owner   string                   // User who took the snapshot
+	Files   map[string]*FileMetadata // Files below Path as they were, by full path
+	Dirs    map[string]*DirMetadata  // Directories below Path and the ones leading to it, by full path
+}
+
+// info summarizes the snapshot for listings
+func (snap *SnapshotMetadata) info() *pb.SnapshotInfo {
+	info := &pb.SnapshotInfo{
+		Name:     snap.Name,
+		Path:     snap.Path,
+		Created:  snap.Created,
+		NumFiles: int64(len(snap.Files)),
+	}
+	for _, fileMeta := range snap.Files {
+		info.TotalSize += fileMeta.FileSize
+	}
+	return info
+}
+
+// copyFile returns a copy of fileMeta that later changes to the live file do
+// not affect
+func copyFile(fileMeta *FileMetadata) *FileMetadata {
+	fileCopy := *fileMeta
+	fileCopy.Chunks = make([]*ChunkInfo, len(fileMeta.Chunks))
+	for i, chunk := range fileMeta.Chunks {
+		chunkCopy := *chunk
+		chunkCopy.Replicas = append([]string(nil), chunk.Replicas...)
+		fileCopy.Chunks[i] = &chunkCopy
+	}
+	return &fileCopy
+}
+
+// validateSnapshotName rejects names that cannot be used in paths and URLs
+func validateSnapshotName(name string) error {
+	if name == "" {
+		return status.Errorf(codes.InvalidArgument, "Snapshot name is required")
+	}
+	if len(name) > 255 || strings.ContainsAny(name, "/\x00") {
+		return status.Errorf(codes.InvalidArgument, "Invalid snapshot name %q", name)
+	}
+	return nil
+}
+
+// applyCreateSnapshot freezes the current version of every file and directory
+// below p as the snapshot name, taken by owner. The caller must hold s.mu.
+func (s *server) applyCreateSnapshot(name, p string, created time.Time, owner string) error {
+	if _, exists := s.snapshots[name]; exists {
+		return status.Errorf(codes.AlreadyExists, "Snapshot %s already exists", name)
+	}
+	if !s.isDir(p) {
+		if _, exists := s.files[p]; exists {
+			return status.Errorf(codes.FailedPrecondition, "%s is not a directory", p)
+		}
+		return status.Errorf(codes.NotFound, "Directory %s not found", p)
+	}
+
+	snap := &SnapshotMetadata{
+		Name:    name,
+		Path:    p,
+		Created: created.Format("2006-01-02 15:04:05"),
+		Owner:   owner,
+		Files:   make(map[string]*FileMetadata),
+		Dirs:    make(map[string]*DirMetadata),
+	}
+
+	// Keep the path to the snapshotted directory so it can be browsed from
+	// the root
+	for dir := p; dir != ""; dir = parentDir(dir) {
+		dirCopy := *s.dirs[dir]
+		snap.Dirs[dir] = &dirCopy
+	}
+	for dirPath, dirMeta := range s.dirs {
+		if isUnder(dirPath, p) {
+			dirCopy := *dirMeta
+			snap.Dirs[dirPath] = &dirCopy
+		}
+	}
+	for filePath, fileMeta := range s.files {
+		if isUnder(filePath, p) {
+			snap.Files[filePath] = copyFile(fileMeta)
+		}
+	}
+
+	s.snapshots[name] = snap
+	return nil
+}
+
+// applyDeleteSnapshot removes a snapshot and queues its chunks for garbage
+// collection. The garbage collector keeps the chunks that live files or other
+// snapshots still use. The caller must hold s.mu.
+func (s *server) applyDeleteSnapshot(name string) error {
+	snap, exists := s.snapshots[name]
+	if !exists {
+		return status.Errorf(codes.NotFound, "Snapshot %s not found", name)
+	}
+	delete(s.snapshots, name)
+	for _, fileMeta := range snap.Files {
+		s.discardChunks(fileMeta.Chunks)
+	}
+	return nil
+}
+
+// CreateSnapshot takes a read-only snapshot of the namespace below a
+// directory. Only metadata is copied; the snapshot shares chunks with the
+// live files.
+func (s *server) CreateSnapshot(ctx context.Context, req *pb.CreateSnapshotRequest) (*pb.CreateSnapshotResponse, error) {
+	// Only the leader accepts writes
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	if err := validateSnapshotName(req.Name); err != nil {
+		return nil, err
+	}
+	dirPath, err := cleanPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the owner of a directory may snapshot it; the files in the
+	// snapshot keep their permissions
+	id := caller(ctx)
+	s.mu.Lock()
+	err = authorizeOwner(id, s.live(), dirPath)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = s.commit(ctx, &command{
+		Op:       opCreateSnapshot,
+		FileName: dirPath,
+		Snapshot: req.Name,
+		Time:     &now,
+		Owner:    ownerName(id),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap, exists := s.snapshots[req.Name]
+	if !exists {
+		return nil, status.Errorf(codes.Aborted, "Snapshot %s was deleted while it was being created", req.Name)
+	}
+
+	log.Printf("Created snapshot %s of /%s with %d files", req.Name, dirPath, len(snap.Files))
+
+	return &pb.CreateSnapshotResponse{
+		Snapshot: snap.info(),
+	}, nil
+}
+
+// ListSnapshots lists every snapshot, sorted by name
+func (s *server) ListSnapshots(ctx context.Context, req *pb.ListSnapshotsRequest) (*pb.ListSnapshotsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshots := make([]*pb.SnapshotInfo, 0, len(s.snapshots))
+	for _, snap := range s.snapshots {
+		snapshots = append(snapshots, snap.info())
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name < snapshots[j].Name })
+
+	return &pb.ListSnapshotsResponse{
+		Snapshots: snapshots,
+	}, nil
+}
+
+// DeleteSnapshot removes a snapshot. Chunks that only the snapshot used are
+// deleted from the storage nodes asynchronously by the garbage collector.
+func (s *server) DeleteSnapshot(ctx context.Context, req *pb.DeleteSnapshotRequest) (*pb.DeleteSnapshotResponse, error) {
+	// Only the leader accepts writes
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	if err := validateSnapshotName(req.Name); err != nil {
+		return nil, err
+	}
+
+	// Only the user who took a snapshot may delete it
+	id := caller(ctx)
+	s.mu.Lock()
+	snap, exists := s.snapshots[req.Name]
+	s.mu.Unlock()
+	if exists && id != nil && !id.Admin && snap.Owner != id.User {
+		return nil, status.Errorf(codes.PermissionDenied, "Snapshot %s belongs to another user", req.Name)
+	}
+
+	err := s.commit(ctx, &command{
+		Op:       opDeleteSnapshot,
+		Snapshot: req.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Deleted snapshot %s", req.Name)
+
+	return &pb.DeleteSnapshotResponse{
+		Success: true,
+	}, nil
+}
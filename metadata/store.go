@@ -0,0 +1,472 @@
+// metadata/store.go
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	walFileName       = "wal.log"
+	snapshotFileName  = "snapshot.json"
+	hardStateFileName = "state.json"
+)
+
+// command is a single mutation of the metadata namespace. Every change to the
+// server state is expressed as a command so it can be written to the
+// replicated log and replayed after a restart.
+type command struct {
+	Op        string         `json:"op"`
+	File      *FileMetadata  `json:"file,omitempty"`
+	Upload    *pendingUpload `json:"upload,omitempty"`
+	UploadID  string         `json:"upload_id,omitempty"`
+	Chunk     *ChunkInfo     `json:"chunk,omitempty"`
+	Index     int64          `json:"index,omitempty"` // Position of Chunk in its upload
+	Size      int64          `json:"size,omitempty"`
+	MD5       string         `json:"md5,omitempty"`
+	Time      *time.Time     `json:"time,omitempty"`
+	FileName  string         `json:"file_name,omitempty"`
+	Dest      string         `json:"dest,omitempty"`
+	Recursive bool           `json:"recursive,omitempty"`
+	Overwrite bool           `json:"overwrite,omitempty"`
+	ChunkID   string         `json:"chunk_id,omitempty"`
+	ChunkIDs  []string       `json:"chunk_ids,omitempty"`
+	Versions  []int64        `json:"versions,omitempty"`
+	Snapshot  string         `json:"snapshot,omitempty"`
+	Replicas  []string       `json:"replicas,omitempty"`
+	Owner     string         `json:"owner,omitempty"`
+	Mode      *uint32        `json:"mode,omitempty"`
+	Name      string         `json:"name,omitempty"` // User name or token ID
+	User      *UserMetadata  `json:"user,omitempty"`
+	Token     *TokenMetadata `json:"token,omitempty"`
+}
+
+// Supported command operations
+const (
+	opNoop           = "noop" // Appended by a new leader to commit earlier entries
+	opCreateFile     = "create_file"
+	opCreateUpload   = "create_upload"   // Start an upload whose file is hidden until committed
+	opAddChunk       = "add_chunk"       // Append a chunk to an upload of unknown size
+	opCommitUpload   = "commit_upload"   // Publish the file of a finished upload
+	opRenewUpload    = "renew_upload"    // Postpone the expiry of an upload that is being resumed
+	opAbortUpload    = "abort_upload"    // Drop an upload and queue its chunks for garbage collection
+	opAddReplicas    = "add_replicas"    // Add replicas to the replica list of one chunk
+	opDropReplicas   = "drop_replicas"   // Remove replicas from the replica list of one chunk
+	opDeleteFile     = "delete_file"     // Remove a file with all its versions and queue their chunks for garbage collection
+	opMkdir          = "mkdir"           // Create a directory, and with Recursive its missing parents
+	opRename         = "rename"          // Move a file or a directory tree from FileName to Dest, with Overwrite replacing what is there
+	opDeleteDir      = "delete_dir"      // Remove a directory, and with Recursive everything below it
+	opPruneVersions  = "prune_versions"  // Drop old versions of a file and queue their chunks for garbage collection
+	opCreateSnapshot = "create_snapshot" // Freeze the namespace below FileName as Snapshot
+	opDeleteSnapshot = "delete_snapshot" // Drop a snapshot and queue the chunks only it uses for garbage collection
+	opForgetChunks   = "forget_chunks"   // Drop garbage chunks deleted from every replica
+	opSetPermissions = "set_permissions" // Change the Mode and, if set, the Owner of FileName
+	opCreateUser     = "create_user"
+	opDeleteUser     = "delete_user" // Remove the user called Name along with their tokens
+	opCreateToken    = "create_token"
+	opRevokeToken    = "revoke_token" // Remove the token whose ID is Name
+)
+
+// walEntry is a single record in the write-ahead log
+type walEntry struct {
+	Index   uint64   `json:"index"`
+	Term    uint64   `json:"term"`
+	Command *command `json:"command"`
+}
+
+// snapshot is a point-in-time copy of the namespace. Index and Term identify
+// the last log entry included in the snapshot.
+type snapshot struct {
+	Index     uint64                       `json:"index"`
+	Term      uint64                       `json:"term"`
+	Files     map[string]*FileMetadata     `json:"files"`
+	Uploads   map[string]*pendingUpload    `json:"uploads"`
+	Dirs      map[string]*DirMetadata      `json:"dirs"`
+	Versions  map[string][]*FileMetadata   `json:"versions"`
+	Snapshots map[string]*SnapshotMetadata `json:"snapshots"` // Namespace snapshots taken by users, by name
+	Garbage   map[string][]string          `json:"garbage"`
+	Users     map[string]*UserMetadata     `json:"users"`
+	Tokens    map[string]*TokenMetadata    `json:"tokens"` // By the hash of the token
+}
+
+// hardState is the Raft state that must survive restarts
+type hardState struct {
+	Term     uint64 `json:"term"`
+	VotedFor string `json:"voted_for"`
+}
+
+// store persists the replicated log, the latest snapshot and the Raft hard
+// state under a data directory. It is not safe for concurrent use; the Raft
+// node serializes access to it.
+type store struct {
+	dir       string
+	wal       *os.File
+	entries   []*walEntry // Log entries after the snapshot, in index order
+	snapIndex uint64      // Index of the last entry covered by the snapshot
+	snapTerm  uint64      // Term of the last entry covered by the snapshot
+	hard      hardState
+}
+
+// openStore opens (or creates) the store in dir and returns the encoded
+// latest snapshot, or nil if none has been written. The log entries written
+// after the snapshot are available through the store.
+func openStore(dir string) (*store, []byte, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, nil, fmt.Errorf("failed to create data directory: %v", err)
+	}
+
+	// Remove temporary files left behind by an interrupted rewrite
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "*.tmp-*"))
+	for _, path := range leftovers {
+		os.Remove(path)
+	}
+
+	snapData, err := os.ReadFile(filepath.Join(dir, snapshotFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+	snap, err := decodeSnapshot(snapData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hard, err := readHardState(filepath.Join(dir, hardStateFileName))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	walPath := filepath.Join(dir, walFileName)
+	entries, validSize, err := readWAL(walPath, snap.Index)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wal, err := os.OpenFile(walPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open write-ahead log: %v", err)
+	}
+
+	// Drop a torn record left behind by a crash in the middle of an append
+	if err := wal.Truncate(validSize); err != nil {
+		wal.Close()
+		return nil, nil, fmt.Errorf("failed to truncate write-ahead log: %v", err)
+	}
+	if _, err := wal.Seek(validSize, 0); err != nil {
+		wal.Close()
+		return nil, nil, fmt.Errorf("failed to seek write-ahead log: %v", err)
+	}
+
+	st := &store{
+		dir:       dir,
+		wal:       wal,
+		entries:   entries,
+		snapIndex: snap.Index,
+		snapTerm:  snap.Term,
+		hard:      *hard,
+	}
+
+	return st, snapData, nil
+}
+
+// decodeSnapshot decodes an encoded snapshot. Empty data decodes to an empty
+// snapshot.
+func decodeSnapshot(data []byte) (*snapshot, error) {
+	snap := &snapshot{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, snap); err != nil {
+			return nil, fmt.Errorf("failed to decode snapshot: %v", err)
+		}
+	}
+	if snap.Files == nil {
+		snap.Files = make(map[string]*FileMetadata)
+	}
+	if snap.Uploads == nil {
+		snap.Uploads = make(map[string]*pendingUpload)
+	}
+	if snap.Versions == nil {
+		snap.Versions = make(map[string][]*FileMetadata)
+	}
+	if snap.Snapshots == nil {
+		snap.Snapshots = make(map[string]*SnapshotMetadata)
+	}
+	if snap.Dirs == nil {
+		snap.Dirs = make(map[string]*DirMetadata)
+	}
+	if snap.Garbage == nil {
+		snap.Garbage = make(map[string][]string)
+	}
+	if snap.Users == nil {
+		snap.Users = make(map[string]*UserMetadata)
+	}
+	if snap.Tokens == nil {
+		snap.Tokens = make(map[string]*TokenMetadata)
+	}
+	return snap, nil
+}
+
+// readHardState loads the persisted Raft term and vote
+func readHardState(path string) (*hardState, error) {
+	hard := &hardState{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return hard, nil
+		}
+		return nil, fmt.Errorf("failed to read hard state: %v", err)
+	}
+	if err := json.Unmarshal(data, hard); err != nil {
+		return nil, fmt.Errorf("failed to decode hard state: %v", err)
+	}
+
+	return hard, nil
+}
+
+// readWAL reads every complete log entry after snapIndex. It also returns the
+// size of the valid prefix of the log so a partially written trailing record
+// can be discarded.
+func readWAL(path string, snapIndex uint64) ([]*walEntry, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to open write-ahead log: %v", err)
+	}
+	defer file.Close()
+
+	var entries []*walEntry
+	var validSize int64
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			// A record without a trailing newline was never fully written
+			if len(line) > 0 {
+				log.Printf("Discarding incomplete write-ahead log record at offset %d", validSize)
+			}
+			break
+		}
+
+		var entry walEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			log.Printf("Discarding corrupt write-ahead log record at offset %d: %v", validSize, err)
+			break
+		}
+		validSize += int64(len(line))
+
+		// Entries already covered by the snapshot are skipped
+		if entry.Index > snapIndex {
+			entries = append(entries, &entry)
+		}
+	}
+
+	return entries, validSize, nil
+}
+
+// lastIndex returns the index of the last entry in the log
+func (st *store) lastIndex() uint64 {
+	if len(st.entries) == 0 {
+		return st.snapIndex
+	}
+	return st.entries[len(st.entries)-1].Index
+}
+
+// lastTerm returns the term of the last entry in the log
+func (st *store) lastTerm() uint64 {
+	if len(st.entries) == 0 {
+		return st.snapTerm
+	}
+	return st.entries[len(st.entries)-1].Term
+}
+
+// entry returns the entry at index, or nil if it was compacted into the
+// snapshot or has not been written
+func (st *store) entry(index uint64) *walEntry {
+	if index <= st.snapIndex || index > st.lastIndex() {
+		return nil
+	}
+	return st.entries[index-st.snapIndex-1]
+}
+
+// term returns the term of the entry at index. The second result is false if
+// the term is unknown because the entry was compacted or does not exist.
+func (st *store) term(index uint64) (uint64, bool) {
+	if index == st.snapIndex {
+		return st.snapTerm, true
+	}
+	if e := st.entry(index); e != nil {
+		return e.Term, true
+	}
+	return 0, false
+}
+
+// entriesFrom returns up to max entries starting at index
+func (st *store) entriesFrom(index uint64, max int) []*walEntry {
+	if index <= st.snapIndex || index > st.lastIndex() {
+		return nil
+	}
+	start := int(index - st.snapIndex - 1)
+	end := len(st.entries)
+	if end-start > max {
+		end = start + max
+	}
+	return st.entries[start:end]
+}
+
+// append writes entries to the end of the log and syncs it to disk
+func (st *store) append(entries ...*walEntry) error {
+	var data []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode log entry: %v", err)
+		}
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+
+	if _, err := st.wal.Write(data); err != nil {
+		return fmt.Errorf("failed to write log entry: %v", err)
+	}
+	if err := st.wal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync write-ahead log: %v", err)
+	}
+
+	st.entries = append(st.entries, entries...)
+	return nil
+}
+
+// truncateFrom removes every entry at or after index from the log
+func (st *store) truncateFrom(index uint64) error {
+	if index <= st.snapIndex || index > st.lastIndex() {
+		return nil
+	}
+	return st.rewrite(st.entries[:index-st.snapIndex-1])
+}
+
+// saveHardState durably records the current term and vote
+func (st *store) saveHardState(hard hardState) error {
+	data, err := json.Marshal(hard)
+	if err != nil {
+		return fmt.Errorf("failed to encode hard state: %v", err)
+	}
+	if err := writeFileAtomic(st.dir, hardStateFileName, data); err != nil {
+		return err
+	}
+	st.hard = hard
+	return nil
+}
+
+// saveSnapshot atomically replaces the snapshot with data, the encoding of a
+// snapshot ending at index and term, and discards the log entries it covers.
+// Entries after the snapshot are kept only if the log agrees with the
+// snapshot about the term of its last entry.
+func (st *store) saveSnapshot(index, term uint64, data []byte) error {
+	if err := writeFileAtomic(st.dir, snapshotFileName, data); err != nil {
+		return err
+	}
+
+	var remaining []*walEntry
+	if t, ok := st.term(index); ok && t == term && index < st.lastIndex() {
+		remaining = st.entries[index-st.snapIndex:]
+	}
+
+	st.snapIndex = index
+	st.snapTerm = term
+	return st.rewrite(remaining)
+}
+
+// readSnapshot returns the encoded snapshot, or nil if none has been written
+func (st *store) readSnapshot() ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(st.dir, snapshotFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+	return data, nil
+}
+
+// rewrite atomically replaces the log with entries
+func (st *store) rewrite(entries []*walEntry) error {
+	var data []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode log entry: %v", err)
+		}
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+
+	if err := writeFileAtomic(st.dir, walFileName, data); err != nil {
+		return err
+	}
+
+	// Reopen the new log for appending
+	wal, err := os.OpenFile(filepath.Join(st.dir, walFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen write-ahead log: %v", err)
+	}
+	st.wal.Close()
+	st.wal = wal
+	st.entries = append([]*walEntry(nil), entries...)
+
+	return nil
+}
+
+// close closes the underlying log file
+func (st *store) close() error {
+	return st.wal.Close()
+}
+
+// writeFileAtomic writes data to name inside dir through a temporary file and
+// a rename so readers never observe a partially written file
+func writeFileAtomic(dir, name string, data []byte) error {
+	tmp, err := os.CreateTemp(dir, name+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync %s: %v", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close %s: %v", name, err)
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(dir, name)); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename %s into place: %v", name, err)
+	}
+
+	// Sync the directory so the rename itself is durable
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open data directory: %v", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync data directory: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+// metadata/store_test.go
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// walRecord returns the log record of a no-op entry at index in term
+func walRecord(t *testing.T, index, term uint64) string {
+	t.Helper()
+	line, err := json.Marshal(&walEntry{Index: index, Term: term, Command: &command{Op: opNoop}})
+	if err != nil {
+		t.Fatalf("encode entry %d: %v", index, err)
+	}
+	return string(line) + "\n"
+}
+
+// logIndexes returns the indexes of the entries held by st
+func logIndexes(st *store) []uint64 {
+	var indexes []uint64
+	for _, entry := range st.entries {
+		indexes = append(indexes, entry.Index)
+	}
+	return indexes
+}
+
+// equalIndexes reports whether a and b hold the same indexes in the same order
+func equalIndexes(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOpenStoreReplaysWAL(t *testing.T) {
+	tests := []struct {
+		name     string
+		snapshot string      // Snapshot file contents, if any
+		records  [][2]uint64 // Index and term of each complete record
+		tail     string      // Written after the records
+		want     []uint64
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:    "complete",
+			records: [][2]uint64{{1, 1}, {2, 1}, {3, 2}},
+			want:    []uint64{1, 2, 3},
+		},
+		{
+			name:    "torn tail",
+			records: [][2]uint64{{1, 1}, {2, 1}},
+			tail:    `{"index":3,"term":2,"comm`,
+			want:    []uint64{1, 2},
+		},
+		{
+			name:    "corrupt last record",
+			records: [][2]uint64{{1, 1}, {2, 1}},
+			tail:    "{not json}\n",
+			want:    []uint64{1, 2},
+		},
+		{
+			name: "torn first record",
+			tail: `{"index":1`,
+			want: nil,
+		},
+		{
+			name:     "entries covered by the snapshot",
+			snapshot: `{"index":2,"term":1}`,
+			records:  [][2]uint64{{1, 1}, {2, 1}, {3, 2}},
+			tail:     `{"index":4`,
+			want:     []uint64{3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.snapshot != "" {
+				if err := os.WriteFile(filepath.Join(dir, snapshotFileName), []byte(tt.snapshot), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var wal string
+			for _, record := range tt.records {
+				wal += walRecord(t, record[0], record[1])
+			}
+			valid := int64(len(wal))
+			wal += tt.tail
+			walPath := filepath.Join(dir, walFileName)
+			if err := os.WriteFile(walPath, []byte(wal), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			st, _, err := openStore(dir)
+			if err != nil {
+				t.Fatalf("open store: %v", err)
+			}
+			if got := logIndexes(st); !equalIndexes(got, tt.want) {
+				t.Errorf("entries = %v, want %v", got, tt.want)
+			}
+
+			// The invalid tail is cut off so new records follow the valid ones
+			info, err := os.Stat(walPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size() != valid {
+				t.Errorf("log size = %d, want %d", info.Size(), valid)
+			}
+
+			next := st.lastIndex() + 1
+			if err := st.append(&walEntry{Index: next, Term: 3, Command: &command{Op: opNoop}}); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+			st.close()
+
+			st, _, err = openStore(dir)
+			if err != nil {
+				t.Fatalf("reopen store: %v", err)
+			}
+			defer st.close()
+			if want := append(append([]uint64(nil), tt.want...), next); !equalIndexes(logIndexes(st), want) {
+				t.Errorf("entries after reopening = %v, want %v", logIndexes(st), want)
+			}
+		})
+	}
+}
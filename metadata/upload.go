@@ -0,0 +1,417 @@
+// metadata/upload.go
+
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"dfs/auth"
+	pb "dfs/proto/metadata"
+	storagePb "dfs/proto/storage"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	statConcurrency = 16               // Chunks checked at once when verifying an upload
+	statTimeout     = 10 * time.Second // Bound on a single StatChunk call
+)
+
+// pendingUpload is a file whose chunks are still being written. It becomes
+// part of the namespace only when the client commits it; uploads that are
+// never committed expire and their chunks are garbage collected.
+type pendingUpload struct {
+	UploadID string
+	File     *FileMetadata
+	Active   time.Time // When the upload was started or last resumed
+}
+
+// unknownSize is the size of an upload started with CreateUpload until it is
+// committed
+const unknownSize = -1
+
+// newUploadID returns a random upload ID
+func newUploadID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// chunkIDVersion is the format version of the chunk handles newChunkID
+// returns. Storage nodes only accept versions they know how to lay out.
+const chunkIDVersion = 1
+
+// newChunkID returns a new chunk handle: the format version followed by 128
+// random bits in hex, such as "v1-3f2a1b4c...". Handles say nothing about the
+// file a chunk belongs to, so files can be renamed and re-uploaded without
+// ever touching or overwriting stored chunks.
+func newChunkID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("v%d-%x", chunkIDVersion, buf), nil
+}
+
+// authorizeUpload checks that an upload was started by id, so only its owner
+// can add to it, resume it or publish it
+func authorizeUpload(id *auth.Identity, upload *pendingUpload) error {
+	if id != nil && !id.Admin && upload.File.Owner != id.User {
+		return status.Errorf(codes.PermissionDenied, "Upload %s belongs to another user", upload.UploadID)
+	}
+	return nil
+}
+
+// CheckChunkWrite tells a storage node whether a client may store a chunk:
+// the chunk must belong to a pending upload of the client, and the data must
+// have the checksum recorded when the chunk was allocated. Chunks of
+// published files are never written by clients.
+func (s *server) CheckChunkWrite(ctx context.Context, req *pb.CheckChunkWriteRequest) (*pb.CheckChunkWriteResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := &auth.Identity{User: req.User, Admin: req.Admin}
+	for _, upload := range s.uploads {
+		for _, chunk := range upload.File.Chunks {
+			if chunk.ChunkID != req.ChunkId {
+				continue
+			}
+			if err := authorizeUpload(id, upload); err != nil {
+				return nil, err
+			}
+			if chunk.Checksum == nil {
+				return nil, status.Errorf(codes.FailedPrecondition, "Chunk %s has no recorded checksum", req.ChunkId)
+			}
+			return &pb.CheckChunkWriteResponse{Checksum: *chunk.Checksum}, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "Chunk %s does not belong to a pending upload", req.ChunkId)
+}
+
+// CreateUpload starts an upload whose size is not known in advance. Its
+// chunks are allocated one at a time with AllocateChunk as the client fills
+// them, and the file size is given when the upload is committed.
+func (s *server) CreateUpload(ctx context.Context, req *pb.CreateUploadRequest) (*pb.CreateUploadResponse, error) {
+	// Only the leader accepts writes
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	fileName, err := cleanPath(req.FileName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check that nothing is in the way of the file and that the caller may
+	// write it
+	id := caller(ctx)
+	s.mu.Lock()
+	err = s.checkWrite(fileName)
+	if err == nil {
+		err = s.authorizeWrite(id, fileName)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	uploadID, err := newUploadID()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to create upload ID: %v", err)
+	}
+
+	mode := defaultFileMode
+	upload := &pendingUpload{
+		UploadID: uploadID,
+		File: &FileMetadata{
+			FileName:          fileName,
+			FileSize:          unknownSize,
+			UploadDate:        time.Now().Format("2006-01-02 15:04:05"),
+			ReplicationFactor: s.replicas,
+			ChunkSize:         s.chunkSize,
+			Owner:             ownerName(id),
+			Mode:              &mode,
+		},
+		Active: time.Now(),
+	}
+	if err := s.commit(ctx, &command{Op: opCreateUpload, Upload: upload}); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Started upload %s of file %s", uploadID, fileName)
+
+	return &pb.CreateUploadResponse{
+		UploadId:  uploadID,
+		ChunkSize: s.chunkSize,
+	}, nil
+}
+
+// AllocateChunk places the next chunk of an upload started with CreateUpload.
+// Allocating an index that was already allocated returns the existing chunk,
+// so clients can safely retry.
+func (s *server) AllocateChunk(ctx context.Context, req *pb.AllocateChunkRequest) (*pb.AllocateChunkResponse, error) {
+	// Only the leader accepts writes
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	upload, exists := s.uploads[req.UploadId]
+	if !exists {
+		s.mu.Unlock()
+		return nil, status.Errorf(codes.NotFound, "Upload %s not found", req.UploadId)
+	}
+	if err := authorizeUpload(caller(ctx), upload); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if upload.File.FileSize != unknownSize {
+		s.mu.Unlock()
+		return nil, status.Errorf(codes.FailedPrecondition, "Upload %s was allocated up front", req.UploadId)
+	}
+	if req.Index < 0 {
+		s.mu.Unlock()
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid chunk index %d", req.Index)
+	}
+	allocated := len(upload.File.Chunks)
+	if int(req.Index) < allocated {
+		chunk := upload.File.Chunks[req.Index].toProto()
+		s.mu.Unlock()
+		return &pb.AllocateChunkResponse{Chunk: chunk}, nil
+	}
+	chunkSize := upload.File.ChunkSize
+	s.mu.Unlock()
+
+	if int(req.Index) != allocated {
+		return nil, status.Errorf(codes.FailedPrecondition, "Expected chunk %d of upload %s, got %d", allocated, req.UploadId, req.Index)
+	}
+
+	// Place every replica of the chunk on a different live storage node
+	placements, err := s.nodes.place(1, s.replicas, chunkSize)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "Failed to place chunk: %v", err)
+	}
+
+	chunkID, err := newChunkID()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to create chunk ID: %v", err)
+	}
+
+	checksum := req.Checksum
+	chunk := &ChunkInfo{
+		ChunkID:  chunkID,
+		Replicas: placements[0],
+		Checksum: &checksum,
+	}
+	err = s.commit(ctx, &command{Op: opAddChunk, UploadID: req.UploadId, Chunk: chunk, Index: int64(req.Index)})
+	if err != nil {
+		return nil, err
+	}
+
+	// A concurrent retry may have allocated the index first; return whichever
+	// chunk was committed
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	upload, exists = s.uploads[req.UploadId]
+	if !exists || int(req.Index) >= len(upload.File.Chunks) {
+		return nil, status.Errorf(codes.Aborted, "Upload %s changed while chunk %d was allocated", req.UploadId, req.Index)
+	}
+	return &pb.AllocateChunkResponse{
+		Chunk: upload.File.Chunks[req.Index].toProto(),
+	}, nil
+}
+
+// CommitFile publishes the file of an upload once every chunk is stored
+func (s *server) CommitFile(ctx context.Context, req *pb.CommitFileRequest) (*pb.CommitFileResponse, error) {
+	// Only the leader accepts writes
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	upload, exists := s.uploads[req.UploadId]
+	if !exists {
+		s.mu.Unlock()
+		return nil, status.Errorf(codes.NotFound, "Upload %s not found", req.UploadId)
+	}
+
+	// The file may have appeared since the upload started, so check again
+	// that the caller may write it
+	id := caller(ctx)
+	err := authorizeUpload(id, upload)
+	if err == nil {
+		err = s.authorizeWrite(id, upload.File.FileName)
+	}
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+
+	// The size of an upload started with CreateUpload must fit its chunks
+	if upload.File.FileSize == unknownSize {
+		numChunks := int64(len(upload.File.Chunks))
+		chunkSize := upload.File.ChunkSize
+		if req.FileSize < 0 || req.FileSize > numChunks*chunkSize || (numChunks > 0 && req.FileSize <= (numChunks-1)*chunkSize) {
+			s.mu.Unlock()
+			return nil, status.Errorf(codes.InvalidArgument, "File size %d does not match %d chunks", req.FileSize, numChunks)
+		}
+	}
+	chunks := make([]*pb.ChunkInfo, len(upload.File.Chunks))
+	for i, chunk := range upload.File.Chunks {
+		chunks[i] = chunk.toProto()
+	}
+	s.mu.Unlock()
+
+	if req.ContentMd5 != "" {
+		if sum, err := hex.DecodeString(req.ContentMd5); err != nil || len(sum) != md5.Size {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid content MD5 %q", req.ContentMd5)
+		}
+	}
+
+	// Only publish the file once every chunk can be read back. Replicas that
+	// failed after the write are restored by the repair scanner, so one good
+	// copy of each chunk is enough.
+	var missing []int
+	for i, stored := range s.statChunks(ctx, chunks) {
+		if stored == 0 {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "Chunks %v of upload %s are not stored", missing, req.UploadId)
+	}
+
+	if err := s.commit(ctx, &command{Op: opCommitUpload, UploadID: req.UploadId, Size: req.FileSize, MD5: strings.ToLower(req.ContentMd5)}); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Committed upload %s", req.UploadId)
+
+	return &pb.CommitFileResponse{
+		Success: true,
+	}, nil
+}
+
+// GetUploadStatus reports which chunks of an upload are already stored on
+// all their replicas, so an interrupted upload can resume with the rest.
+// Querying an upload also postpones its expiry, since the client is about to
+// resume it.
+func (s *server) GetUploadStatus(ctx context.Context, req *pb.GetUploadStatusRequest) (*pb.GetUploadStatusResponse, error) {
+	// Only the leader accepts writes, including renewing the upload
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	upload, exists := s.uploads[req.UploadId]
+	if !exists {
+		s.mu.Unlock()
+		return nil, status.Errorf(codes.NotFound, "Upload %s not found", req.UploadId)
+	}
+	if err := authorizeUpload(caller(ctx), upload); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	resp := &pb.GetUploadStatusResponse{
+		FileName:  upload.File.FileName,
+		FileSize:  upload.File.FileSize,
+		Chunks:    make([]*pb.ChunkInfo, len(upload.File.Chunks)),
+		Stored:    make([]bool, len(upload.File.Chunks)),
+		ChunkSize: upload.File.ChunkSize,
+	}
+	for i, chunk := range upload.File.Chunks {
+		resp.Chunks[i] = chunk.toProto()
+	}
+	s.mu.Unlock()
+
+	now := time.Now()
+	if err := s.commit(ctx, &command{Op: opRenewUpload, UploadID: req.UploadId, Time: &now}); err != nil {
+		return nil, err
+	}
+
+	// Ask the storage nodes which chunks they hold
+	for i, stored := range s.statChunks(ctx, resp.Chunks) {
+		resp.Stored[i] = stored == len(resp.Chunks[i].Replicas)
+	}
+
+	return resp, nil
+}
+
+// statChunks asks the storage nodes about chunks and returns how many
+// replicas of each hold a copy matching its checksum
+func (s *server) statChunks(ctx context.Context, chunks []*pb.ChunkInfo) []int {
+	stored := make([]int, len(chunks))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, statConcurrency)
+	for i, chunk := range chunks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, chunk *pb.ChunkInfo) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			stored[i] = s.storedReplicas(ctx, chunk)
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	return stored
+}
+
+// storedReplicas returns how many replicas of a chunk hold a copy matching
+// its checksum
+func (s *server) storedReplicas(ctx context.Context, chunk *pb.ChunkInfo) int {
+	stored := 0
+	for _, replica := range chunk.Replicas {
+		client, err := s.nodes.client(replica)
+		if err != nil {
+			continue
+		}
+
+		statCtx, cancel := context.WithTimeout(ctx, statTimeout)
+		resp, err := client.StatChunk(statCtx, &storagePb.StatChunkRequest{
+			ChunkId: chunk.ChunkId,
+		})
+		cancel()
+		if err != nil || !resp.Exists {
+			continue
+		}
+		if chunk.Checksum != nil && (resp.Checksum == nil || *resp.Checksum != *chunk.Checksum) {
+			continue
+		}
+		stored++
+	}
+	return stored
+}
+
+// expireUploads aborts uploads that were not committed or resumed within
+// timeout. Their chunks are queued for garbage collection.
+func (s *server) expireUploads(timeout time.Duration) {
+	s.mu.Lock()
+	var expired []string
+	for uploadID, upload := range s.uploads {
+		if time.Since(upload.Active) > timeout {
+			expired = append(expired, uploadID)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, uploadID := range expired {
+		if err := s.commit(context.Background(), &command{Op: opAbortUpload, UploadID: uploadID}); err != nil {
+			log.Printf("GC: failed to expire upload %s: %v", uploadID, err)
+			continue
+		}
+		log.Printf("GC: expired upload %s after %v of inactivity", uploadID, timeout)
+	}
+}
@@ -0,0 +1,305 @@
+// metadata/upload_test.go
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	pb "dfs/proto/metadata"
+	storagePb "dfs/proto/storage"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeStorage is a storage node holding chunks with the given checksums
+type fakeStorage struct {
+	storagePb.StorageServiceClient
+	chunks    map[string]uint32
+	replicate func(source string) error // Fails copies if set
+}
+
+func (f *fakeStorage) StatChunk(ctx context.Context, in *storagePb.StatChunkRequest, opts ...grpc.CallOption) (*storagePb.StatChunkResponse, error) {
+	checksum, exists := f.chunks[in.ChunkId]
+	if !exists {
+		return &storagePb.StatChunkResponse{}, nil
+	}
+	return &storagePb.StatChunkResponse{Exists: true, Checksum: &checksum}, nil
+}
+
+func (f *fakeStorage) ReplicateChunk(ctx context.Context, in *storagePb.ReplicateChunkRequest, opts ...grpc.CallOption) (*storagePb.ReplicateChunkResponse, error) {
+	if f.replicate != nil {
+		if err := f.replicate(in.Source); err != nil {
+			return nil, err
+		}
+	}
+	f.chunks[in.ChunkId] = *in.Checksum
+	return &storagePb.ReplicateChunkResponse{Success: true}, nil
+}
+
+// newTestLeader returns a server that leads a single-node Raft cluster and
+// reaches the given fake storage nodes
+func newTestLeader(t *testing.T, nodes map[string]*fakeStorage) *server {
+	t.Helper()
+	s := newTestServer()
+	s.nodes = newNodeRegistry(time.Second, 3*time.Second, nil)
+	for address, node := range nodes {
+		s.nodes.clients[address] = node
+	}
+
+	st, _, err := openStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	s.raft = newRaftNode("self", nil, st, s, nil)
+	s.raft.start()
+	t.Cleanup(func() { s.raft.close() })
+
+	waitFor(t, 5*time.Second, "leadership", func() bool {
+		_, isLeader, _ := s.raft.status()
+		return isLeader
+	})
+	return s
+}
+
+// addChunkCmd allocates chunkID at index of the upload
+func addChunkCmd(uploadID, chunkID string, index int64) *command {
+	return &command{
+		Op:       opAddChunk,
+		UploadID: uploadID,
+		Chunk:    &ChunkInfo{ChunkID: chunkID, Replicas: []string{"node1"}},
+		Index:    index,
+	}
+}
+
+func TestApplyAddChunk(t *testing.T) {
+	tests := []struct {
+		name string
+		cmds []*command
+		code codes.Code // Of the last command
+		want []string
+	}{
+		{
+			name: "in order",
+			cmds: []*command{addChunkCmd("u1", "c1", 0), addChunkCmd("u1", "c2", 1)},
+			want: []string{"c1", "c2"},
+		},
+		{
+			name: "retried index keeps the first chunk",
+			cmds: []*command{addChunkCmd("u1", "c1", 0), addChunkCmd("u1", "c2", 0)},
+			want: []string{"c1"},
+		},
+		{
+			name: "retried earlier index",
+			cmds: []*command{addChunkCmd("u1", "c1", 0), addChunkCmd("u1", "c2", 1), addChunkCmd("u1", "c3", 0)},
+			want: []string{"c1", "c2"},
+		},
+		{
+			name: "skipped index",
+			cmds: []*command{addChunkCmd("u1", "c1", 0), addChunkCmd("u1", "c2", 2)},
+			code: codes.FailedPrecondition,
+			want: []string{"c1"},
+		},
+		{
+			name: "unknown upload",
+			cmds: []*command{addChunkCmd("u2", "c1", 0)},
+			code: codes.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer()
+			upload := &pendingUpload{
+				UploadID: "u1",
+				File:     &FileMetadata{FileName: "f", FileSize: unknownSize, ChunkSize: s.chunkSize},
+				Active:   time.Now(),
+			}
+			mustApply(t, s, &command{Op: opCreateUpload, Upload: upload})
+
+			last := len(tt.cmds) - 1
+			mustApply(t, s, tt.cmds[:last]...)
+			if err := s.applyCommand(tt.cmds[last]); status.Code(err) != tt.code {
+				t.Fatalf("error = %v, want code %v", err, tt.code)
+			}
+
+			var got []string
+			for _, chunk := range upload.File.Chunks {
+				got = append(got, chunk.ChunkID)
+			}
+			if !equalStrings(got, tt.want) {
+				t.Errorf("chunks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitFileVerifiesChunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		node1   map[string]uint32
+		node2   map[string]uint32
+		code    codes.Code
+		missing string // Listed in the error
+	}{
+		{
+			name:  "stored everywhere",
+			node1: map[string]uint32{"c0": 10, "c1": 11},
+			node2: map[string]uint32{"c0": 10, "c1": 11},
+		},
+		{
+			name:  "one copy of each chunk",
+			node1: map[string]uint32{"c0": 10},
+			node2: map[string]uint32{"c1": 11},
+		},
+		{
+			name:    "chunk never stored",
+			node1:   map[string]uint32{"c0": 10},
+			node2:   map[string]uint32{"c0": 10},
+			code:    codes.FailedPrecondition,
+			missing: "[1]",
+		},
+		{
+			name:    "wrong checksums",
+			node1:   map[string]uint32{"c0": 99, "c1": 11},
+			node2:   map[string]uint32{"c0": 99, "c1": 99},
+			code:    codes.FailedPrecondition,
+			missing: "[0]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestLeader(t, map[string]*fakeStorage{
+				"node1": {chunks: tt.node1},
+				"node2": {chunks: tt.node2},
+			})
+
+			file := &FileMetadata{FileName: "f", FileSize: 2 * s.chunkSize, ChunkSize: s.chunkSize}
+			for i, checksum := range []uint32{10, 11} {
+				checksum := checksum
+				file.Chunks = append(file.Chunks, &ChunkInfo{
+					ChunkID:  fmt.Sprintf("c%d", i),
+					Replicas: []string{"node1", "node2"},
+					Checksum: &checksum,
+				})
+			}
+			mustApply(t, s, &command{Op: opCreateUpload, Upload: &pendingUpload{UploadID: "u1", File: file, Active: time.Now()}})
+
+			_, err := s.CommitFile(context.Background(), &pb.CommitFileRequest{UploadId: "u1", FileSize: file.FileSize})
+			if status.Code(err) != tt.code {
+				t.Fatalf("CommitFile error = %v, want code %v", err, tt.code)
+			}
+			if tt.missing != "" && !strings.Contains(err.Error(), tt.missing) {
+				t.Errorf("error %v does not list chunks %s", err, tt.missing)
+			}
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			_, published := s.files["f"]
+			_, pending := s.uploads["u1"]
+			if published != (tt.code == codes.OK) || pending == published {
+				t.Errorf("published = %v, pending = %v after CommitFile", published, pending)
+			}
+		})
+	}
+}
+
+func TestCheckChunkWrite(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *pb.CheckChunkWriteRequest
+		code codes.Code
+		want uint32
+	}{
+		{name: "owner", req: &pb.CheckChunkWriteRequest{ChunkId: "c1", User: "alice"}, want: 7},
+		{name: "other user", req: &pb.CheckChunkWriteRequest{ChunkId: "c1", User: "bob"}, code: codes.PermissionDenied},
+		{name: "admin", req: &pb.CheckChunkWriteRequest{ChunkId: "c1", User: "carol", Admin: true}, want: 7},
+		{name: "chunk of a published file", req: &pb.CheckChunkWriteRequest{ChunkId: "c2", User: "alice"}, code: codes.NotFound},
+		{name: "unknown chunk", req: &pb.CheckChunkWriteRequest{ChunkId: "c3", User: "alice"}, code: codes.NotFound},
+	}
+
+	s := newTestServer()
+	checksum := uint32(7)
+	file := &FileMetadata{
+		FileName:  "f",
+		FileSize:  s.chunkSize,
+		ChunkSize: s.chunkSize,
+		Owner:     "alice",
+		Chunks:    []*ChunkInfo{{ChunkID: "c1", Replicas: []string{"node1"}, Checksum: &checksum}},
+	}
+	mustApply(t, s,
+		&command{Op: opCreateUpload, Upload: &pendingUpload{UploadID: "u1", File: file, Active: time.Now()}},
+		fileCmd("g", "c2"),
+	)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.CheckChunkWrite(context.Background(), tt.req)
+			if status.Code(err) != tt.code {
+				t.Fatalf("CheckChunkWrite error = %v, want code %v", err, tt.code)
+			}
+			if err == nil && resp.Checksum != tt.want {
+				t.Errorf("checksum = %d, want %d", resp.Checksum, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitFileRecordsMD5(t *testing.T) {
+	tests := []struct {
+		name string
+		md5  string
+		code codes.Code
+		want string
+	}{
+		{name: "reported", md5: "D41D8CD98F00B204E9800998ECF8427E", want: "d41d8cd98f00b204e9800998ecf8427e"},
+		{name: "not reported"},
+		{name: "not hex", md5: "not an md5", code: codes.InvalidArgument},
+		{name: "wrong length", md5: "d41d8cd9", code: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestLeader(t, nil)
+			file := &FileMetadata{FileName: "f", FileSize: unknownSize, ChunkSize: s.chunkSize}
+			mustApply(t, s, &command{Op: opCreateUpload, Upload: &pendingUpload{UploadID: "u1", File: file, Active: time.Now()}})
+
+			_, err := s.CommitFile(context.Background(), &pb.CommitFileRequest{UploadId: "u1", ContentMd5: tt.md5})
+			if status.Code(err) != tt.code {
+				t.Fatalf("CommitFile error = %v, want code %v", err, tt.code)
+			}
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if got := s.files["f"].info().ContentMd5; got != tt.want {
+				t.Errorf("content MD5 = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewChunkID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		chunkID, err := newChunkID()
+		if err != nil {
+			t.Fatalf("new chunk ID: %v", err)
+		}
+		random, ok := strings.CutPrefix(chunkID, "v1-")
+		if !ok || len(random) != 32 || strings.Trim(random, "0123456789abcdef") != "" {
+			t.Fatalf("chunk ID %q is not a version 1 handle", chunkID)
+		}
+		if seen[chunkID] {
+			t.Fatalf("chunk ID %q handed out twice", chunkID)
+		}
+		seen[chunkID] = true
+	}
+}
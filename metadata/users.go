@@ -0,0 +1,349 @@
+// metadata/users.go
+
+package main
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"sort"
+	"time"
+
+	"dfs/auth"
+	pb "dfs/proto/metadata"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UserMetadata holds a user account. Users authenticate with API tokens.
+type UserMetadata struct {
+	Name    string
+	Admin   bool
+	Created string
+}
+
+// info describes the user for clients
+func (u *UserMetadata) info() *pb.UserInfo {
+	return &pb.UserInfo{
+		Name:    u.Name,
+		Admin:   u.Admin,
+		Created: u.Created,
+	}
+}
+
+// TokenMetadata holds an API token. Only the hash of the token is kept, so
+// tokens cannot be recovered from the log or snapshots.
+type TokenMetadata struct {
+	ID          string
+	User        string
+	Hash        string
+	Description string
+	Created     string
+}
+
+// info describes the token for clients, without the token itself
+func (t *TokenMetadata) info() *pb.TokenInfo {
+	return &pb.TokenInfo{
+		Id:          t.ID,
+		User:        t.User,
+		Description: t.Description,
+		Created:     t.Created,
+	}
+}
+
+// userNamePattern matches valid user names
+var userNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_.-]{0,31}$`)
+
+// validateUserName rejects names that cannot be used as owners, including
+// the reserved root user
+func validateUserName(name string) error {
+	if name == "" {
+		return status.Errorf(codes.InvalidArgument, "User name is required")
+	}
+	if !userNamePattern.MatchString(name) {
+		return status.Errorf(codes.InvalidArgument, "Invalid user name %q", name)
+	}
+	if name == auth.RootUser {
+		return status.Errorf(codes.InvalidArgument, "User name %s is reserved", name)
+	}
+	return nil
+}
+
+// applyCreateUser adds a user account. The caller must hold s.mu.
+func (s *server) applyCreateUser(user *UserMetadata) error {
+	if _, exists := s.users[user.Name]; exists {
+		return status.Errorf(codes.AlreadyExists, "User %s already exists", user.Name)
+	}
+	s.users[user.Name] = user
+	return nil
+}
+
+// applyDeleteUser removes a user account and revokes its tokens. Everything
+// the user owned passes to root, so an account created later under the same
+// name does not inherit it and an administrator can hand it to someone else.
+// The caller must hold s.mu.
+func (s *server) applyDeleteUser(name string) error {
+	if _, exists := s.users[name]; !exists {
+		return status.Errorf(codes.NotFound, "User %s not found", name)
+	}
+	delete(s.users, name)
+	for hash, tokenMeta := range s.tokens {
+		if tokenMeta.User == name {
+			delete(s.tokens, hash)
+		}
+	}
+
+	files := s.allFiles()
+	for _, upload := range s.uploads {
+		files = append(files, upload.File)
+	}
+	for _, fileMeta := range files {
+		if fileMeta.Owner == name {
+			fileMeta.Owner = auth.RootUser
+		}
+	}
+	dirs := make([]*DirMetadata, 0, len(s.dirs))
+	for _, dirMeta := range s.dirs {
+		dirs = append(dirs, dirMeta)
+	}
+	for _, snap := range s.snapshots {
+		if snap.Owner == name {
+			snap.Owner = auth.RootUser
+		}
+		for _, dirMeta := range snap.Dirs {
+			dirs = append(dirs, dirMeta)
+		}
+	}
+	for _, dirMeta := range dirs {
+		if dirMeta.Owner == name {
+			dirMeta.Owner = auth.RootUser
+		}
+	}
+	return nil
+}
+
+// applyCreateToken adds an API token for an existing user. The caller must
+// hold s.mu.
+func (s *server) applyCreateToken(tokenMeta *TokenMetadata) error {
+	if _, exists := s.users[tokenMeta.User]; !exists {
+		return status.Errorf(codes.NotFound, "User %s not found", tokenMeta.User)
+	}
+	s.tokens[tokenMeta.Hash] = tokenMeta
+	return nil
+}
+
+// applyRevokeToken removes the API token with the given ID. The caller must
+// hold s.mu.
+func (s *server) applyRevokeToken(tokenID string) error {
+	for hash, tokenMeta := range s.tokens {
+		if tokenMeta.ID == tokenID {
+			delete(s.tokens, hash)
+			return nil
+		}
+	}
+	return status.Errorf(codes.NotFound, "Token %s not found", tokenID)
+}
+
+// tokenUser returns the user a token request is about: the caller unless
+// another user is named, which only administrators may do
+func tokenUser(id *auth.Identity, user string) (string, error) {
+	if user == "" {
+		if id == nil {
+			return "", status.Errorf(codes.InvalidArgument, "User is required")
+		}
+		return id.User, nil
+	}
+	if id != nil && user != id.User {
+		if err := requireAdmin(id); err != nil {
+			return "", err
+		}
+	}
+	return user, nil
+}
+
+// CreateUser adds a user account
+func (s *server) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
+	// Only the leader accepts writes
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	if err := requireAdmin(caller(ctx)); err != nil {
+		return nil, err
+	}
+	if err := validateUserName(req.Name); err != nil {
+		return nil, err
+	}
+
+	user := &UserMetadata{
+		Name:    req.Name,
+		Admin:   req.Admin,
+		Created: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if err := s.commit(ctx, &command{Op: opCreateUser, User: user}); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Created user %s (admin: %t)", user.Name, user.Admin)
+
+	return &pb.CreateUserResponse{
+		User: user.info(),
+	}, nil
+}
+
+// DeleteUser removes a user account and revokes its tokens
+func (s *server) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
+	// Only the leader accepts writes
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	if err := requireAdmin(caller(ctx)); err != nil {
+		return nil, err
+	}
+	if req.Name == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "User name is required")
+	}
+
+	if err := s.commit(ctx, &command{Op: opDeleteUser, Name: req.Name}); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Deleted user %s", req.Name)
+
+	return &pb.DeleteUserResponse{
+		Success: true,
+	}, nil
+}
+
+// ListUsers lists every user account, sorted by name
+func (s *server) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	if err := requireAdmin(caller(ctx)); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := make([]*pb.UserInfo, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user.info())
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+
+	return &pb.ListUsersResponse{
+		Users: users,
+	}, nil
+}
+
+// CreateToken issues a new API token for a user. The token is only returned
+// here; the metadata service keeps its hash.
+func (s *server) CreateToken(ctx context.Context, req *pb.CreateTokenRequest) (*pb.CreateTokenResponse, error) {
+	// Only the leader accepts writes
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	user, err := tokenUser(caller(ctx), req.User)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := auth.NewToken()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to create token: %v", err)
+	}
+	tokenID, err := newUploadID()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to create token ID: %v", err)
+	}
+
+	tokenMeta := &TokenMetadata{
+		ID:          tokenID,
+		User:        user,
+		Hash:        auth.HashToken(token),
+		Description: req.Description,
+		Created:     time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if err := s.commit(ctx, &command{Op: opCreateToken, Token: tokenMeta}); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Created token %s for user %s", tokenID, user)
+
+	return &pb.CreateTokenResponse{
+		Token: token,
+		Info:  tokenMeta.info(),
+	}, nil
+}
+
+// ListTokens lists the tokens of a user, oldest first
+func (s *server) ListTokens(ctx context.Context, req *pb.ListTokensRequest) (*pb.ListTokensResponse, error) {
+	user, err := tokenUser(caller(ctx), req.User)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tokens []*pb.TokenInfo
+	for _, tokenMeta := range s.tokens {
+		if tokenMeta.User == user {
+			tokens = append(tokens, tokenMeta.info())
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].Created != tokens[j].Created {
+			return tokens[i].Created < tokens[j].Created
+		}
+		return tokens[i].Id < tokens[j].Id
+	})
+
+	return &pb.ListTokensResponse{
+		Tokens: tokens,
+	}, nil
+}
+
+// RevokeToken removes an API token. Users may revoke their own tokens and
+// administrators any token.
+func (s *server) RevokeToken(ctx context.Context, req *pb.RevokeTokenRequest) (*pb.RevokeTokenResponse, error) {
+	// Only the leader accepts writes
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	if req.Id == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Token ID is required")
+	}
+
+	id := caller(ctx)
+	s.mu.Lock()
+	var tokenOwner string
+	for _, tokenMeta := range s.tokens {
+		if tokenMeta.ID == req.Id {
+			tokenOwner = tokenMeta.User
+		}
+	}
+	s.mu.Unlock()
+
+	if tokenOwner == "" {
+		return nil, status.Errorf(codes.NotFound, "Token %s not found", req.Id)
+	}
+	if id != nil && tokenOwner != id.User {
+		if err := requireAdmin(id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.commit(ctx, &command{Op: opRevokeToken, Name: req.Id}); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Revoked token %s of user %s", req.Id, tokenOwner)
+
+	return &pb.RevokeTokenResponse{
+		Success: true,
+	}, nil
+}
@@ -0,0 +1,73 @@
+// metadata/users_test.go
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"dfs/auth"
+)
+
+func TestApplyDeleteUserDisownsEntries(t *testing.T) {
+	s := newTestServer()
+	mustApply(t, s,
+		&command{Op: opCreateUser, User: &UserMetadata{Name: "alice"}},
+		&command{Op: opCreateUser, User: &UserMetadata{Name: "bob"}},
+		&command{Op: opCreateToken, Token: &TokenMetadata{ID: "t1", Hash: "h1", User: "alice"}},
+		&command{Op: opCreateToken, Token: &TokenMetadata{ID: "t2", Hash: "h2", User: "bob"}},
+	)
+	mustApply(t, s, uploadCmds("u1", "home/alice/notes", "c1")...)
+	mustApply(t, s, uploadCmds("u2", "home/alice/notes", "c2")...)
+	mustApply(t, s,
+		fileCmd("home/bob/notes", "c3"),
+		chmodCmd("home/alice", "alice", 0700),
+		chmodCmd("home/alice/notes", "alice", 0600),
+		chmodCmd("home/bob", "bob", 0700),
+		chmodCmd("home/bob/notes", "bob", 0600),
+	)
+	s.versions["home/alice/notes"][0].Owner = "alice"
+	now := time.Now()
+	mustApply(t, s, &command{Op: opCreateSnapshot, Snapshot: "snap", FileName: "home", Time: &now, Owner: "alice"})
+	mustApply(t, s, &command{Op: opCreateUpload, Upload: &pendingUpload{
+		UploadID: "u3",
+		File:     &FileMetadata{FileName: "home/alice/draft", Owner: "alice"},
+	}})
+
+	mustApply(t, s, &command{Op: opDeleteUser, Name: "alice"})
+
+	if _, exists := s.users["alice"]; exists {
+		t.Errorf("user alice still exists")
+	}
+	if _, exists := s.tokens["h1"]; exists {
+		t.Errorf("token of alice still exists")
+	}
+	if _, exists := s.tokens["h2"]; !exists {
+		t.Errorf("token of bob was revoked")
+	}
+
+	// Nothing is left to a new account named alice, and bob keeps his
+	owners := map[string]string{
+		"dir home/alice":           s.dirs["home/alice"].Owner,
+		"file home/alice/notes":    s.files["home/alice/notes"].Owner,
+		"version home/alice/notes": s.versions["home/alice/notes"][0].Owner,
+		"upload home/alice/draft":  s.uploads["u3"].File.Owner,
+		"snapshot":                 s.snapshots["snap"].Owner,
+		"snapshot dir home/alice":  s.snapshots["snap"].Dirs["home/alice"].Owner,
+		"snapshot file home/alice": s.snapshots["snap"].Files["home/alice/notes"].Owner,
+	}
+	for entry, owner := range owners {
+		if owner != auth.RootUser {
+			t.Errorf("%s is owned by %q, want %s", entry, owner, auth.RootUser)
+		}
+	}
+	if owner := s.files["home/bob/notes"].Owner; owner != "bob" {
+		t.Errorf("home/bob/notes is owned by %q, want bob", owner)
+	}
+
+	mustApply(t, s, &command{Op: opCreateUser, User: &UserMetadata{Name: "alice"}})
+	alice := &auth.Identity{User: "alice"}
+	if err := authorize(alice, s.live(), "home/alice/notes", permRead); err == nil {
+		t.Errorf("new account alice can read the old account's files")
+	}
+}
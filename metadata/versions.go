@@ -0,0 +1,186 @@
+// metadata/versions.go
+
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	pb "dfs/proto/metadata"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// retentionPolicy decides how long older versions of a file are kept. The
+// current version is always kept.
+type retentionPolicy struct {
+	keep    int           // Versions to keep per file, counting the current one; zero keeps all
+	keepFor time.Duration // Age after which older versions are pruned; zero keeps them forever
+}
+
+// version returns the version number of a file. Files written before
+// versioning are version 1.
+func (f *FileMetadata) version() int64 {
+	if f.Version == 0 {
+		return 1
+	}
+	return f.Version
+}
+
+// addVersion makes fileMeta the current version of its path, keeping the file
+// it replaces as an older version. A new version keeps the owner and mode of
+// the file it replaces. The caller must hold s.mu.
+func (s *server) addVersion(fileMeta *FileMetadata) {
+	fileMeta.Version = 1
+	if prev, exists := s.files[fileMeta.FileName]; exists {
+		s.versions[prev.FileName] = append(s.versions[prev.FileName], prev)
+		fileMeta.Version = prev.version() + 1
+		fileMeta.Owner = prev.Owner
+		fileMeta.Mode = prev.Mode
+	}
+	s.files[fileMeta.FileName] = fileMeta
+}
+
+// findVersion returns the given version of the file at p, or nil if there is
+// no such version. The caller must hold s.mu.
+func (s *server) findVersion(p string, version int64) *FileMetadata {
+	if fileMeta, exists := s.files[p]; exists && fileMeta.version() == version {
+		return fileMeta
+	}
+	for _, fileMeta := range s.versions[p] {
+		if fileMeta.version() == version {
+			return fileMeta
+		}
+	}
+	return nil
+}
+
+// allFiles returns every version of every file, including the copies held by
+// snapshots, so chunk bookkeeping covers everything that still references a
+// chunk. The caller must hold s.mu.
+func (s *server) allFiles() []*FileMetadata {
+	files := make([]*FileMetadata, 0, len(s.files))
+	for _, fileMeta := range s.files {
+		files = append(files, fileMeta)
+	}
+	for _, versions := range s.versions {
+		files = append(files, versions...)
+	}
+	for _, snap := range s.snapshots {
+		for _, fileMeta := range snap.Files {
+			files = append(files, fileMeta)
+		}
+	}
+	return files
+}
+
+// applyPruneVersions removes the listed older versions of the file at p and
+// queues their chunks for garbage collection. Versions that are already gone
+// are ignored. The caller must hold s.mu.
+func (s *server) applyPruneVersions(p string, versions []int64) {
+	prune := make(map[int64]bool, len(versions))
+	for _, version := range versions {
+		prune[version] = true
+	}
+
+	var kept []*FileMetadata
+	for _, fileMeta := range s.versions[p] {
+		if prune[fileMeta.version()] {
+			s.discardChunks(fileMeta.Chunks)
+			continue
+		}
+		kept = append(kept, fileMeta)
+	}
+
+	if len(kept) == 0 {
+		delete(s.versions, p)
+	} else {
+		s.versions[p] = kept
+	}
+}
+
+// ListVersions lists every version of a file, newest first
+func (s *server) ListVersions(ctx context.Context, req *pb.ListVersionsRequest) (*pb.ListVersionsResponse, error) {
+	fileName, err := cleanPath(req.FileName)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fileMeta, exists := s.files[fileName]
+	if !exists {
+		if s.isDir(fileName) {
+			return nil, status.Errorf(codes.FailedPrecondition, "%s is a directory", fileName)
+		}
+		return nil, status.Errorf(codes.NotFound, "File %s not found", fileName)
+	}
+	if err := authorize(caller(ctx), s.live(), fileName, permRead); err != nil {
+		return nil, err
+	}
+
+	versions := []*pb.FileInfo{s.fileInfo(fileMeta)}
+	older := s.versions[fileName]
+	for i := len(older) - 1; i >= 0; i-- {
+		versions = append(versions, s.fileInfo(older[i]))
+	}
+
+	return &pb.ListVersionsResponse{
+		Versions: versions,
+	}, nil
+}
+
+// pruneVersions removes the older versions that fall outside the retention
+// policy, one file at a time
+func (s *server) pruneVersions(policy retentionPolicy) {
+	if policy.keep <= 0 && policy.keepFor <= 0 {
+		return
+	}
+
+	// Work out what to prune under the lock, then commit without it
+	s.mu.Lock()
+	prune := s.prunableVersions(policy, time.Now())
+	s.mu.Unlock()
+
+	for p, versions := range prune {
+		err := s.commit(context.Background(), &command{Op: opPruneVersions, FileName: p, Versions: versions})
+		if err != nil {
+			log.Printf("Retention: failed to prune versions of %s: %v", p, err)
+			continue
+		}
+		log.Printf("Retention: pruned %d versions of %s", len(versions), p)
+	}
+}
+
+// prunableVersions returns the older versions of each file that fall outside
+// the retention policy at now. The caller must hold s.mu.
+func (s *server) prunableVersions(policy retentionPolicy, now time.Time) map[string][]int64 {
+	prune := make(map[string][]int64)
+	for p, versions := range s.versions {
+		for i, fileMeta := range versions {
+			// versions is oldest first and the current version counts too
+			newer := len(versions) - i
+			if policy.keep > 0 && newer >= policy.keep {
+				prune[p] = append(prune[p], fileMeta.version())
+				continue
+			}
+			if policy.keepFor > 0 && isOlderThan(fileMeta.UploadDate, policy.keepFor, now) {
+				prune[p] = append(prune[p], fileMeta.version())
+			}
+		}
+	}
+	return prune
+}
+
+// isOlderThan reports whether an upload date is further than age before now.
+// Dates that cannot be parsed are never considered old.
+func isOlderThan(uploadDate string, age time.Duration, now time.Time) bool {
+	uploaded, err := time.ParseInLocation("2006-01-02 15:04:05", uploadDate, time.Local)
+	if err != nil {
+		return false
+	}
+	return now.Sub(uploaded) > age
+}
@@ -0,0 +1,132 @@
+// metadata/versions_test.go
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPrunableVersions(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name   string
+		policy retentionPolicy
+		dates  []string // Upload dates of versions 1 and 2; version 3 is current
+		want   []int64
+	}{
+		{
+			name:   "no policy",
+			policy: retentionPolicy{},
+		},
+		{
+			name:   "keep only the current version",
+			policy: retentionPolicy{keep: 1},
+			want:   []int64{1, 2},
+		},
+		{
+			name:   "keep two versions",
+			policy: retentionPolicy{keep: 2},
+			want:   []int64{1},
+		},
+		{
+			name:   "keep every version",
+			policy: retentionPolicy{keep: 3},
+		},
+		{
+			name:   "keep more versions than exist",
+			policy: retentionPolicy{keep: 5},
+		},
+		{
+			name:   "oldest version exactly at the cutoff",
+			policy: retentionPolicy{keepFor: 9 * day},
+		},
+		{
+			name:   "oldest version just past the cutoff",
+			policy: retentionPolicy{keepFor: 9*day - time.Second},
+			want:   []int64{1},
+		},
+		{
+			name:   "current version is never pruned by age",
+			policy: retentionPolicy{keepFor: time.Hour},
+			want:   []int64{1, 2},
+		},
+		{
+			name:   "count and age combined",
+			policy: retentionPolicy{keep: 2, keepFor: 6 * day},
+			want:   []int64{1},
+		},
+		{
+			name:   "unparseable dates are never old",
+			policy: retentionPolicy{keepFor: time.Hour},
+			dates:  []string{"yesterday", "2024-01-05 00:00:00"},
+			want:   []int64{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dates := tt.dates
+			if dates == nil {
+				dates = []string{"2024-01-01 00:00:00", "2024-01-05 00:00:00"}
+			}
+
+			s := newTestServer()
+			mustApply(t, s, uploadCmds("u1", "a", "c1")...)
+			mustApply(t, s, uploadCmds("u2", "a", "c2")...)
+			mustApply(t, s, uploadCmds("u3", "a", "c3")...)
+			for i, version := range s.versions["a"] {
+				version.UploadDate = dates[i]
+			}
+			s.files["a"].UploadDate = "2024-01-09 00:00:00"
+
+			got := s.prunableVersions(tt.policy, now)["a"]
+			if len(got) != len(tt.want) {
+				t.Fatalf("prunable versions = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("prunable versions = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyPruneVersionsKeepsSnapshotChunks(t *testing.T) {
+	tests := []struct {
+		name     string
+		snapshot bool
+		want     []string
+	}{
+		{name: "unreferenced", want: nil},
+		{name: "referenced by a snapshot", snapshot: true, want: []string{"node1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer()
+			mustApply(t, s, uploadCmds("u1", "d/a", "c1")...)
+			if tt.snapshot {
+				now := time.Now()
+				mustApply(t, s, &command{Op: opCreateSnapshot, Snapshot: "snap", FileName: "d", Time: &now})
+			}
+			mustApply(t, s, uploadCmds("u2", "d/a", "c2")...)
+			mustApply(t, s, &command{Op: opPruneVersions, FileName: "d/a", Versions: []int64{1}})
+
+			if _, exists := s.versions["d/a"]; exists {
+				t.Errorf("version 1 of d/a was not pruned")
+			}
+			if _, exists := s.garbage["c1"]; !exists {
+				t.Errorf("chunk c1 of the pruned version was not queued for garbage collection")
+			}
+
+			// The garbage collector only deletes replicas nothing references
+			if got := sortedKeys(s.liveReplicas("c1")); !equalStrings(got, tt.want) {
+				t.Errorf("live replicas of c1 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,7 +2,7 @@
 // versions:
 // - protoc-gen-go-grpc v1.5.1
 // - protoc             v5.28.2
-// source: proto/client.proto
+// source: proto/client/client.proto
 
 package client
 
@@ -19,20 +19,28 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ClientService_CreateFile_FullMethodName = "/client.ClientService/CreateFile"
-	ClientService_WriteFile_FullMethodName  = "/client.ClientService/WriteFile"
-	ClientService_ReadFile_FullMethodName   = "/client.ClientService/ReadFile"
-	ClientService_DeleteFile_FullMethodName = "/client.ClientService/DeleteFile"
+	ClientService_CreateFile_FullMethodName      = "/client.ClientService/CreateFile"
+	ClientService_WriteFile_FullMethodName       = "/client.ClientService/WriteFile"
+	ClientService_ReadFile_FullMethodName        = "/client.ClientService/ReadFile"
+	ClientService_DeleteFile_FullMethodName      = "/client.ClientService/DeleteFile"
+	ClientService_WriteFileStream_FullMethodName = "/client.ClientService/WriteFileStream"
+	ClientService_ReadFileStream_FullMethodName  = "/client.ClientService/ReadFileStream"
 )
 
 // ClientServiceClient is the client API for ClientService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ClientService is a single gRPC endpoint for the whole file system, served by
+// the gateway. Callers never deal with chunks, storage nodes or the metadata
+// service.
 type ClientServiceClient interface {
 	CreateFile(ctx context.Context, in *CreateFileRequest, opts ...grpc.CallOption) (*CreateFileResponse, error)
 	WriteFile(ctx context.Context, in *WriteFileRequest, opts ...grpc.CallOption) (*WriteFileResponse, error)
 	ReadFile(ctx context.Context, in *ReadFileRequest, opts ...grpc.CallOption) (*ReadFileResponse, error)
 	DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*DeleteFileResponse, error)
+	WriteFileStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[WriteFileRequest, WriteFileResponse], error)
+	ReadFileStream(ctx context.Context, in *ReadFileRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ReadFileResponse], error)
 }
 
 type clientServiceClient struct {
@@ -83,14 +91,52 @@ func (c *clientServiceClient) DeleteFile(ctx context.Context, in *DeleteFileRequ
 	return out, nil
 }
 
+func (c *clientServiceClient) WriteFileStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[WriteFileRequest, WriteFileResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ClientService_ServiceDesc.Streams[0], ClientService_WriteFileStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WriteFileRequest, WriteFileResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ClientService_WriteFileStreamClient = grpc.ClientStreamingClient[WriteFileRequest, WriteFileResponse]
+
+func (c *clientServiceClient) ReadFileStream(ctx context.Context, in *ReadFileRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ReadFileResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ClientService_ServiceDesc.Streams[1], ClientService_ReadFileStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ReadFileRequest, ReadFileResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ClientService_ReadFileStreamClient = grpc.ServerStreamingClient[ReadFileResponse]
+
 // ClientServiceServer is the server API for ClientService service.
 // All implementations must embed UnimplementedClientServiceServer
 // for forward compatibility.
+//
+// ClientService is a single gRPC endpoint for the whole file system, served by
+// the gateway. Callers never deal with chunks, storage nodes or the metadata
+// service.
 type ClientServiceServer interface {
 	CreateFile(context.Context, *CreateFileRequest) (*CreateFileResponse, error)
 	WriteFile(context.Context, *WriteFileRequest) (*WriteFileResponse, error)
 	ReadFile(context.Context, *ReadFileRequest) (*ReadFileResponse, error)
 	DeleteFile(context.Context, *DeleteFileRequest) (*DeleteFileResponse, error)
+	WriteFileStream(grpc.ClientStreamingServer[WriteFileRequest, WriteFileResponse]) error
+	ReadFileStream(*ReadFileRequest, grpc.ServerStreamingServer[ReadFileResponse]) error
 	mustEmbedUnimplementedClientServiceServer()
 }
 
@@ -113,6 +159,12 @@ func (UnimplementedClientServiceServer) ReadFile(context.Context, *ReadFileReque
 func (UnimplementedClientServiceServer) DeleteFile(context.Context, *DeleteFileRequest) (*DeleteFileResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteFile not implemented")
 }
+func (UnimplementedClientServiceServer) WriteFileStream(grpc.ClientStreamingServer[WriteFileRequest, WriteFileResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method WriteFileStream not implemented")
+}
+func (UnimplementedClientServiceServer) ReadFileStream(*ReadFileRequest, grpc.ServerStreamingServer[ReadFileResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ReadFileStream not implemented")
+}
 func (UnimplementedClientServiceServer) mustEmbedUnimplementedClientServiceServer() {}
 func (UnimplementedClientServiceServer) testEmbeddedByValue()                       {}
 
@@ -206,6 +258,24 @@ func _ClientService_DeleteFile_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ClientService_WriteFileStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ClientServiceServer).WriteFileStream(&grpc.GenericServerStream[WriteFileRequest, WriteFileResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ClientService_WriteFileStreamServer = grpc.ClientStreamingServer[WriteFileRequest, WriteFileResponse]
+
+func _ClientService_ReadFileStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReadFileRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ClientServiceServer).ReadFileStream(m, &grpc.GenericServerStream[ReadFileRequest, ReadFileResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ClientService_ReadFileStreamServer = grpc.ServerStreamingServer[ReadFileResponse]
+
 // ClientService_ServiceDesc is the grpc.ServiceDesc for ClientService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -230,6 +300,17 @@ var ClientService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _ClientService_DeleteFile_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
-	Metadata: "proto/client.proto",
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WriteFileStream",
+			Handler:       _ClientService_WriteFileStream_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ReadFileStream",
+			Handler:       _ClientService_ReadFileStream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/client/client.proto",
 }
@@ -19,8 +19,13 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	StorageService_StoreChunk_FullMethodName    = "/storage.StorageService/StoreChunk"
-	StorageService_RetrieveChunk_FullMethodName = "/storage.StorageService/RetrieveChunk"
+	StorageService_StoreChunk_FullMethodName          = "/storage.StorageService/StoreChunk"
+	StorageService_RetrieveChunk_FullMethodName       = "/storage.StorageService/RetrieveChunk"
+	StorageService_StoreChunkStream_FullMethodName    = "/storage.StorageService/StoreChunkStream"
+	StorageService_RetrieveChunkStream_FullMethodName = "/storage.StorageService/RetrieveChunkStream"
+	StorageService_ReplicateChunk_FullMethodName      = "/storage.StorageService/ReplicateChunk"
+	StorageService_DeleteChunk_FullMethodName         = "/storage.StorageService/DeleteChunk"
+	StorageService_StatChunk_FullMethodName           = "/storage.StorageService/StatChunk"
 )
 
 // StorageServiceClient is the client API for StorageService service.
@@ -29,6 +34,12 @@ const (
 type StorageServiceClient interface {
 	StoreChunk(ctx context.Context, in *StoreChunkRequest, opts ...grpc.CallOption) (*StoreChunkResponse, error)
 	RetrieveChunk(ctx context.Context, in *RetrieveChunkRequest, opts ...grpc.CallOption) (*RetrieveChunkResponse, error)
+	// Streaming variants that move a chunk in small frames instead of one message
+	StoreChunkStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[StoreChunkFrame, StoreChunkResponse], error)
+	RetrieveChunkStream(ctx context.Context, in *RetrieveChunkRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChunkFrame], error)
+	ReplicateChunk(ctx context.Context, in *ReplicateChunkRequest, opts ...grpc.CallOption) (*ReplicateChunkResponse, error)
+	DeleteChunk(ctx context.Context, in *DeleteChunkRequest, opts ...grpc.CallOption) (*DeleteChunkResponse, error)
+	StatChunk(ctx context.Context, in *StatChunkRequest, opts ...grpc.CallOption) (*StatChunkResponse, error)
 }
 
 type storageServiceClient struct {
@@ -59,12 +70,80 @@ func (c *storageServiceClient) RetrieveChunk(ctx context.Context, in *RetrieveCh
 	return out, nil
 }
 
+func (c *storageServiceClient) StoreChunkStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[StoreChunkFrame, StoreChunkResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &StorageService_ServiceDesc.Streams[0], StorageService_StoreChunkStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StoreChunkFrame, StoreChunkResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StorageService_StoreChunkStreamClient = grpc.ClientStreamingClient[StoreChunkFrame, StoreChunkResponse]
+
+func (c *storageServiceClient) RetrieveChunkStream(ctx context.Context, in *RetrieveChunkRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChunkFrame], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &StorageService_ServiceDesc.Streams[1], StorageService_RetrieveChunkStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RetrieveChunkRequest, ChunkFrame]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StorageService_RetrieveChunkStreamClient = grpc.ServerStreamingClient[ChunkFrame]
+
+func (c *storageServiceClient) ReplicateChunk(ctx context.Context, in *ReplicateChunkRequest, opts ...grpc.CallOption) (*ReplicateChunkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReplicateChunkResponse)
+	err := c.cc.Invoke(ctx, StorageService_ReplicateChunk_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) DeleteChunk(ctx context.Context, in *DeleteChunkRequest, opts ...grpc.CallOption) (*DeleteChunkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteChunkResponse)
+	err := c.cc.Invoke(ctx, StorageService_DeleteChunk_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) StatChunk(ctx context.Context, in *StatChunkRequest, opts ...grpc.CallOption) (*StatChunkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StatChunkResponse)
+	err := c.cc.Invoke(ctx, StorageService_StatChunk_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // StorageServiceServer is the server API for StorageService service.
 // All implementations must embed UnimplementedStorageServiceServer
 // for forward compatibility.
 type StorageServiceServer interface {
 	StoreChunk(context.Context, *StoreChunkRequest) (*StoreChunkResponse, error)
 	RetrieveChunk(context.Context, *RetrieveChunkRequest) (*RetrieveChunkResponse, error)
+	// Streaming variants that move a chunk in small frames instead of one message
+	StoreChunkStream(grpc.ClientStreamingServer[StoreChunkFrame, StoreChunkResponse]) error
+	RetrieveChunkStream(*RetrieveChunkRequest, grpc.ServerStreamingServer[ChunkFrame]) error
+	ReplicateChunk(context.Context, *ReplicateChunkRequest) (*ReplicateChunkResponse, error)
+	DeleteChunk(context.Context, *DeleteChunkRequest) (*DeleteChunkResponse, error)
+	StatChunk(context.Context, *StatChunkRequest) (*StatChunkResponse, error)
 	mustEmbedUnimplementedStorageServiceServer()
 }
 
@@ -81,6 +160,21 @@ func (UnimplementedStorageServiceServer) StoreChunk(context.Context, *StoreChunk
 func (UnimplementedStorageServiceServer) RetrieveChunk(context.Context, *RetrieveChunkRequest) (*RetrieveChunkResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RetrieveChunk not implemented")
 }
+func (UnimplementedStorageServiceServer) StoreChunkStream(grpc.ClientStreamingServer[StoreChunkFrame, StoreChunkResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StoreChunkStream not implemented")
+}
+func (UnimplementedStorageServiceServer) RetrieveChunkStream(*RetrieveChunkRequest, grpc.ServerStreamingServer[ChunkFrame]) error {
+	return status.Errorf(codes.Unimplemented, "method RetrieveChunkStream not implemented")
+}
+func (UnimplementedStorageServiceServer) ReplicateChunk(context.Context, *ReplicateChunkRequest) (*ReplicateChunkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReplicateChunk not implemented")
+}
+func (UnimplementedStorageServiceServer) DeleteChunk(context.Context, *DeleteChunkRequest) (*DeleteChunkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteChunk not implemented")
+}
+func (UnimplementedStorageServiceServer) StatChunk(context.Context, *StatChunkRequest) (*StatChunkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StatChunk not implemented")
+}
 func (UnimplementedStorageServiceServer) mustEmbedUnimplementedStorageServiceServer() {}
 func (UnimplementedStorageServiceServer) testEmbeddedByValue()                        {}
 
@@ -138,6 +232,78 @@ func _StorageService_RetrieveChunk_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StorageService_StoreChunkStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(StorageServiceServer).StoreChunkStream(&grpc.GenericServerStream[StoreChunkFrame, StoreChunkResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StorageService_StoreChunkStreamServer = grpc.ClientStreamingServer[StoreChunkFrame, StoreChunkResponse]
+
+func _StorageService_RetrieveChunkStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RetrieveChunkRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageServiceServer).RetrieveChunkStream(m, &grpc.GenericServerStream[RetrieveChunkRequest, ChunkFrame]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StorageService_RetrieveChunkStreamServer = grpc.ServerStreamingServer[ChunkFrame]
+
+func _StorageService_ReplicateChunk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplicateChunkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).ReplicateChunk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_ReplicateChunk_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).ReplicateChunk(ctx, req.(*ReplicateChunkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_DeleteChunk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteChunkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).DeleteChunk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_DeleteChunk_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).DeleteChunk(ctx, req.(*DeleteChunkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_StatChunk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatChunkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).StatChunk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_StatChunk_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).StatChunk(ctx, req.(*StatChunkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // StorageService_ServiceDesc is the grpc.ServiceDesc for StorageService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -153,7 +319,30 @@ var StorageService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RetrieveChunk",
 			Handler:    _StorageService_RetrieveChunk_Handler,
 		},
+		{
+			MethodName: "ReplicateChunk",
+			Handler:    _StorageService_ReplicateChunk_Handler,
+		},
+		{
+			MethodName: "DeleteChunk",
+			Handler:    _StorageService_DeleteChunk_Handler,
+		},
+		{
+			MethodName: "StatChunk",
+			Handler:    _StorageService_StatChunk_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StoreChunkStream",
+			Handler:       _StorageService_StoreChunkStream_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "RetrieveChunkStream",
+			Handler:       _StorageService_RetrieveChunkStream_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/storage/storage.proto",
 }
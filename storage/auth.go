@@ -0,0 +1,247 @@
+// storage/auth.go
+
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"dfs/auth"
+	metadataPb "dfs/proto/metadata"
+	pb "dfs/proto/storage"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	tokenCacheTTL = 30 * time.Second // How long a verified token is trusted before asking again
+	verifyTimeout = 5 * time.Second  // Bound on a single VerifyToken call
+)
+
+// authRules lets clients read and write chunks, whose random IDs they learn
+// from the metadata service after its permission checks. Since readers of a
+// file learn its chunk IDs too, clients may only store chunks of their own
+// pending uploads; see authorizeStore. Everything else is reserved for the
+// cluster's own nodes.
+var authRules = auth.Rules{
+	Internal: []string{
+		pb.StorageService_ReplicateChunk_FullMethodName,
+		pb.StorageService_DeleteChunk_FullMethodName,
+		pb.StorageService_StatChunk_FullMethodName,
+	},
+}
+
+// trusted reports whether the caller is another node of the cluster, or may do
+// anything because authentication is disabled
+func trusted(ctx context.Context) bool {
+	id, ok := auth.FromContext(ctx)
+	return !ok || id.Cluster
+}
+
+// authorizeChunkID rejects legacy chunk IDs from clients other than
+// administrators. Legacy IDs are derived from file names, so unlike random
+// handles they could be guessed to read a file without permission.
+func authorizeChunkID(ctx context.Context, chunkID string) error {
+	id, ok := auth.FromContext(ctx)
+	if !ok || id.Cluster || id.Admin || isHandle(chunkID) {
+		return nil
+	}
+	return status.Errorf(codes.PermissionDenied, "Chunk %s may only be accessed by cluster nodes and administrators", chunkID)
+}
+
+// authorizeStore checks that the caller may store a chunk with the given
+// checksum. The metadata service must confirm that the chunk belongs to a
+// pending upload of the caller and that the checksum is the one recorded
+// when the chunk was allocated; otherwise anyone who can read a file could
+// rewrite its chunks. A chunk that is already stored is never replaced by a
+// client, but storing the same data again, as a retry does, is allowed;
+// stored reports that the chunk is already there, so there is nothing to
+// write.
+func (s *server) authorizeStore(ctx context.Context, chunkID string, checksum *uint32) (stored bool, err error) {
+	if trusted(ctx) {
+		return false, nil
+	}
+	if err := authorizeChunkID(ctx, chunkID); err != nil {
+		return false, err
+	}
+	if checksum == nil {
+		return false, status.Errorf(codes.InvalidArgument, "A checksum is required to store chunk %s", chunkID)
+	}
+
+	id, _ := auth.FromContext(ctx)
+	expected, err := s.verifier.expectedChecksum(ctx, id, chunkID)
+	if err != nil {
+		return false, err
+	}
+	if *checksum != expected {
+		return false, status.Errorf(codes.PermissionDenied, "Checksum of chunk %s does not match its upload", chunkID)
+	}
+
+	if _, err := os.Stat(s.chunkPath(chunkID)); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, status.Errorf(codes.Internal, "Failed to check chunk %s: %v", chunkID, err)
+	}
+	existing, ok, err := s.readChecksum(chunkID)
+	if err != nil {
+		return false, status.Errorf(codes.Internal, "Failed to check chunk %s: %v", chunkID, err)
+	}
+	if ok && existing == *checksum {
+		return true, nil
+	}
+	return false, status.Errorf(codes.PermissionDenied, "Chunk %s is already stored and may only be replaced by cluster nodes", chunkID)
+}
+
+// clusterDialOptions returns the options for connecting to metadata nodes and
+// other storage nodes, which authenticate this node by the cluster token
+func clusterDialOptions(clusterToken string) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithPerRPCCredentials(auth.Credentials(clusterToken)),
+	}
+}
+
+// tokenVerifier authenticates the callers of a storage node. The cluster
+// token is checked locally; user tokens are verified by the metadata service,
+// which holds the user accounts, and remembered for a short while.
+type tokenVerifier struct {
+	clusterToken  string
+	metadataAddrs []string
+
+	mu      sync.Mutex
+	clients map[string]metadataPb.MetadataServiceClient
+	cache   map[string]cachedIdentity // By the hash of the token
+}
+
+// cachedIdentity is a verified token's identity and when it must be verified
+// again
+type cachedIdentity struct {
+	id      *auth.Identity
+	expires time.Time
+}
+
+// newTokenVerifier creates a verifier asking the metadata nodes in
+// metadataAddrs about user tokens
+func newTokenVerifier(clusterToken string, metadataAddrs []string) *tokenVerifier {
+	return &tokenVerifier{
+		clusterToken:  clusterToken,
+		metadataAddrs: metadataAddrs,
+		clients:       make(map[string]metadataPb.MetadataServiceClient),
+		cache:         make(map[string]cachedIdentity),
+	}
+}
+
+// authenticate resolves a token to the identity of its holder
+func (v *tokenVerifier) authenticate(ctx context.Context, token string) (*auth.Identity, error) {
+	if auth.Equal(token, v.clusterToken) {
+		return auth.Root, nil
+	}
+
+	hash := auth.HashToken(token)
+	v.mu.Lock()
+	cached, exists := v.cache[hash]
+	v.mu.Unlock()
+	if exists && time.Now().Before(cached.expires) {
+		return cached.id, nil
+	}
+
+	// Any metadata node can answer, so try them in turn
+	var err error
+	for _, addr := range v.metadataAddrs {
+		var client metadataPb.MetadataServiceClient
+		client, err = v.client(addr)
+		if err != nil {
+			continue
+		}
+
+		verifyCtx, cancel := context.WithTimeout(ctx, verifyTimeout)
+		var resp *metadataPb.VerifyTokenResponse
+		resp, err = client.VerifyToken(verifyCtx, &metadataPb.VerifyTokenRequest{
+			Token: token,
+		})
+		cancel()
+		if status.Code(err) == codes.Unauthenticated {
+			return nil, err
+		}
+		if err != nil {
+			continue
+		}
+
+		id := &auth.Identity{User: resp.User.Name, Admin: resp.User.Admin}
+		v.remember(hash, id)
+		return id, nil
+	}
+	return nil, status.Errorf(codes.Unavailable, "Failed to verify token: %v", err)
+}
+
+// expectedChecksum asks the metadata service which checksum a chunk that
+// the holder of id is about to store must have
+func (v *tokenVerifier) expectedChecksum(ctx context.Context, id *auth.Identity, chunkID string) (uint32, error) {
+	// A follower may not have heard of a chunk allocated a moment ago, so
+	// only a refusal ends the search
+	var err error
+	for _, addr := range v.metadataAddrs {
+		var client metadataPb.MetadataServiceClient
+		client, err = v.client(addr)
+		if err != nil {
+			continue
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, verifyTimeout)
+		var resp *metadataPb.CheckChunkWriteResponse
+		resp, err = client.CheckChunkWrite(checkCtx, &metadataPb.CheckChunkWriteRequest{
+			ChunkId: chunkID,
+			User:    id.User,
+			Admin:   id.Admin,
+		})
+		cancel()
+		switch status.Code(err) {
+		case codes.OK:
+			return resp.Checksum, nil
+		case codes.PermissionDenied, codes.FailedPrecondition:
+			return 0, err
+		}
+	}
+	if status.Code(err) == codes.NotFound {
+		return 0, status.Errorf(codes.PermissionDenied, "Chunk %s does not belong to a pending upload of %s", chunkID, id.User)
+	}
+	return 0, status.Errorf(codes.Unavailable, "Failed to check write of chunk %s: %v", chunkID, err)
+}
+
+// remember caches a verified identity, dropping expired entries as it goes
+func (v *tokenVerifier) remember(hash string, id *auth.Identity) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	for cachedHash, cached := range v.cache {
+		if now.After(cached.expires) {
+			delete(v.cache, cachedHash)
+		}
+	}
+	v.cache[hash] = cachedIdentity{id: id, expires: now.Add(tokenCacheTTL)}
+}
+
+// client retrieves or creates a MetadataServiceClient for a metadata node
+func (v *tokenVerifier) client(address string) (metadataPb.MetadataServiceClient, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if client, exists := v.clients[address]; exists {
+		return client, nil
+	}
+
+	conn, err := grpc.Dial(address, clusterDialOptions(v.clusterToken)...)
+	if err != nil {
+		return nil, err
+	}
+
+	client := metadataPb.NewMetadataServiceClient(conn)
+	v.clients[address] = client
+	return client, nil
+}
@@ -0,0 +1,109 @@
+// storage/auth_test.go
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"dfs/auth"
+	metadataPb "dfs/proto/metadata"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testHandle  = "v1-0f1e2d3c4b5a49788a6b5c4d3e2f1a0b"
+	otherHandle = "v1-1a2b3c4d5e6f4a0b9c1d2e3f4a5b6c7d"
+)
+
+// pendingChunk is a chunk of a pending upload known to fakeMetadata
+type pendingChunk struct {
+	owner    string
+	checksum uint32
+}
+
+// fakeMetadata answers CheckChunkWrite like the metadata service would for
+// the given pending chunks
+type fakeMetadata struct {
+	metadataPb.MetadataServiceClient
+	chunks map[string]pendingChunk
+}
+
+func (f *fakeMetadata) CheckChunkWrite(ctx context.Context, in *metadataPb.CheckChunkWriteRequest, opts ...grpc.CallOption) (*metadataPb.CheckChunkWriteResponse, error) {
+	chunk, exists := f.chunks[in.ChunkId]
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "Chunk %s does not belong to a pending upload", in.ChunkId)
+	}
+	if !in.Admin && chunk.owner != in.User {
+		return nil, status.Errorf(codes.PermissionDenied, "Upload belongs to another user")
+	}
+	return &metadataPb.CheckChunkWriteResponse{Checksum: chunk.checksum}, nil
+}
+
+// newAuthTestServer returns a server whose verifier asks a fake metadata
+// node about the given pending chunks
+func newAuthTestServer(t *testing.T, chunks map[string]pendingChunk) *server {
+	s := NewServer(t.TempDir(), nil)
+	s.verifier = newTokenVerifier("cluster", []string{"meta1"})
+	s.verifier.clients["meta1"] = &fakeMetadata{chunks: chunks}
+	return s
+}
+
+func TestAuthorizeStore(t *testing.T) {
+	alice := &auth.Identity{User: "alice"}
+	checksum := func(c uint32) *uint32 { return &c }
+
+	tests := []struct {
+		name     string
+		id       *auth.Identity // nil when authentication is disabled
+		chunkID  string
+		checksum *uint32
+		existing *uint32 // Checksum of the chunk already stored, if any
+		code     codes.Code
+		stored   bool
+	}{
+		{name: "own pending chunk", id: alice, chunkID: testHandle, checksum: checksum(7)},
+		{name: "checksum differs from the upload", id: alice, chunkID: testHandle, checksum: checksum(8), code: codes.PermissionDenied},
+		{name: "no checksum", id: alice, chunkID: testHandle, code: codes.InvalidArgument},
+		{name: "chunk of another user's upload", id: &auth.Identity{User: "bob"}, chunkID: testHandle, checksum: checksum(7), code: codes.PermissionDenied},
+		{name: "chunk of no pending upload", id: alice, chunkID: otherHandle, checksum: checksum(7), code: codes.PermissionDenied},
+		{name: "admin stores another user's chunk", id: &auth.Identity{User: "carol", Admin: true}, chunkID: testHandle, checksum: checksum(7)},
+		{name: "retry of a stored chunk", id: alice, chunkID: testHandle, checksum: checksum(7), existing: checksum(7), stored: true},
+		{name: "replacing a stored chunk", id: alice, chunkID: testHandle, checksum: checksum(7), existing: checksum(9), code: codes.PermissionDenied},
+		{name: "legacy chunk ID", id: alice, chunkID: "file_0", checksum: checksum(7), code: codes.PermissionDenied},
+		{name: "cluster node", id: auth.Root, chunkID: otherHandle},
+		{name: "authentication disabled", chunkID: otherHandle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newAuthTestServer(t, map[string]pendingChunk{
+				testHandle: {owner: "alice", checksum: 7},
+			})
+			if tt.existing != nil {
+				if _, err := s.writeChunk(tt.chunkID, strings.NewReader("data"), nil); err != nil {
+					t.Fatalf("write chunk: %v", err)
+				}
+				if err := s.writeChecksum(tt.chunkID, *tt.existing); err != nil {
+					t.Fatalf("write checksum: %v", err)
+				}
+			}
+
+			ctx := context.Background()
+			if tt.id != nil {
+				ctx = auth.NewContext(ctx, tt.id)
+			}
+			stored, err := s.authorizeStore(ctx, tt.chunkID, tt.checksum)
+			if status.Code(err) != tt.code {
+				t.Fatalf("authorizeStore error = %v, want code %v", err, tt.code)
+			}
+			if stored != tt.stored {
+				t.Errorf("stored = %v, want %v", stored, tt.stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// storage/checksum.go
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// checksumSuffix marks the sidecar file holding a chunk's checksum
+const checksumSuffix = ".crc"
+
+// castagnoli is the CRC32C table used for chunk checksums
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// errCorrupt reports chunk data that does not match its checksum
+type errCorrupt struct {
+	chunkID  string
+	expected uint32
+	actual   uint32
+}
+
+func (e *errCorrupt) Error() string {
+	return fmt.Sprintf("chunk %s checksum mismatch: expected %08x, got %08x", e.chunkID, e.expected, e.actual)
+}
+
+// checksumPath returns the path of the sidecar file for a chunk
+func (s *server) checksumPath(chunkID string) string {
+	return s.chunkPath(chunkID) + checksumSuffix
+}
+
+// writeChecksum records the checksum of a chunk next to it
+func (s *server) writeChecksum(chunkID string, checksum uint32) error {
+	data := []byte(fmt.Sprintf("%08x\n", checksum))
+	return s.writeFileAtomic(s.checksumPath(chunkID), data)
+}
+
+// readChecksum returns the recorded checksum of a chunk. ok is false for
+// chunks stored before checksums were recorded.
+func (s *server) readChecksum(chunkID string) (checksum uint32, ok bool, err error) {
+	data, err := os.ReadFile(s.checksumPath(chunkID))
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read checksum: %v", err)
+	}
+
+	value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 16, 32)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to parse checksum: %v", err)
+	}
+	return uint32(value), true, nil
+}
+
+// verifyChecksum compares the checksum computed over a chunk's data with the
+// one recorded when it was stored
+func (s *server) verifyChecksum(chunkID string, actual uint32) error {
+	expected, ok, err := s.readChecksum(chunkID)
+	if err != nil {
+		return err
+	}
+	if ok && expected != actual {
+		return &errCorrupt{chunkID: chunkID, expected: expected, actual: actual}
+	}
+	return nil
+}
+
+// chunkStatus converts an error from storing or reading a chunk into a gRPC
+// status, reporting corrupt data as DataLoss
+func chunkStatus(err error, msg string) error {
+	var corrupt *errCorrupt
+	if errors.As(err, &corrupt) {
+		return status.Errorf(codes.DataLoss, "%s: %v", msg, err)
+	}
+	return status.Errorf(codes.Internal, "%s: %v", msg, err)
+}
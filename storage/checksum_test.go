@@ -0,0 +1,115 @@
+// storage/checksum_test.go
+
+package main
+
+import (
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWriteChunkChecksum(t *testing.T) {
+	data := "chunk data"
+	sum := crc32.Checksum([]byte(data), castagnoli)
+	wrong := sum + 1
+
+	tests := []struct {
+		name     string
+		expected *uint32
+		corrupt  bool
+	}{
+		{name: "no expected checksum"},
+		{name: "matching checksum", expected: &sum},
+		{name: "mismatching checksum", expected: &wrong, corrupt: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(t.TempDir(), nil)
+
+			_, err := s.writeChunk(testHandle, strings.NewReader(data), tt.expected)
+			var corrupt *errCorrupt
+			if errors.As(err, &corrupt) != tt.corrupt {
+				t.Fatalf("write chunk error = %v, want corrupt %v", err, tt.corrupt)
+			}
+			if !tt.corrupt && err != nil {
+				t.Fatalf("write chunk: %v", err)
+			}
+
+			if tt.corrupt {
+				// Neither the chunk nor a sidecar is left behind
+				if _, err := os.Stat(s.chunkPath(testHandle)); !os.IsNotExist(err) {
+					t.Errorf("corrupt chunk was stored")
+				}
+				if _, ok, _ := s.readChecksum(testHandle); ok {
+					t.Errorf("checksum of a corrupt chunk was recorded")
+				}
+				return
+			}
+
+			checksum, ok, err := s.readChecksum(testHandle)
+			if err != nil || !ok || checksum != sum {
+				t.Errorf("readChecksum = %08x, %v, %v, want %08x", checksum, ok, err, sum)
+			}
+		})
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	tests := []struct {
+		name    string
+		sidecar *string // Contents of the checksum file, if any
+		actual  uint32
+		corrupt bool
+		err     bool
+	}{
+		{name: "matching checksum", sidecar: strPtr("0000002a\n"), actual: 42},
+		{name: "mismatching checksum", sidecar: strPtr("0000002a\n"), actual: 43, corrupt: true},
+		{name: "chunk stored before checksums", actual: 43},
+		{name: "unreadable sidecar", sidecar: strPtr("not hex\n"), actual: 42, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(t.TempDir(), nil)
+			path := s.checksumPath(testHandle)
+			if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+			if tt.sidecar != nil {
+				if err := os.WriteFile(path, []byte(*tt.sidecar), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := s.verifyChecksum(testHandle, tt.actual)
+			var corrupt *errCorrupt
+			if errors.As(err, &corrupt) != tt.corrupt {
+				t.Errorf("verifyChecksum = %v, want corrupt %v", err, tt.corrupt)
+			}
+			if (err != nil && !tt.corrupt) != tt.err {
+				t.Errorf("verifyChecksum = %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestChunkStatus(t *testing.T) {
+	corrupt := &errCorrupt{chunkID: testHandle, expected: 1, actual: 2}
+	if code := status.Code(chunkStatus(corrupt, "Failed")); code != codes.DataLoss {
+		t.Errorf("corrupt chunk code = %v, want %v", code, codes.DataLoss)
+	}
+	if code := status.Code(chunkStatus(errors.New("disk full"), "Failed")); code != codes.Internal {
+		t.Errorf("other error code = %v, want %v", code, codes.Internal)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,127 @@
+// storage/chunkfile.go
+
+package main
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// tempDirName is the subdirectory chunks are written to before being renamed
+// into place. It lives inside the storage directory so the rename stays on
+// one filesystem.
+const tempDirName = "tmp"
+
+// cleanupTempFiles removes chunks left half written by a crash
+func (s *server) cleanupTempFiles() error {
+	tempDir := filepath.Join(s.storageDir, tempDirName)
+	entries, err := os.ReadDir(tempDir)
+	if os.IsNotExist(err) {
+		return os.MkdirAll(tempDir, os.ModePerm)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(tempDir, entry.Name())); err != nil {
+			return err
+		}
+		log.Printf("Removed incomplete chunk file %s", entry.Name())
+	}
+	return nil
+}
+
+// writeChunk streams a chunk from r to disk and returns the number of bytes
+// written. If expected is set the data must match that checksum. The checksum
+// of the stored data is recorded next to it so later reads can be verified.
+//
+// The chunk is written to a temporary file, synced and then renamed into
+// place, so a crash never leaves a truncated chunk under its final name.
+func (s *server) writeChunk(chunkID string, r io.Reader, expected *uint32) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Join(s.storageDir, tempDirName), "chunk-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create chunk file: %v", err)
+	}
+	tmpPath := tmp.Name()
+
+	hash := crc32.New(castagnoli)
+	n, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && expected != nil && hash.Sum32() != *expected {
+		err = &errCorrupt{chunkID: chunkID, expected: *expected, actual: hash.Sum32()}
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+
+	// Move the chunk into place before recording its checksum; a crash in
+	// between leaves a chunk that is simply not verified
+	chunkPath := s.chunkPath(chunkID)
+	if err := os.MkdirAll(filepath.Dir(chunkPath), os.ModePerm); err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to create chunk directory: %v", err)
+	}
+	if err := os.Rename(tmpPath, chunkPath); err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to rename chunk into place: %v", err)
+	}
+	if err := s.writeChecksum(chunkID, hash.Sum32()); err != nil {
+		return 0, err
+	}
+	if err := syncDir(filepath.Dir(chunkPath)); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// writeFileAtomic replaces the file at path, which must be inside the storage
+// directory, with data via a synced temporary file
+func (s *server) writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Join(s.storageDir, tempDirName), "file-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	tmpPath := tmp.Name()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write %s: %v", filepath.Base(path), err)
+	}
+
+	return nil
+}
+
+// syncDir flushes a directory so renames into it survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open storage directory: %v", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync storage directory: %v", err)
+	}
+	return nil
+}
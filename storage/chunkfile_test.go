@@ -0,0 +1,67 @@
+// storage/chunkfile_test.go
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCleanupTempFilesOnStart(t *testing.T) {
+	dir := t.TempDir()
+	s := NewServer(dir, nil)
+	if _, err := s.writeChunk(testHandle, strings.NewReader("stored"), nil); err != nil {
+		t.Fatalf("write chunk: %v", err)
+	}
+
+	// Leave files behind as a crash in the middle of writes would
+	tempDir := filepath.Join(dir, tempDirName)
+	for _, name := range []string{"chunk-123", "file-456"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("partial"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s = NewServer(dir, nil)
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("read temp directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("%d files left in the temp directory", len(entries))
+	}
+	if data, err := os.ReadFile(s.chunkPath(testHandle)); err != nil || string(data) != "stored" {
+		t.Errorf("stored chunk = %q, %v", data, err)
+	}
+}
+
+func TestWriteChunkFailureKeepsOldData(t *testing.T) {
+	s := NewServer(t.TempDir(), nil)
+	if _, err := s.writeChunk(testHandle, strings.NewReader("first"), nil); err != nil {
+		t.Fatalf("write chunk: %v", err)
+	}
+
+	// A write that breaks off midway neither replaces the chunk nor leaves a
+	// temporary file behind
+	errBroken := errors.New("connection lost")
+	r := io.MultiReader(strings.NewReader("sec"), iotest.ErrReader(errBroken))
+	if _, err := s.writeChunk(testHandle, r, nil); !errors.Is(err, errBroken) {
+		t.Fatalf("write chunk error = %v, want %v", err, errBroken)
+	}
+
+	if data, err := os.ReadFile(s.chunkPath(testHandle)); err != nil || string(data) != "first" {
+		t.Errorf("chunk = %q, %v, want %q", data, err, "first")
+	}
+	entries, err := os.ReadDir(filepath.Join(s.storageDir, tempDirName))
+	if err != nil {
+		t.Fatalf("read temp directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("%d files left in the temp directory", len(entries))
+	}
+}
@@ -0,0 +1,84 @@
+// storage/chunkid.go
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// handlePattern matches the chunk handles handed out by the metadata
+	// service: a format version followed by 128 random bits in lowercase
+	// hex. Only version 1 exists so far.
+	handlePattern = regexp.MustCompile(`^v1-[0-9a-f]{32}$`)
+
+	// legacyPattern matches chunk IDs from before handles, which were the
+	// file name followed by the chunk index. They are kept readable but
+	// must not contain path separators.
+	legacyPattern = regexp.MustCompile(`^[^/\\\x00]+_[0-9]+$`)
+)
+
+// isHandle reports whether chunkID is a chunk handle rather than a legacy ID
+func isHandle(chunkID string) bool {
+	return handlePattern.MatchString(chunkID)
+}
+
+// validateChunkID rejects chunk IDs that could name a file outside the chunk
+// layout, such as "../x" or a checksum sidecar
+func validateChunkID(chunkID string) error {
+	if isHandle(chunkID) || (legacyPattern.MatchString(chunkID) && len(chunkID) <= 255) {
+		return nil
+	}
+	return status.Errorf(codes.InvalidArgument, "Invalid chunk ID %q", chunkID)
+}
+
+// chunkPath returns where a chunk is stored. Handles are spread over two
+// levels of subdirectories named after the first four hex digits of their
+// random part, so no directory grows too large; legacy chunks stay in the
+// storage directory itself.
+func (s *server) chunkPath(chunkID string) string {
+	if isHandle(chunkID) {
+		random := chunkID[len("v1-"):]
+		return filepath.Join(s.storageDir, random[0:2], random[2:4], chunkID)
+	}
+	return filepath.Join(s.storageDir, chunkID)
+}
+
+// listChunks returns the IDs of every chunk stored on this node
+func (s *server) listChunks() ([]string, error) {
+	var chunkIDs []string
+
+	// Legacy chunks sit at the top level, next to the fan-out directories
+	entries, err := os.ReadDir(s.storageDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && legacyPattern.MatchString(entry.Name()) {
+			chunkIDs = append(chunkIDs, entry.Name())
+		}
+	}
+
+	dirs, err := filepath.Glob(filepath.Join(s.storageDir, "[0-9a-f][0-9a-f]", "[0-9a-f][0-9a-f]"))
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && isHandle(entry.Name()) {
+				chunkIDs = append(chunkIDs, entry.Name())
+			}
+		}
+	}
+
+	return chunkIDs, nil
+}
@@ -0,0 +1,73 @@
+// storage/chunkid_test.go
+
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidateChunkID(t *testing.T) {
+	tests := []struct {
+		chunkID string
+		code    codes.Code
+	}{
+		{chunkID: testHandle},
+		{chunkID: "report.txt_0"},
+		{chunkID: "report_v2.txt_12"},
+		{chunkID: "", code: codes.InvalidArgument},
+		{chunkID: "../x", code: codes.InvalidArgument},
+		{chunkID: "../x_0", code: codes.InvalidArgument},
+		{chunkID: `..\x_0`, code: codes.InvalidArgument},
+		{chunkID: "a/b_0", code: codes.InvalidArgument},
+		{chunkID: "file", code: codes.InvalidArgument},
+		{chunkID: testHandle + ".crc", code: codes.InvalidArgument},
+		{chunkID: "v2-0f1e2d3c4b5a49788a6b5c4d3e2f1a0b", code: codes.InvalidArgument},
+		{chunkID: "v1-0F1E2D3C4B5A49788A6B5C4D3E2F1A0B", code: codes.InvalidArgument},
+		{chunkID: "v1-0f1e2d3c", code: codes.InvalidArgument},
+		{chunkID: strings.Repeat("a", 254) + "_0", code: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.chunkID, func(t *testing.T) {
+			if err := validateChunkID(tt.chunkID); status.Code(err) != tt.code {
+				t.Errorf("validateChunkID(%q) = %v, want code %v", tt.chunkID, err, tt.code)
+			}
+		})
+	}
+}
+
+func TestChunkLayout(t *testing.T) {
+	s := NewServer(t.TempDir(), nil)
+
+	if got, want := s.chunkPath(testHandle), filepath.Join(s.storageDir, "0f", "1e", testHandle); got != want {
+		t.Errorf("chunkPath(%q) = %q, want %q", testHandle, got, want)
+	}
+	if got, want := s.chunkPath("file_0"), filepath.Join(s.storageDir, "file_0"); got != want {
+		t.Errorf("chunkPath(%q) = %q, want %q", "file_0", got, want)
+	}
+
+	for _, chunkID := range []string{testHandle, otherHandle, "file_0"} {
+		if _, err := s.writeChunk(chunkID, strings.NewReader("data"), nil); err != nil {
+			t.Fatalf("write chunk %s: %v", chunkID, err)
+		}
+		if err := s.writeChecksum(chunkID, 1); err != nil {
+			t.Fatalf("write checksum %s: %v", chunkID, err)
+		}
+	}
+
+	chunkIDs, err := s.listChunks()
+	if err != nil {
+		t.Fatalf("list chunks: %v", err)
+	}
+	sort.Strings(chunkIDs)
+	want := []string{"file_0", testHandle, otherHandle}
+	if strings.Join(chunkIDs, ",") != strings.Join(want, ",") {
+		t.Errorf("listChunks = %v, want %v", chunkIDs, want)
+	}
+}
@@ -0,0 +1,11 @@
+// storage/diskstats_other.go
+
+//go:build !unix
+
+package main
+
+// diskUsage is not supported on this platform; the node reports no capacity
+// information and the metadata service places chunks on it as if it were empty
+func diskUsage(dir string) (int64, int64, error) {
+	return 0, 0, nil
+}
@@ -0,0 +1,16 @@
+// storage/diskstats_unix.go
+
+//go:build unix
+
+package main
+
+import "syscall"
+
+// diskUsage returns the capacity and free space of the file system holding dir
+func diskUsage(dir string) (int64, int64, error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fs); err != nil {
+		return 0, 0, err
+	}
+	return int64(fs.Blocks) * int64(fs.Bsize), int64(fs.Bavail) * int64(fs.Bsize), nil
+}
@@ -0,0 +1,103 @@
+// storage/heartbeat.go
+
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	metadataPb "dfs/proto/metadata"
+
+	"google.golang.org/grpc"
+)
+
+// RunHeartbeats registers this node with every metadata node in metadataAddrs
+// and then keeps reporting its stats to them. address is the address clients
+// reach this node at.
+func (s *server) RunHeartbeats(address string, metadataAddrs []string) {
+	for _, metadataAddr := range metadataAddrs {
+		go s.heartbeatLoop(address, metadataAddr)
+	}
+}
+
+// heartbeatLoop registers with a single metadata node and sends it heartbeats,
+// registering again whenever the metadata node has forgotten about us
+func (s *server) heartbeatLoop(address, metadataAddr string) {
+	conn, err := grpc.Dial(metadataAddr, s.dialOptions...)
+	if err != nil {
+		log.Printf("Failed to connect to metadata node at %s: %v", metadataAddr, err)
+		return
+	}
+	defer conn.Close()
+	client := metadataPb.NewMetadataServiceClient(conn)
+
+	interval := 5 * time.Second
+	retryDelay := time.Second // Wait between registration attempts
+	registered := false
+
+	for {
+		if !registered {
+			resp, err := s.register(client, address, interval)
+			if err != nil {
+				log.Printf("Failed to register with metadata node at %s: %v", metadataAddr, err)
+				time.Sleep(retryDelay)
+				continue
+			}
+
+			registered = true
+			if resp.HeartbeatIntervalMs > 0 {
+				interval = time.Duration(resp.HeartbeatIntervalMs) * time.Millisecond
+			}
+			log.Printf("Registered with metadata node at %s", metadataAddr)
+		} else {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			resp, err := client.Heartbeat(ctx, &metadataPb.HeartbeatRequest{
+				Address: address,
+				Stats:   s.collectStats(),
+			})
+			cancel()
+
+			if err != nil {
+				log.Printf("Failed to send heartbeat to metadata node at %s: %v", metadataAddr, err)
+			} else if resp.Reregister {
+				// The metadata node restarted and lost its registry
+				registered = false
+				continue
+			}
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// register announces this node to a metadata node
+func (s *server) register(client metadataPb.MetadataServiceClient, address string, timeout time.Duration) (*metadataPb.RegisterNodeResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return client.RegisterNode(ctx, &metadataPb.RegisterNodeRequest{
+		Address: address,
+		Stats:   s.collectStats(),
+	})
+}
+
+// collectStats reports the node's disk capacity, free space and chunk count
+func (s *server) collectStats() *metadataPb.NodeStats {
+	stats := &metadataPb.NodeStats{}
+
+	capacity, free, err := diskUsage(s.storageDir)
+	if err != nil {
+		log.Printf("Failed to read disk usage: %v", err)
+	}
+	stats.CapacityBytes = capacity
+	stats.FreeBytes = free
+
+	chunkIDs, err := s.listChunks()
+	if err != nil {
+		log.Printf("Failed to count chunks: %v", err)
+	}
+	stats.ChunkCount = int64(len(chunkIDs))
+
+	return stats
+}
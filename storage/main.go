@@ -6,7 +6,10 @@ import (
 	"flag"
 	"log"
 	"net"
+	"strings"
+	"time"
 
+	"dfs/auth"
 	pb "dfs/proto/storage"
 
 	"google.golang.org/grpc"
@@ -16,23 +19,68 @@ func main() {
 	// Command-line flags
 	port := flag.String("port", ":50052", "Port to listen on")
 	storageDir := flag.String("storage_dir", "storage_data", "Directory to store chunk data")
+	metadataAddrs := flag.String("metadata", "localhost:50051", "Comma-separated list of metadata service addresses to register with")
+	advertiseAddr := flag.String("advertise_addr", "", "Address clients reach this node at (defaults to localhost plus -port)")
+	scrubInterval := flag.Duration("scrub_interval", time.Hour, "Time between passes of the chunk scrubber (0 disables it)")
+	scrubRate := flag.Int64("scrub_rate_mb", 10, "Maximum rate in MB per second at which the scrubber reads chunks")
+	clusterTokenFile := flag.String("cluster_token_file", "", "File holding the token cluster nodes authenticate to each other with; enables authentication (disabled if empty)")
 	flag.Parse()
 
+	if *scrubRate <= 0 {
+		log.Fatalf("Invalid scrub rate: %d", *scrubRate)
+	}
+
+	// Identify this node by the address clients reach it at
+	selfAddr := *advertiseAddr
+	if selfAddr == "" {
+		selfAddr = "localhost" + *port
+	}
+
 	// Listen on the specified port
 	lis, err := net.Listen("tcp", *port)
 	if err != nil {
 		log.Fatalf("Failed to listen on port %s: %v", *port, err)
 	}
 
+	var metadataNodes []string
+	for _, addr := range strings.Split(*metadataAddrs, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			metadataNodes = append(metadataNodes, addr)
+		}
+	}
+
+	// Callers must present a token once the cluster has one
+	var clusterToken string
+	if *clusterTokenFile != "" {
+		clusterToken, err = auth.ReadTokenFile(*clusterTokenFile)
+		if err != nil {
+			log.Fatalf("Failed to read cluster token: %v", err)
+		}
+	}
+
 	// Create a new Storage server
-	srv := NewServer(*storageDir)
+	srv := NewServer(*storageDir, clusterDialOptions(clusterToken))
 
-	// Create a new gRPC server
-	grpcServer := grpc.NewServer()
+	// Create a new gRPC server, authenticating every call if enabled
+	var serverOpts []grpc.ServerOption
+	if clusterToken != "" {
+		srv.verifier = newTokenVerifier(clusterToken, metadataNodes)
+		serverOpts = auth.ServerOptions(srv.verifier.authenticate, authRules)
+		log.Printf("Authentication is enabled")
+	}
+	grpcServer := grpc.NewServer(serverOpts...)
 
 	// Register the StorageService with the gRPC server
 	pb.RegisterStorageServiceServer(grpcServer, srv)
 
+	// Register with the metadata service and keep sending heartbeats
+	srv.RunHeartbeats(selfAddr, metadataNodes)
+
+	// Periodically verify stored chunks against their checksums
+	if *scrubInterval > 0 {
+		go srv.RunScrubber(*scrubInterval, *scrubRate*1024*1024, selfAddr, metadataNodes)
+	}
+
 	log.Printf("Storage Service is running on port %s", *port)
 
 	// Start serving
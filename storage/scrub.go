@@ -0,0 +1,196 @@
+// storage/scrub.go
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	metadataPb "dfs/proto/metadata"
+
+	"google.golang.org/grpc"
+)
+
+const (
+	quarantineDirName = "quarantine"    // Subdirectory corrupt chunks are moved into
+	reportTimeout     = 5 * time.Second // Bound on a single corruption report
+	reportRetries     = 3               // Attempts to report a corrupt chunk within one pass
+	reportRetryDelay  = 2 * time.Second // Wait between report attempts
+)
+
+// throttle limits how fast the scrubber reads from disk so it does not
+// starve client reads and writes
+type throttle struct {
+	rate  int64 // Bytes per second
+	start time.Time
+	bytes int64
+}
+
+// wait records n bytes read and sleeps until reading them fits the rate
+func (t *throttle) wait(n int) {
+	t.bytes += int64(n)
+	due := time.Duration(float64(t.bytes) / float64(t.rate) * float64(time.Second))
+	if elapsed := time.Since(t.start); elapsed < due {
+		time.Sleep(due - elapsed)
+	}
+}
+
+// RunScrubber verifies every chunk against its recorded checksum once per
+// interval, reading at most rate bytes per second. Corrupt chunks are moved to
+// the quarantine directory and reported to the metadata service, which
+// re-replicates them from a healthy copy. address is the address clients reach
+// this node at.
+func (s *server) RunScrubber(interval time.Duration, rate int64, address string, metadataAddrs []string) {
+	// Reports that could not be delivered are retried on the next pass
+	pending := make(map[string]bool)
+
+	for {
+		for chunkID := range pending {
+			if err := s.reportCorrupt(metadataAddrs, address, chunkID); err == nil {
+				delete(pending, chunkID)
+			}
+		}
+
+		for _, chunkID := range s.scrubPass(rate) {
+			if err := s.reportCorrupt(metadataAddrs, address, chunkID); err != nil {
+				log.Printf("Scrub: failed to report corrupt chunk %s: %v", chunkID, err)
+				pending[chunkID] = true
+			}
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// scrubPass verifies every chunk in the storage directory once and returns
+// the IDs of the chunks it quarantined
+func (s *server) scrubPass(rate int64) []string {
+	chunkIDs, err := s.listChunks()
+	if err != nil {
+		log.Printf("Scrub: failed to list chunks: %v", err)
+		return nil
+	}
+
+	limit := &throttle{rate: rate, start: time.Now()}
+	var corrupt []string
+	var verified int
+
+	for _, chunkID := range chunkIDs {
+		err := s.scrubChunk(chunkID, limit)
+		var bad *errCorrupt
+		if errors.As(err, &bad) {
+			// A chunk being replaced while we read it looks corrupt, so
+			// check again before giving up on it
+			err = s.scrubChunk(chunkID, limit)
+		}
+		switch {
+		case err == nil:
+			verified++
+		case os.IsNotExist(err):
+			// Deleted while the pass was running
+		case errors.As(err, &bad):
+			log.Printf("Scrub: %v", err)
+			if err := s.quarantine(chunkID); err != nil {
+				log.Printf("Scrub: failed to quarantine chunk %s: %v", chunkID, err)
+				continue
+			}
+			corrupt = append(corrupt, chunkID)
+		default:
+			log.Printf("Scrub: failed to verify chunk %s: %v", chunkID, err)
+		}
+	}
+
+	log.Printf("Scrub: verified %d chunks, quarantined %d", verified, len(corrupt))
+	return corrupt
+}
+
+// scrubChunk reads a chunk at the throttled rate and checks it against its
+// recorded checksum
+func (s *server) scrubChunk(chunkID string, limit *throttle) error {
+	file, err := os.Open(s.chunkPath(chunkID))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := crc32.New(castagnoli)
+	buf := make([]byte, frameSize)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			hash.Write(buf[:n])
+			limit.wait(n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.verifyChecksum(chunkID, hash.Sum32())
+}
+
+// quarantine moves a corrupt chunk and its checksum out of the storage
+// directory so it is no longer served, keeping it for inspection
+func (s *server) quarantine(chunkID string) error {
+	quarantineDir := filepath.Join(s.storageDir, quarantineDirName)
+	if err := os.MkdirAll(quarantineDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	if err := os.Rename(s.chunkPath(chunkID), filepath.Join(quarantineDir, chunkID)); err != nil {
+		return err
+	}
+	if err := os.Rename(s.checksumPath(chunkID), filepath.Join(quarantineDir, chunkID+checksumSuffix)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	log.Printf("Scrub: quarantined chunk %s", chunkID)
+	return nil
+}
+
+// reportCorrupt tells the metadata leader that this node's replica of chunkID
+// is corrupt. Each metadata node is tried in turn, since only the leader
+// accepts the report.
+func (s *server) reportCorrupt(metadataAddrs []string, address, chunkID string) error {
+	err := fmt.Errorf("no metadata nodes configured")
+	for attempt := 0; attempt < reportRetries; attempt++ {
+		for _, metadataAddr := range metadataAddrs {
+			if err = s.sendReport(metadataAddr, address, chunkID); err == nil {
+				log.Printf("Scrub: reported corrupt chunk %s to %s", chunkID, metadataAddr)
+				return nil
+			}
+		}
+		if attempt < reportRetries-1 {
+			time.Sleep(reportRetryDelay)
+		}
+	}
+	return err
+}
+
+// sendReport delivers a corruption report to a single metadata node
+func (s *server) sendReport(metadataAddr, address, chunkID string) error {
+	conn, err := grpc.Dial(metadataAddr, s.dialOptions...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+
+	_, err = metadataPb.NewMetadataServiceClient(conn).ReportCorruptChunk(ctx, &metadataPb.ReportCorruptChunkRequest{
+		Address: address,
+		ChunkId: chunkID,
+	})
+	return err
+}
@@ -0,0 +1,56 @@
+// storage/scrub_test.go
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScrubPass(t *testing.T) {
+	s := NewServer(t.TempDir(), nil)
+
+	// A healthy chunk, a chunk whose data rotted on disk and a chunk stored
+	// before checksums were recorded
+	for _, chunkID := range []string{testHandle, otherHandle, "file_0"} {
+		if _, err := s.writeChunk(chunkID, strings.NewReader("original"), nil); err != nil {
+			t.Fatalf("write chunk %s: %v", chunkID, err)
+		}
+	}
+	if err := os.WriteFile(s.chunkPath(otherHandle), []byte("rotten!!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(s.checksumPath("file_0")); err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := s.scrubPass(1 << 30)
+	if len(corrupt) != 1 || corrupt[0] != otherHandle {
+		t.Fatalf("scrubPass = %v, want [%s]", corrupt, otherHandle)
+	}
+
+	// The corrupt chunk and its checksum moved to the quarantine directory
+	if _, err := os.Stat(s.chunkPath(otherHandle)); !os.IsNotExist(err) {
+		t.Errorf("corrupt chunk is still served")
+	}
+	quarantineDir := filepath.Join(s.storageDir, quarantineDirName)
+	data, err := os.ReadFile(filepath.Join(quarantineDir, otherHandle))
+	if err != nil || string(data) != "rotten!!" {
+		t.Errorf("quarantined chunk = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(quarantineDir, otherHandle+checksumSuffix)); err != nil {
+		t.Errorf("checksum was not quarantined: %v", err)
+	}
+
+	// The other chunks are left alone, and a second pass finds nothing
+	for _, chunkID := range []string{testHandle, "file_0"} {
+		if _, err := os.Stat(s.chunkPath(chunkID)); err != nil {
+			t.Errorf("chunk %s: %v", chunkID, err)
+		}
+	}
+	if corrupt := s.scrubPass(1 << 30); len(corrupt) != 0 {
+		t.Errorf("second scrubPass = %v, want none", corrupt)
+	}
+}
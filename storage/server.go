@@ -3,14 +3,19 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
-	"path/filepath"
+	"sync"
 
 	pb "dfs/proto/storage"
 
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -18,50 +23,205 @@ import (
 // server implements the StorageServiceServer interface
 type server struct {
 	pb.UnimplementedStorageServiceServer
-	storageDir string
+	storageDir  string
+	mu          sync.Mutex
+	peers       map[string]pb.StorageServiceClient // Other storage nodes, for replication
+	dialOptions []grpc.DialOption                  // For connecting to metadata and storage nodes
+	verifier    *tokenVerifier                     // Set when authentication is enabled
 }
 
-// NewServer initializes a new Storage server
-func NewServer(storageDir string) *server {
+// NewServer initializes a new Storage server that connects to other nodes
+// with dialOptions
+func NewServer(storageDir string, dialOptions []grpc.DialOption) *server {
 	// Ensure the storage directory exists
 	if err := os.MkdirAll(storageDir, os.ModePerm); err != nil {
 		log.Fatalf("Failed to create storage directory: %v", err)
 	}
 
-	return &server{
-		storageDir: storageDir,
+	s := &server{
+		storageDir:  storageDir,
+		peers:       make(map[string]pb.StorageServiceClient),
+		dialOptions: dialOptions,
 	}
+
+	// Discard chunks that were being written when the node last stopped
+	if err := s.cleanupTempFiles(); err != nil {
+		log.Fatalf("Failed to clean up temporary files: %v", err)
+	}
+
+	return s
 }
 
 // StoreChunk saves a chunk of data to the storage node
 func (s *server) StoreChunk(ctx context.Context, req *pb.StoreChunkRequest) (*pb.StoreChunkResponse, error) {
-	chunkPath := filepath.Join(s.storageDir, req.ChunkId)
-	err := ioutil.WriteFile(chunkPath, req.Data, 0644)
+	if err := validateChunkID(req.ChunkId); err != nil {
+		return nil, err
+	}
+	stored, err := s.authorizeStore(ctx, req.ChunkId, req.Checksum)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Failed to store chunk: %v", err)
+		return nil, err
 	}
 
-	log.Printf("Stored chunk %s", req.ChunkId)
+	if !stored {
+		if _, err := s.writeChunk(req.ChunkId, bytes.NewReader(req.Data), req.Checksum); err != nil {
+			return nil, chunkStatus(err, "Failed to store chunk")
+		}
+		log.Printf("Stored chunk %s", req.ChunkId)
+	}
 
 	return &pb.StoreChunkResponse{
 		Success: true,
 	}, nil
 }
 
-// RetrieveChunk retrieves a chunk of data from the storage node
+// RetrieveChunk retrieves a chunk of data, or a byte range of it, from the
+// storage node
 func (s *server) RetrieveChunk(ctx context.Context, req *pb.RetrieveChunkRequest) (*pb.RetrieveChunkResponse, error) {
-	chunkPath := filepath.Join(s.storageDir, req.ChunkId)
-	data, err := ioutil.ReadFile(chunkPath)
+	file, reader, whole, err := s.openChunk(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := ioutil.ReadAll(reader)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, status.Errorf(codes.NotFound, "Chunk %s not found", req.ChunkId)
-		}
 		return nil, status.Errorf(codes.Internal, "Failed to read chunk: %v", err)
 	}
 
+	// Never hand out data that no longer matches its checksum
+	if whole {
+		if err := s.verifyChecksum(req.ChunkId, crc32.Checksum(data, castagnoli)); err != nil {
+			log.Printf("Refusing to serve chunk %s: %v", req.ChunkId, err)
+			return nil, chunkStatus(err, "Failed to verify chunk")
+		}
+	}
+
 	log.Printf("Retrieved chunk %s", req.ChunkId)
 
 	return &pb.RetrieveChunkResponse{
 		Data: data,
 	}, nil
 }
+
+// StatChunk reports whether a chunk is stored along with its size and
+// recorded checksum
+func (s *server) StatChunk(ctx context.Context, req *pb.StatChunkRequest) (*pb.StatChunkResponse, error) {
+	if err := validateChunkID(req.ChunkId); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(s.chunkPath(req.ChunkId))
+	if os.IsNotExist(err) {
+		return &pb.StatChunkResponse{Exists: false}, nil
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to stat chunk: %v", err)
+	}
+
+	resp := &pb.StatChunkResponse{
+		Exists: true,
+		Size:   info.Size(),
+	}
+
+	checksum, ok, err := s.readChecksum(req.ChunkId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to read checksum: %v", err)
+	}
+	if ok {
+		resp.Checksum = &checksum
+	}
+
+	return resp, nil
+}
+
+// DeleteChunk removes a chunk from the storage node. Deleting a chunk that
+// does not exist succeeds, so the metadata service can safely retry.
+func (s *server) DeleteChunk(ctx context.Context, req *pb.DeleteChunkRequest) (*pb.DeleteChunkResponse, error) {
+	if err := validateChunkID(req.ChunkId); err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(s.chunkPath(req.ChunkId)); err != nil && !os.IsNotExist(err) {
+		return nil, status.Errorf(codes.Internal, "Failed to delete chunk: %v", err)
+	}
+	if err := os.Remove(s.checksumPath(req.ChunkId)); err != nil && !os.IsNotExist(err) {
+		return nil, status.Errorf(codes.Internal, "Failed to delete chunk checksum: %v", err)
+	}
+
+	log.Printf("Deleted chunk %s", req.ChunkId)
+
+	return &pb.DeleteChunkResponse{
+		Success: true,
+	}, nil
+}
+
+// ReplicateChunk copies a chunk from another storage node onto this one
+func (s *server) ReplicateChunk(ctx context.Context, req *pb.ReplicateChunkRequest) (*pb.ReplicateChunkResponse, error) {
+	if err := validateChunkID(req.ChunkId); err != nil {
+		return nil, err
+	}
+	if req.Source == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Source storage node is required")
+	}
+
+	// Failures of the source are reported as FailedPrecondition, or DataLoss
+	// if its copy is corrupt, so the caller can tell them from failures of
+	// this node and pick another source rather than another target
+	source, err := s.peerClient(req.Source)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "Failed to connect to source %s: %v", req.Source, err)
+	}
+
+	// Stream the chunk from the source replica straight to disk
+	stream, err := source.RetrieveChunkStream(ctx, &pb.RetrieveChunkRequest{
+		ChunkId: req.ChunkId,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "Failed to fetch chunk %s from %s: %v", req.ChunkId, req.Source, err)
+	}
+
+	var recvErr error
+	reader := &frameReader{
+		recv: func() ([]byte, error) {
+			frame, err := stream.Recv()
+			if err != nil {
+				if err != io.EOF {
+					recvErr = err
+				}
+				return nil, err
+			}
+			return frame.Data, nil
+		},
+	}
+	if _, err := s.writeChunk(req.ChunkId, reader, req.Checksum); err != nil {
+		if recvErr != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "Failed to fetch chunk %s from %s: %v", req.ChunkId, req.Source, recvErr)
+		}
+		return nil, chunkStatus(err, fmt.Sprintf("Failed to copy chunk %s from %s", req.ChunkId, req.Source))
+	}
+
+	log.Printf("Replicated chunk %s from %s", req.ChunkId, req.Source)
+
+	return &pb.ReplicateChunkResponse{
+		Success: true,
+	}, nil
+}
+
+// peerClient retrieves or creates a StorageServiceClient for another storage node
+func (s *server) peerClient(address string) (pb.StorageServiceClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if client, exists := s.peers[address]; exists {
+		return client, nil
+	}
+
+	conn, err := grpc.Dial(address, s.dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	client := pb.NewStorageServiceClient(conn)
+	s.peers[address] = client
+	return client, nil
+}
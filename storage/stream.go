@@ -0,0 +1,177 @@
+// storage/stream.go
+
+package main
+
+import (
+	"context"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+
+	pb "dfs/proto/storage"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// frameSize is the amount of chunk data sent in a single stream frame
+const frameSize = 1024 * 1024 // 1MB
+
+// frameReader adapts a stream of data frames to an io.Reader
+type frameReader struct {
+	recv func() ([]byte, error)
+	buf  []byte
+}
+
+func (r *frameReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		data, err := r.recv()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// StoreChunkStream saves a chunk sent as a stream of frames, writing each
+// frame to disk as it arrives
+func (s *server) StoreChunkStream(stream pb.StorageService_StoreChunkStreamServer) error {
+	// The first frame names the chunk
+	first, err := stream.Recv()
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "Failed to receive first frame: %v", err)
+	}
+	if first.ChunkId == "" {
+		return status.Errorf(codes.InvalidArgument, "First frame must carry the chunk ID")
+	}
+	if err := validateChunkID(first.ChunkId); err != nil {
+		return err
+	}
+	stored, err := s.authorizeStore(stream.Context(), first.ChunkId, first.Checksum)
+	if err != nil {
+		return err
+	}
+	if stored {
+		// Drain the frames of a retried store; the data is already here
+		for {
+			if _, err := stream.Recv(); err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+		}
+		return stream.SendAndClose(&pb.StoreChunkResponse{
+			Success: true,
+		})
+	}
+
+	reader := &frameReader{
+		buf: first.Data,
+		recv: func() ([]byte, error) {
+			frame, err := stream.Recv()
+			if err != nil {
+				return nil, err
+			}
+			return frame.Data, nil
+		},
+	}
+
+	n, err := s.writeChunk(first.ChunkId, reader, first.Checksum)
+	if err != nil {
+		return chunkStatus(err, "Failed to store chunk")
+	}
+
+	log.Printf("Stored chunk %s (%d bytes)", first.ChunkId, n)
+
+	return stream.SendAndClose(&pb.StoreChunkResponse{
+		Success: true,
+	})
+}
+
+// openChunk opens a chunk and returns a reader over the range requested in
+// req. whole reports whether the range is the entire chunk, in which case the
+// data can be checked against its checksum. The caller must close the file.
+func (s *server) openChunk(ctx context.Context, req *pb.RetrieveChunkRequest) (file *os.File, r io.Reader, whole bool, err error) {
+	if err := validateChunkID(req.ChunkId); err != nil {
+		return nil, nil, false, err
+	}
+	if err := authorizeChunkID(ctx, req.ChunkId); err != nil {
+		return nil, nil, false, err
+	}
+	if req.Offset < 0 || req.Length < 0 {
+		return nil, nil, false, status.Errorf(codes.InvalidArgument, "Invalid range: offset %d, length %d", req.Offset, req.Length)
+	}
+
+	file, err = os.Open(s.chunkPath(req.ChunkId))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, false, status.Errorf(codes.NotFound, "Chunk %s not found", req.ChunkId)
+		}
+		return nil, nil, false, status.Errorf(codes.Internal, "Failed to read chunk: %v", err)
+	}
+
+	if req.Offset == 0 && req.Length == 0 {
+		return file, file, true, nil
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, false, status.Errorf(codes.Internal, "Failed to stat chunk: %v", err)
+	}
+
+	// Clamp the range to the end of the chunk
+	length := info.Size() - req.Offset
+	if length < 0 {
+		length = 0
+	}
+	if req.Length > 0 && req.Length < length {
+		length = req.Length
+	}
+	return file, io.NewSectionReader(file, req.Offset, length), false, nil
+}
+
+// RetrieveChunkStream sends a chunk, or a byte range of it, as a stream of
+// frames read directly from disk. A whole chunk is checked against its
+// checksum as it is sent; if it turns out to be corrupt the stream ends with a
+// DataLoss error instead of success.
+func (s *server) RetrieveChunkStream(req *pb.RetrieveChunkRequest, stream pb.StorageService_RetrieveChunkStreamServer) error {
+	file, reader, whole, err := s.openChunk(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := crc32.New(castagnoli)
+	buf := make([]byte, frameSize)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			hash.Write(buf[:n])
+			if sendErr := stream.Send(&pb.ChunkFrame{Data: buf[:n]}); sendErr != nil {
+				return sendErr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "Failed to read chunk: %v", err)
+		}
+	}
+
+	if whole {
+		if err := s.verifyChecksum(req.ChunkId, hash.Sum32()); err != nil {
+			log.Printf("Refusing to serve chunk %s: %v", req.ChunkId, err)
+			return chunkStatus(err, "Failed to verify chunk")
+		}
+	}
+
+	log.Printf("Retrieved chunk %s", req.ChunkId)
+
+	return nil
+}
@@ -0,0 +1,95 @@
+// storage/stream_test.go
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// framesOf returns a recv function that hands out frames one at a time and
+// then fails with end
+func framesOf(frames []string, end error) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		if len(frames) == 0 {
+			return nil, end
+		}
+		frame := frames[0]
+		frames = frames[1:]
+		return []byte(frame), nil
+	}
+}
+
+func TestFrameReader(t *testing.T) {
+	errBroken := errors.New("stream broken")
+
+	tests := []struct {
+		name   string
+		first  string // Data carried by the first frame
+		frames []string
+		end    error
+		want   string
+		err    error
+	}{
+		{name: "single frame", first: "hello", end: io.EOF, want: "hello"},
+		{name: "many frames", first: "he", frames: []string{"l", "lo", " world"}, end: io.EOF, want: "hello world"},
+		{name: "empty frames are skipped", first: "", frames: []string{"", "ab", "", "c"}, end: io.EOF, want: "abc"},
+		{name: "no data", end: io.EOF, want: ""},
+		{name: "frame larger than the read buffer", first: strings.Repeat("x", 100), end: io.EOF, want: strings.Repeat("x", 100)},
+		{name: "stream fails midway", first: "ab", frames: []string{"cd"}, end: errBroken, want: "abcd", err: errBroken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &frameReader{buf: []byte(tt.first), recv: framesOf(tt.frames, tt.end)}
+
+			// Read in small pieces so frames are split across reads
+			var got []byte
+			buf := make([]byte, 3)
+			var err error
+			for {
+				var n int
+				n, err = r.Read(buf)
+				got = append(got, buf[:n]...)
+				if err != nil {
+					break
+				}
+			}
+			if err == io.EOF {
+				err = nil
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("data = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteChunkFromFrames(t *testing.T) {
+	s := NewServer(t.TempDir(), nil)
+
+	frames := []string{strings.Repeat("a", 10), "", strings.Repeat("b", 7)}
+	r := &frameReader{buf: []byte("start"), recv: framesOf(frames, io.EOF)}
+	n, err := s.writeChunk(testHandle, r, nil)
+	if err != nil {
+		t.Fatalf("write chunk: %v", err)
+	}
+
+	want := "start" + strings.Join(frames, "")
+	if n != int64(len(want)) {
+		t.Errorf("wrote %d bytes, want %d", n, len(want))
+	}
+	data, err := os.ReadFile(s.chunkPath(testHandle))
+	if err != nil {
+		t.Fatalf("read chunk: %v", err)
+	}
+	if string(data) != want {
+		t.Errorf("chunk = %q, want %q", data, want)
+	}
+}